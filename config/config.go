@@ -57,6 +57,17 @@ func LoadConfig(path string) IConfig {
 				return b
 			}(),
 			gcpbucket: envMap["APP_GCP_BUCKET"],
+			stage: func() string {
+				// Default prod, so a deployment that does not set it is treated as production
+				switch envMap["APP_STAGE"] {
+				case "":
+					return "prod"
+				case "dev", "test", "prod":
+					return envMap["APP_STAGE"]
+				}
+				log.Fatalf("load app stage failed: %s is not dev, test or prod", envMap["APP_STAGE"])
+				return ""
+			}(),
 		},
 		db: &db{
 			host: envMap["DB_HOST"],
@@ -98,6 +109,223 @@ func LoadConfig(path string) IConfig {
 				}
 				return t
 			}(),
+			signingAlg: func() string {
+				// Default HS256 with the secret key
+				switch envMap["JWT_SIGNING_ALG"] {
+				case "", "HS256":
+					return "HS256"
+				case "RS256", "EdDSA":
+					return envMap["JWT_SIGNING_ALG"]
+				default:
+					log.Fatalf("load jwt signing alg failed: %v is not supported", envMap["JWT_SIGNING_ALG"])
+				}
+				return ""
+			}(),
+			keyRotateInterval: func() time.Duration {
+				// Default 30 days
+				if envMap["JWT_KEY_ROTATE_DAYS"] == "" {
+					return 30 * 24 * time.Hour
+				}
+				d, err := strconv.Atoi(envMap["JWT_KEY_ROTATE_DAYS"])
+				if err != nil || d < 1 {
+					log.Fatalf("load jwt key rotate interval failed: %v", err)
+				}
+				return time.Duration(d) * 24 * time.Hour
+			}(),
+			keyCheckInterval: func() time.Duration {
+				// Default 1 hour
+				if envMap["JWT_KEY_CHECK_MINUTES"] == "" {
+					return time.Hour
+				}
+				m, err := strconv.Atoi(envMap["JWT_KEY_CHECK_MINUTES"])
+				if err != nil || m < 1 {
+					log.Fatalf("load jwt key check interval failed: %v", err)
+				}
+				return time.Duration(m) * time.Minute
+			}(),
+		},
+		order: &order{
+			returnWindow: func() time.Duration {
+				// Default 7 days
+				if envMap["ORDER_RETURN_WINDOW_DAYS"] == "" {
+					return 7 * 24 * time.Hour
+				}
+				d, err := strconv.Atoi(envMap["ORDER_RETURN_WINDOW_DAYS"])
+				if err != nil {
+					log.Fatalf("load order return window failed: %v", err)
+				}
+				return time.Duration(d) * 24 * time.Hour
+			}(),
+			promptPayId:         envMap["ORDER_PROMPTPAY_ID"],
+			allowUnreadableSlip: envMap["ORDER_ALLOW_UNREADABLE_SLIP"] == "true",
+			unpaidTimeout: func() time.Duration {
+				// Default 24 hours, 0 turns off the automatic cancellation
+				if envMap["ORDER_UNPAID_TIMEOUT_HOURS"] == "" {
+					return 24 * time.Hour
+				}
+				h, err := strconv.Atoi(envMap["ORDER_UNPAID_TIMEOUT_HOURS"])
+				if err != nil {
+					log.Fatalf("load order unpaid timeout failed: %v", err)
+				}
+				return time.Duration(h) * time.Hour
+			}(),
+			unpaidCheckInterval: func() time.Duration {
+				// Default 10 minutes
+				if envMap["ORDER_UNPAID_CHECK_MINUTES"] == "" {
+					return 10 * time.Minute
+				}
+				m, err := strconv.Atoi(envMap["ORDER_UNPAID_CHECK_MINUTES"])
+				if err != nil || m < 1 {
+					log.Fatalf("load order unpaid check interval failed: %v", err)
+				}
+				return time.Duration(m) * time.Minute
+			}(),
+		},
+		payment: &payment{
+			mockEnabled: envMap["PAYMENT_MOCK_ENABLED"] == "true",
+			mockSecret:  envMap["PAYMENT_MOCK_SECRET"],
+		},
+		store: &store{
+			name:    envMap["STORE_NAME"],
+			address: envMap["STORE_ADDRESS"],
+			taxId:   envMap["STORE_TAX_ID"],
+			phone:   envMap["STORE_PHONE"],
+			fiscalYearStart: func() time.Month {
+				// Default January
+				if envMap["STORE_FISCAL_YEAR_START_MONTH"] == "" {
+					return time.January
+				}
+				m, err := strconv.Atoi(envMap["STORE_FISCAL_YEAR_START_MONTH"])
+				if err != nil || m < 1 || m > 12 {
+					log.Fatalf("load store fiscal year start month failed: %v", err)
+				}
+				return time.Month(m)
+			}(),
+			vatRate: func() float64 {
+				// Default 7%
+				if envMap["STORE_VAT_RATE"] == "" {
+					return 7
+				}
+				v, err := strconv.ParseFloat(envMap["STORE_VAT_RATE"], 64)
+				if err != nil || v < 0 {
+					log.Fatalf("load store vat rate failed: %v", err)
+				}
+				return v
+			}(),
+			invoiceFont: envMap["STORE_INVOICE_FONT"],
+		},
+		mail: &mail{
+			driver: func() string {
+				// Default log, prints the messages instead of sending them
+				switch envMap["MAIL_DRIVER"] {
+				case "":
+					return "log"
+				case "smtp", "file", "log":
+					return envMap["MAIL_DRIVER"]
+				default:
+					log.Fatalf("load mail driver failed: unknown driver %s", envMap["MAIL_DRIVER"])
+				}
+				return ""
+			}(),
+			host: envMap["MAIL_HOST"],
+			port: func() int {
+				// Default 587
+				if envMap["MAIL_PORT"] == "" {
+					return 587
+				}
+				p, err := strconv.Atoi(envMap["MAIL_PORT"])
+				if err != nil {
+					log.Fatalf("load mail port failed: %v", err)
+				}
+				return p
+			}(),
+			username: envMap["MAIL_USERNAME"],
+			password: envMap["MAIL_PASSWORD"],
+			from:     envMap["MAIL_FROM"],
+			dir:      envMap["MAIL_DIR"],
+		},
+		user: &user{
+			requireVerifiedEmail: envMap["USER_REQUIRE_VERIFIED_EMAIL"] == "true",
+			verifyEmailExpires: func() time.Duration {
+				// Default 24 hours
+				if envMap["USER_VERIFY_EMAIL_EXPIRES_HOURS"] == "" {
+					return 24 * time.Hour
+				}
+				h, err := strconv.Atoi(envMap["USER_VERIFY_EMAIL_EXPIRES_HOURS"])
+				if err != nil || h < 1 {
+					log.Fatalf("load user verify email expires failed: %v", err)
+				}
+				return time.Duration(h) * time.Hour
+			}(),
+			verifyEmailUrl: envMap["USER_VERIFY_EMAIL_URL"],
+			passwordResetExpires: func() time.Duration {
+				// Default 1 hour
+				if envMap["USER_PASSWORD_RESET_EXPIRES_MINUTES"] == "" {
+					return time.Hour
+				}
+				m, err := strconv.Atoi(envMap["USER_PASSWORD_RESET_EXPIRES_MINUTES"])
+				if err != nil || m < 1 {
+					log.Fatalf("load user password reset expires failed: %v", err)
+				}
+				return time.Duration(m) * time.Minute
+			}(),
+			passwordResetUrl:    envMap["USER_PASSWORD_RESET_URL"],
+			mfaRequiredForAdmin: envMap["USER_MFA_REQUIRED_FOR_ADMIN"] == "true",
+			mfaChallengeExpires: func() time.Duration {
+				// Default 5 minutes
+				if envMap["USER_MFA_CHALLENGE_EXPIRES_SECONDS"] == "" {
+					return 5 * time.Minute
+				}
+				t, err := strconv.Atoi(envMap["USER_MFA_CHALLENGE_EXPIRES_SECONDS"])
+				if err != nil || t < 1 {
+					log.Fatalf("load user mfa challenge expires failed: %v", err)
+				}
+				return time.Duration(t) * time.Second
+			}(),
+			loginFreeFailures: func() int {
+				// Default 3
+				if envMap["USER_LOGIN_FREE_FAILURES"] == "" {
+					return 3
+				}
+				n, err := strconv.Atoi(envMap["USER_LOGIN_FREE_FAILURES"])
+				if err != nil || n < 1 {
+					log.Fatalf("load user login free failures failed: %v", err)
+				}
+				return n
+			}(),
+			loginMaxFailures: func() int {
+				// Default 10
+				if envMap["USER_LOGIN_MAX_FAILURES"] == "" {
+					return 10
+				}
+				n, err := strconv.Atoi(envMap["USER_LOGIN_MAX_FAILURES"])
+				if err != nil || n < 1 {
+					log.Fatalf("load user login max failures failed: %v", err)
+				}
+				return n
+			}(),
+			loginMaxFailuresPerIp: func() int {
+				// Default 50
+				if envMap["USER_LOGIN_MAX_FAILURES_PER_IP"] == "" {
+					return 50
+				}
+				n, err := strconv.Atoi(envMap["USER_LOGIN_MAX_FAILURES_PER_IP"])
+				if err != nil || n < 1 {
+					log.Fatalf("load user login max failures per ip failed: %v", err)
+				}
+				return n
+			}(),
+			loginLockout: func() time.Duration {
+				// Default 15 minutes
+				if envMap["USER_LOGIN_LOCKOUT_MINUTES"] == "" {
+					return 15 * time.Minute
+				}
+				m, err := strconv.Atoi(envMap["USER_LOGIN_LOCKOUT_MINUTES"])
+				if err != nil || m < 1 {
+					log.Fatalf("load user login lockout failed: %v", err)
+				}
+				return time.Duration(m) * time.Minute
+			}(),
 		},
 	}
 }
@@ -106,12 +334,22 @@ type IConfig interface {
 	App() IAppConfig
 	Db() IDbConfig
 	Jwt() IJwtConfig
+	Order() IOrderConfig
+	Payment() IPaymentConfig
+	Store() IStoreConfig
+	Mail() IMailConfig
+	User() IUserConfig
 }
 
 type config struct {
-	app *app
-	db  *db
-	jwt *jwt
+	app     *app
+	db      *db
+	jwt     *jwt
+	order   *order
+	payment *payment
+	store   *store
+	mail    *mail
+	user    *user
 }
 
 type IAppConfig interface {
@@ -125,6 +363,8 @@ type IAppConfig interface {
 	GCPBucket() string
 	Host() string
 	Port() int
+	Stage() string
+	IsProduction() bool
 }
 
 type app struct {
@@ -137,6 +377,7 @@ type app struct {
 	bodyLimit    int //bytes
 	fileLimit    int //bytes
 	gcpbucket    string
+	stage        string //dev, test or prod
 }
 
 func (c *config) App() IAppConfig {
@@ -152,6 +393,8 @@ func (a *app) FileLimit() int              { return a.fileLimit }
 func (a *app) GCPBucket() string           { return a.gcpbucket }
 func (a *app) Host() string                { return a.host }
 func (a *app) Port() int                   { return a.port }
+func (a *app) Stage() string               { return a.stage }
+func (a *app) IsProduction() bool          { return a.stage == "prod" }
 
 type IDbConfig interface {
 	Url() string
@@ -193,23 +436,178 @@ type IJwtConfig interface {
 	RefreshExpiresAt() int
 	SetJwtAccessExpires(t int)
 	SetJwtRefreshExpires(t int)
+	SigningAlg() string
+	KeyRotateInterval() time.Duration
+	KeyCheckInterval() time.Duration
 }
 
 type jwt struct {
-	secertKey        string
-	adminKey         string
-	apiKey           string
-	accessExpiresAt  int //sec
-	refreshExpiresAt int //sec
+	secertKey         string
+	adminKey          string
+	apiKey            string
+	accessExpiresAt   int    //sec
+	refreshExpiresAt  int    //sec
+	signingAlg        string //HS256, RS256 or EdDSA, admin tokens and api keys are always HS256
+	keyRotateInterval time.Duration
+	keyCheckInterval  time.Duration //how often every instance reloads the keys, a new key is published this long before it signs
 }
 
 func (c *config) Jwt() IJwtConfig {
 	return c.jwt
 }
-func (j *jwt) SecretKey() []byte          { return []byte(j.secertKey) }
-func (j *jwt) AdminKey() []byte           { return []byte(j.adminKey) }
-func (j *jwt) ApiKey() []byte             { return []byte(j.apiKey) }
-func (j *jwt) AccessExpiresAt() int       { return j.accessExpiresAt }
-func (j *jwt) RefreshExpiresAt() int      { return j.refreshExpiresAt }
-func (j *jwt) SetJwtAccessExpires(t int)  { j.accessExpiresAt = t }
-func (j *jwt) SetJwtRefreshExpires(t int) { j.refreshExpiresAt = t }
+func (j *jwt) SecretKey() []byte                { return []byte(j.secertKey) }
+func (j *jwt) AdminKey() []byte                 { return []byte(j.adminKey) }
+func (j *jwt) ApiKey() []byte                   { return []byte(j.apiKey) }
+func (j *jwt) AccessExpiresAt() int             { return j.accessExpiresAt }
+func (j *jwt) RefreshExpiresAt() int            { return j.refreshExpiresAt }
+func (j *jwt) SetJwtAccessExpires(t int)        { j.accessExpiresAt = t }
+func (j *jwt) SetJwtRefreshExpires(t int)       { j.refreshExpiresAt = t }
+func (j *jwt) SigningAlg() string               { return j.signingAlg }
+func (j *jwt) KeyRotateInterval() time.Duration { return j.keyRotateInterval }
+func (j *jwt) KeyCheckInterval() time.Duration  { return j.keyCheckInterval }
+
+type IOrderConfig interface {
+	ReturnWindow() time.Duration
+	PromptPayId() string
+	AllowUnreadableSlip() bool
+	UnpaidTimeout() time.Duration
+	UnpaidCheckInterval() time.Duration
+}
+
+type order struct {
+	returnWindow        time.Duration
+	promptPayId         string
+	allowUnreadableSlip bool //slips without a readable mini-QR are kept for admins, they are not de-duplicated
+	unpaidTimeout       time.Duration
+	unpaidCheckInterval time.Duration
+}
+
+func (c *config) Order() IOrderConfig {
+	return c.order
+}
+func (o *order) ReturnWindow() time.Duration        { return o.returnWindow }
+func (o *order) PromptPayId() string                { return o.promptPayId }
+func (o *order) AllowUnreadableSlip() bool          { return o.allowUnreadableSlip }
+func (o *order) UnpaidTimeout() time.Duration       { return o.unpaidTimeout }
+func (o *order) UnpaidCheckInterval() time.Duration { return o.unpaidCheckInterval }
+
+type IPaymentConfig interface {
+	MockEnabled() bool
+	MockSecret() string
+}
+
+type payment struct {
+	mockEnabled bool //the mock provider is registered, never in production
+	mockSecret  string
+}
+
+func (c *config) Payment() IPaymentConfig {
+	return c.payment
+}
+func (p *payment) MockEnabled() bool  { return p.mockEnabled }
+func (p *payment) MockSecret() string { return p.mockSecret }
+
+type IStoreConfig interface {
+	Name() string
+	Address() string
+	TaxId() string
+	Phone() string
+	FiscalYearStart() time.Month
+	VatRate() float64
+	InvoiceFont() string
+}
+
+type store struct {
+	name            string
+	address         string
+	taxId           string
+	phone           string
+	fiscalYearStart time.Month
+	vatRate         float64 //percent, prices include vat
+	invoiceFont     string  //path to a utf-8 ttf font, empty -> helvetica
+}
+
+func (c *config) Store() IStoreConfig {
+	return c.store
+}
+func (s *store) Name() string                { return s.name }
+func (s *store) Address() string             { return s.address }
+func (s *store) TaxId() string               { return s.taxId }
+func (s *store) Phone() string               { return s.phone }
+func (s *store) FiscalYearStart() time.Month { return s.fiscalYearStart }
+func (s *store) VatRate() float64            { return s.vatRate }
+func (s *store) InvoiceFont() string         { return s.invoiceFont }
+
+type IMailConfig interface {
+	Driver() string
+	Host() string
+	Port() int
+	Username() string
+	Password() string
+	From() string
+	Dir() string
+}
+
+type mail struct {
+	driver   string //smtp | file | log
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+	dir      string //where the file driver writes the messages
+}
+
+func (c *config) Mail() IMailConfig {
+	return c.mail
+}
+func (m *mail) Driver() string   { return m.driver }
+func (m *mail) Host() string     { return m.host }
+func (m *mail) Port() int        { return m.port }
+func (m *mail) Username() string { return m.username }
+func (m *mail) Password() string { return m.password }
+func (m *mail) From() string     { return m.from }
+func (m *mail) Dir() string      { return m.dir }
+
+type IUserConfig interface {
+	RequireVerifiedEmail() bool
+	VerifyEmailExpires() time.Duration
+	VerifyEmailUrl() string
+	PasswordResetExpires() time.Duration
+	PasswordResetUrl() string
+	MfaRequiredForAdmin() bool
+	MfaChallengeExpires() time.Duration
+	LoginFreeFailures() int
+	LoginMaxFailures() int
+	LoginMaxFailuresPerIp() int
+	LoginLockout() time.Duration
+}
+
+type user struct {
+	requireVerifiedEmail  bool //sign in is refused until the email is verified
+	verifyEmailExpires    time.Duration
+	verifyEmailUrl        string //page that posts the token to /users/verify-email
+	passwordResetExpires  time.Duration
+	passwordResetUrl      string //page that posts the token and the new password to /users/password/reset
+	mfaRequiredForAdmin   bool   //admins without totp have to enroll before they get a passport
+	mfaChallengeExpires   time.Duration
+	loginFreeFailures     int //failures before the backoff starts
+	loginMaxFailures      int //failures on one account before it is locked for loginLockout
+	loginMaxFailuresPerIp int
+	loginLockout          time.Duration
+}
+
+func (c *config) User() IUserConfig {
+	return c.user
+}
+func (u *user) RequireVerifiedEmail() bool          { return u.requireVerifiedEmail }
+func (u *user) VerifyEmailExpires() time.Duration   { return u.verifyEmailExpires }
+func (u *user) VerifyEmailUrl() string              { return u.verifyEmailUrl }
+func (u *user) PasswordResetExpires() time.Duration { return u.passwordResetExpires }
+func (u *user) PasswordResetUrl() string            { return u.passwordResetUrl }
+func (u *user) MfaRequiredForAdmin() bool           { return u.mfaRequiredForAdmin }
+func (u *user) MfaChallengeExpires() time.Duration  { return u.mfaChallengeExpires }
+func (u *user) LoginFreeFailures() int              { return u.loginFreeFailures }
+func (u *user) LoginMaxFailures() int               { return u.loginMaxFailures }
+func (u *user) LoginMaxFailuresPerIp() int          { return u.loginMaxFailuresPerIp }
+func (u *user) LoginLockout() time.Duration         { return u.loginLockout }
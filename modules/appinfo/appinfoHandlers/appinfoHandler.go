@@ -43,6 +43,7 @@ func (h *appinfoHandler) GenerateApiKey(c *fiber.Ctx) error {
 		auth.ApiKey,
 		h.cfg.Jwt(),
 		nil,
+		nil,
 	)
 	if err != nil {
 		return entities.NewResponse(c).Error(
@@ -0,0 +1,113 @@
+package credits
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+type GiftCard struct {
+	Id          string  `db:"id" json:"id"`
+	Code        string  `db:"code" json:"code"`
+	Amount      float64 `db:"amount" json:"amount"`
+	Balance     float64 `db:"balance" json:"balance"`
+	Active      bool    `db:"active" json:"active"` // purchased cards are active once the order is paid
+	ExpiresAt   *string `db:"expires_at" json:"expires_at"`
+	IssuedBy    *string `db:"issued_by" json:"issued_by"`
+	PurchasedBy *string `db:"purchased_by" json:"purchased_by"`
+	OrderId     *string `db:"order_id" json:"order_id"`
+	Note        string  `db:"note" json:"note"`
+	CreatedAt   string  `db:"created_at" json:"created_at"`
+}
+
+type GiftCardReq struct {
+	Amount    float64 `json:"amount" form:"amount"`
+	ExpiresAt string  `json:"expires_at" form:"expires_at"` // YYYY-MM-DD, empty -> never
+	Note      string  `json:"note" form:"note"`
+	IssuedBy  string  `json:"-"`
+}
+
+type GiftCardPurchaseReq struct {
+	UserId  string  `json:"-"`
+	Amount  float64 `json:"amount" form:"amount"`
+	Contact string  `json:"contact" form:"contact"`
+}
+
+type GiftCardPurchase struct {
+	GiftCard *GiftCard `json:"gift_card"`
+	OrderId  string    `json:"order_id"` // pay this order to activate the card
+}
+
+type GiftCardCheckReq struct {
+	Code string `json:"code" form:"code"`
+}
+
+type GiftCardFilter struct {
+	PurchasedBy string `query:"purchased_by"`
+}
+
+type LedgerEntry struct {
+	Id         string  `db:"id" json:"id"`
+	GiftCardId *string `db:"gift_card_id" json:"gift_card_id"`
+	UserId     *string `db:"user_id" json:"user_id"`
+	OrderId    *string `db:"order_id" json:"order_id"`
+	Kind       string  `db:"kind" json:"kind"` // issue | purchase | redeem | refund | adjust
+	Amount     float64 `db:"amount" json:"amount"`
+	Note       string  `db:"note" json:"note"`
+	CreatedBy  *string `db:"created_by" json:"created_by"`
+	CreatedAt  string  `db:"created_at" json:"created_at"`
+}
+
+type StoreCredit struct {
+	UserId  string         `json:"user_id"`
+	Balance float64        `json:"balance"`
+	Entries []*LedgerEntry `json:"entries"`
+}
+
+// AdjustReq credits (+) or debits (-) the store credit of a user
+type AdjustReq struct {
+	UserId    string  `json:"-"`
+	Amount    float64 `json:"amount" form:"amount"`
+	Note      string  `json:"note" form:"note"`
+	CreatedBy string  `json:"-"`
+}
+
+// RedeemReq pays for an order with a gift card and/or the store credit of the user
+type RedeemReq struct {
+	OrderId        string
+	UserId         string
+	GiftCardCode   string
+	UseStoreCredit bool
+	Amount         float64 // the most that can be taken
+}
+
+// Without 0, O, 1 and I which are easy to mix up
+const codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// NewCode returns a random code formatted as XXXX-XXXX-XXXX-XXXX
+func NewCode() (string, error) {
+	b := make([]byte, 16)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(codeAlphabet))))
+		if err != nil {
+			return "", fmt.Errorf("generate gift card code failed: %v", err)
+		}
+		b[i] = codeAlphabet[n.Int64()]
+	}
+	return NormalizeCode(string(b)), nil
+}
+
+// NormalizeCode accepts a code typed in lower case, with or without dashes and spaces
+func NormalizeCode(code string) string {
+	code = strings.ToUpper(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+
+	groups := make([]string, 0)
+	for len(code) > 4 {
+		groups = append(groups, code[:4])
+		code = code[4:]
+	}
+	groups = append(groups, code)
+	return strings.Join(groups, "-")
+}
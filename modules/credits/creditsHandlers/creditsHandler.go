@@ -0,0 +1,240 @@
+package creditsHandlers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/LGROW101/lgrow-shop/config"
+	"github.com/LGROW101/lgrow-shop/modules/credits"
+	"github.com/LGROW101/lgrow-shop/modules/credits/creditsUsecases"
+	"github.com/LGROW101/lgrow-shop/modules/entities"
+	"github.com/gofiber/fiber/v2"
+)
+
+type creditsHandlersErrCode string
+
+const (
+	findGiftCardErr      creditsHandlersErrCode = "credits-001"
+	insertGiftCardErr    creditsHandlersErrCode = "credits-002"
+	purchaseGiftCardErr  creditsHandlersErrCode = "credits-003"
+	checkGiftCardErr     creditsHandlersErrCode = "credits-004"
+	findStoreCreditErr   creditsHandlersErrCode = "credits-005"
+	adjustStoreCreditErr creditsHandlersErrCode = "credits-006"
+)
+
+type ICreditsHandler interface {
+	FindGiftCard(c *fiber.Ctx) error
+	InsertGiftCard(c *fiber.Ctx) error
+	PurchaseGiftCard(c *fiber.Ctx) error
+	CheckGiftCard(c *fiber.Ctx) error
+	FindStoreCredit(c *fiber.Ctx) error
+	AdjustStoreCredit(c *fiber.Ctx) error
+}
+
+type creditsHandler struct {
+	cfg            config.IConfig
+	creditsUsecase creditsUsecases.ICreditsUsecase
+}
+
+func CreditsHandler(cfg config.IConfig, creditsUsecase creditsUsecases.ICreditsUsecase) ICreditsHandler {
+	return &creditsHandler{
+		cfg:            cfg,
+		creditsUsecase: creditsUsecase,
+	}
+}
+
+func (h *creditsHandler) FindGiftCard(c *fiber.Ctx) error {
+	req := new(credits.GiftCardFilter)
+	if err := c.QueryParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(findGiftCardErr),
+			err.Error(),
+		).Res()
+	}
+	// /giftcards/:user_id -> cards bought by the user
+	if userId := strings.Trim(c.Params("user_id"), " "); userId != "" {
+		req.PurchasedBy = userId
+	}
+
+	giftCards, err := h.creditsUsecase.FindGiftCard(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findGiftCardErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, giftCards).Res()
+}
+
+func (h *creditsHandler) InsertGiftCard(c *fiber.Ctx) error {
+	req := new(credits.GiftCardReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertGiftCardErr),
+			err.Error(),
+		).Res()
+	}
+	req.IssuedBy = c.Locals("userId").(string)
+	req.Note = strings.TrimSpace(req.Note)
+
+	if req.Amount <= 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertGiftCardErr),
+			"amount must more than 0",
+		).Res()
+	}
+	// Date	YYYY-MM-DD, valid until the end of the day
+	if req.ExpiresAt != "" {
+		expiresAt, err := time.Parse("2006-01-02", req.ExpiresAt)
+		if err != nil || expiresAt.Before(time.Now()) {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(insertGiftCardErr),
+				"expires at is invalid",
+			).Res()
+		}
+		req.ExpiresAt = expiresAt.Format("2006-01-02") + " 23:59:59"
+	}
+
+	giftCard, err := h.creditsUsecase.InsertGiftCard(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(insertGiftCardErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, giftCard).Res()
+}
+
+func (h *creditsHandler) PurchaseGiftCard(c *fiber.Ctx) error {
+	req := new(credits.GiftCardPurchaseReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(purchaseGiftCardErr),
+			err.Error(),
+		).Res()
+	}
+	req.UserId = strings.Trim(c.Params("user_id"), " ")
+	req.Contact = strings.TrimSpace(req.Contact)
+
+	if req.Amount <= 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(purchaseGiftCardErr),
+			"amount must more than 0",
+		).Res()
+	}
+	if req.Contact == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(purchaseGiftCardErr),
+			"contact is required",
+		).Res()
+	}
+
+	res, err := h.creditsUsecase.PurchaseGiftCard(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(purchaseGiftCardErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, res).Res()
+}
+
+func (h *creditsHandler) CheckGiftCard(c *fiber.Ctx) error {
+	req := new(credits.GiftCardCheckReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(checkGiftCardErr),
+			err.Error(),
+		).Res()
+	}
+	if strings.TrimSpace(req.Code) == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(checkGiftCardErr),
+			"code is required",
+		).Res()
+	}
+
+	giftCard, err := h.creditsUsecase.CheckGiftCard(req.Code)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(checkGiftCardErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, giftCard).Res()
+}
+
+func (h *creditsHandler) FindStoreCredit(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+
+	credit, err := h.creditsUsecase.FindStoreCredit(userId)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findStoreCreditErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, credit).Res()
+}
+
+func (h *creditsHandler) AdjustStoreCredit(c *fiber.Ctx) error {
+	req := new(credits.AdjustReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(adjustStoreCreditErr),
+			err.Error(),
+		).Res()
+	}
+	req.UserId = strings.Trim(c.Params("user_id"), " ")
+	req.CreatedBy = c.Locals("userId").(string)
+	req.Note = strings.TrimSpace(req.Note)
+
+	if req.Amount == 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(adjustStoreCreditErr),
+			"amount is required",
+		).Res()
+	}
+	if req.Note == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(adjustStoreCreditErr),
+			"note is required",
+		).Res()
+	}
+
+	credit, err := h.creditsUsecase.AdjustStoreCredit(req)
+	if err != nil {
+		switch err.Error() {
+		case "user not found", "store credit balance is not enough":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(adjustStoreCreditErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(adjustStoreCreditErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, credit).Res()
+}
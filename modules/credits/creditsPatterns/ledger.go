@@ -0,0 +1,219 @@
+package creditsPatterns
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/LGROW101/lgrow-shop/modules/credits"
+	"github.com/jmoiron/sqlx"
+)
+
+// Balances are always derived from the ledger, the card or user row is locked by the caller
+const (
+	queryGiftCardBalance = `
+	SELECT
+		COALESCE(SUM("amount"), 0)
+	FROM "credits_ledger"
+	WHERE "gift_card_id" = $1;`
+
+	queryUserBalance = `
+	SELECT
+		COALESCE(SUM("amount"), 0)
+	FROM "credits_ledger"
+	WHERE "user_id" = $1;`
+
+	queryInsertEntry = `
+	INSERT INTO "credits_ledger" (
+		"gift_card_id",
+		"user_id",
+		"order_id",
+		"kind",
+		"amount",
+		"note",
+		"created_by"
+	)
+	VALUES ($1, $2, $3, $4, $5, $6, $7);`
+)
+
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// LockUser serializes the store credit changes of a user
+func LockUser(ctx context.Context, tx *sqlx.Tx, userId string) error {
+	var id string
+	if err := tx.QueryRowxContext(ctx, `SELECT "id" FROM "users" WHERE "id" = $1 FOR UPDATE;`, userId).Scan(&id); err != nil {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
+
+func UserBalance(ctx context.Context, tx *sqlx.Tx, userId string) (float64, error) {
+	var balance float64
+	if err := tx.GetContext(ctx, &balance, queryUserBalance, userId); err != nil {
+		return 0, fmt.Errorf("get store credit balance failed: %v", err)
+	}
+	return round(balance), nil
+}
+
+// InsertEntry appends an entry, exactly one of giftCardId and userId is set
+func InsertEntry(ctx context.Context, tx *sqlx.Tx, giftCardId, userId, orderId *string, kind string, amount float64, note string, createdBy *string) error {
+	if _, err := tx.ExecContext(ctx, queryInsertEntry, giftCardId, userId, orderId, kind, round(amount), note, createdBy); err != nil {
+		return fmt.Errorf("insert credits_ledger failed: %v", err)
+	}
+	return nil
+}
+
+// Redeem takes up to req.Amount from the gift card first and then the store credit,
+// it returns the amount taken
+func Redeem(ctx context.Context, tx *sqlx.Tx, req *credits.RedeemReq) (float64, error) {
+	remaining := round(req.Amount)
+	note := fmt.Sprintf("order %s", req.OrderId)
+
+	if req.GiftCardCode != "" {
+		var (
+			giftCardId string
+			expired    bool
+		)
+		query := `
+		SELECT
+			"id",
+			COALESCE("expires_at" < now(), FALSE) AS "expired"
+		FROM "gift_cards"
+		WHERE "code" = $1
+		FOR UPDATE;`
+
+		if err := tx.QueryRowxContext(ctx, query, credits.NormalizeCode(req.GiftCardCode)).Scan(&giftCardId, &expired); err != nil {
+			return 0, fmt.Errorf("gift card not found")
+		}
+		if expired {
+			return 0, fmt.Errorf("gift card has expired")
+		}
+
+		var balance float64
+		if err := tx.GetContext(ctx, &balance, queryGiftCardBalance, giftCardId); err != nil {
+			return 0, fmt.Errorf("get gift card balance failed: %v", err)
+		}
+		if round(balance) <= 0 {
+			return 0, fmt.Errorf("gift card has no balance")
+		}
+
+		taken := math.Min(round(balance), remaining)
+		if taken > 0 {
+			if err := InsertEntry(ctx, tx, &giftCardId, nil, &req.OrderId, "redeem", -taken, note, nil); err != nil {
+				return 0, err
+			}
+			remaining = round(remaining - taken)
+		}
+	}
+
+	if req.UseStoreCredit && req.UserId != "" && remaining > 0 {
+		if err := LockUser(ctx, tx, req.UserId); err != nil {
+			return 0, err
+		}
+		balance, err := UserBalance(ctx, tx, req.UserId)
+		if err != nil {
+			return 0, err
+		}
+
+		taken := math.Min(balance, remaining)
+		if taken > 0 {
+			if err := InsertEntry(ctx, tx, nil, &req.UserId, &req.OrderId, "redeem", -taken, note, nil); err != nil {
+				return 0, err
+			}
+			remaining = round(remaining - taken)
+		}
+	}
+	return round(req.Amount - remaining), nil
+}
+
+// RefundRedemptions gives what the order took back to the same gift cards and store credit
+func RefundRedemptions(ctx context.Context, tx *sqlx.Tx, orderId string) error {
+	query := `
+	SELECT
+		"gift_card_id",
+		"user_id",
+		SUM("amount") AS "amount"
+	FROM "credits_ledger"
+	WHERE "order_id" = $1
+	AND "kind" IN ('redeem', 'refund')
+	GROUP BY "gift_card_id", "user_id"
+	HAVING SUM("amount") < 0;`
+
+	entries := make([]*credits.LedgerEntry, 0)
+	if err := tx.SelectContext(ctx, &entries, query, orderId); err != nil {
+		return fmt.Errorf("get redemptions failed: %v", err)
+	}
+
+	for _, e := range entries {
+		if err := InsertEntry(ctx, tx, e.GiftCardId, e.UserId, &orderId, "refund", -e.Amount, fmt.Sprintf("order %s canceled", orderId), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RefundPart gives up to amount of what the order took back, the store credit first as it was taken last
+func RefundPart(ctx context.Context, tx *sqlx.Tx, orderId string, amount float64, note string) error {
+	query := `
+	SELECT
+		"gift_card_id",
+		"user_id",
+		SUM("amount") AS "amount"
+	FROM "credits_ledger"
+	WHERE "order_id" = $1
+	AND "kind" IN ('redeem', 'refund')
+	GROUP BY "gift_card_id", "user_id"
+	HAVING SUM("amount") < 0
+	ORDER BY "user_id" IS NULL;`
+
+	entries := make([]*credits.LedgerEntry, 0)
+	if err := tx.SelectContext(ctx, &entries, query, orderId); err != nil {
+		return fmt.Errorf("get redemptions failed: %v", err)
+	}
+
+	remaining := round(amount)
+	for _, e := range entries {
+		if remaining <= 0 {
+			break
+		}
+		refund := math.Min(round(-e.Amount), remaining)
+		if err := InsertEntry(ctx, tx, e.GiftCardId, e.UserId, &orderId, "refund", refund, note, nil); err != nil {
+			return err
+		}
+		remaining = round(remaining - refund)
+	}
+	return nil
+}
+
+// ActivateGiftCards credits the gift cards bought with the order, called when the order is paid
+func ActivateGiftCards(ctx context.Context, tx *sqlx.Tx, orderId string) error {
+	query := `
+	INSERT INTO "credits_ledger" (
+		"gift_card_id",
+		"order_id",
+		"kind",
+		"amount",
+		"note"
+	)
+	SELECT
+		"g"."id",
+		"g"."order_id",
+		'purchase',
+		"g"."amount",
+		'purchased'
+	FROM "gift_cards" "g"
+	WHERE "g"."order_id" = $1
+	AND NOT EXISTS (
+		SELECT 1
+		FROM "credits_ledger" "l"
+		WHERE "l"."gift_card_id" = "g"."id"
+		AND "l"."kind" = 'purchase'
+	);`
+
+	if _, err := tx.ExecContext(ctx, query, orderId); err != nil {
+		return fmt.Errorf("activate gift cards failed: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,294 @@
+package creditsRepositories
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/LGROW101/lgrow-shop/modules/credits"
+	"github.com/LGROW101/lgrow-shop/modules/credits/creditsPatterns"
+	"github.com/LGROW101/lgrow-shop/modules/products"
+	"github.com/jmoiron/sqlx"
+)
+
+type ICreditsRepository interface {
+	FindOneGiftCard(giftCardId string) (*credits.GiftCard, error)
+	FindOneGiftCardByCode(code string) (*credits.GiftCard, error)
+	FindGiftCard(req *credits.GiftCardFilter) ([]*credits.GiftCard, error)
+	InsertGiftCard(req *credits.GiftCardReq) (string, error)
+	PurchaseGiftCard(req *credits.GiftCardPurchaseReq) (*credits.GiftCardPurchase, error)
+	FindStoreCredit(userId string) (*credits.StoreCredit, error)
+	AdjustStoreCredit(req *credits.AdjustReq) error
+}
+
+type creditsRepository struct {
+	db *sqlx.DB
+}
+
+func CreditsRepository(db *sqlx.DB) ICreditsRepository {
+	return &creditsRepository{db: db}
+}
+
+const selectGiftCardQuery = `
+	SELECT
+		"g"."id",
+		"g"."code",
+		"g"."amount",
+		(
+			SELECT
+				COALESCE(SUM("l"."amount"), 0)
+			FROM "credits_ledger" "l"
+			WHERE "l"."gift_card_id" = "g"."id"
+		) AS "balance",
+		EXISTS (
+			SELECT 1
+			FROM "credits_ledger" "l"
+			WHERE "l"."gift_card_id" = "g"."id"
+			AND "l"."kind" IN ('issue', 'purchase')
+		) AS "active",
+		"g"."expires_at",
+		"g"."issued_by",
+		"g"."purchased_by",
+		"g"."order_id",
+		"g"."note",
+		"g"."created_at"
+	FROM "gift_cards" "g"`
+
+func (r *creditsRepository) FindOneGiftCard(giftCardId string) (*credits.GiftCard, error) {
+	giftCard := new(credits.GiftCard)
+	if err := r.db.Get(giftCard, selectGiftCardQuery+`
+	WHERE "g"."id" = $1;`, giftCardId); err != nil {
+		return nil, fmt.Errorf("gift card not found")
+	}
+	return giftCard, nil
+}
+
+func (r *creditsRepository) FindOneGiftCardByCode(code string) (*credits.GiftCard, error) {
+	giftCard := new(credits.GiftCard)
+	if err := r.db.Get(giftCard, selectGiftCardQuery+`
+	WHERE "g"."code" = $1;`, credits.NormalizeCode(code)); err != nil {
+		return nil, fmt.Errorf("gift card not found")
+	}
+	return giftCard, nil
+}
+
+func (r *creditsRepository) FindGiftCard(req *credits.GiftCardFilter) ([]*credits.GiftCard, error) {
+	query := selectGiftCardQuery + `
+	WHERE 1 = 1`
+
+	values := make([]any, 0)
+	if req.PurchasedBy != "" {
+		values = append(values, req.PurchasedBy)
+		query += fmt.Sprintf(`
+	AND "g"."purchased_by" = $%d`, len(values))
+	}
+	query += `
+	ORDER BY "g"."created_at" DESC;`
+
+	giftCards := make([]*credits.GiftCard, 0)
+	if err := r.db.Select(&giftCards, query, values...); err != nil {
+		return nil, fmt.Errorf("get gift cards failed: %v", err)
+	}
+	return giftCards, nil
+}
+
+// InsertGiftCard issues an active card with its full amount credited
+func (r *creditsRepository) InsertGiftCard(req *credits.GiftCardReq) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	code, err := credits.NewCode()
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+
+	query := `
+	INSERT INTO "gift_cards" (
+		"code",
+		"amount",
+		"expires_at",
+		"issued_by",
+		"note"
+	)
+	VALUES ($1, $2, NULLIF($3, '')::TIMESTAMP, $4, $5)
+		RETURNING "id";`
+
+	var giftCardId string
+	if err := tx.QueryRowxContext(ctx, query, code, req.Amount, req.ExpiresAt, req.IssuedBy, req.Note).Scan(&giftCardId); err != nil {
+		tx.Rollback()
+		return "", fmt.Errorf("insert gift card failed: %v", err)
+	}
+
+	if err := creditsPatterns.InsertEntry(ctx, tx, &giftCardId, nil, nil, "issue", req.Amount, req.Note, &req.IssuedBy); err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return giftCardId, nil
+}
+
+// PurchaseGiftCard places an order for the card, the card is credited when the order is paid
+func (r *creditsRepository) PurchaseGiftCard(req *credits.GiftCardPurchaseReq) (*credits.GiftCardPurchase, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	code, err := credits.NewCode()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	queryOrder := `
+	INSERT INTO "orders" (
+		"user_id",
+		"contact",
+		"address",
+		"status"
+	)
+	VALUES ($1, $2, 'gift card', 'waiting')
+		RETURNING "id";`
+
+	var orderId string
+	if err := tx.QueryRowxContext(ctx, queryOrder, req.UserId, req.Contact).Scan(&orderId); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("insert order failed: %v", err)
+	}
+
+	// Not a product in the catalog, nothing to reserve
+	queryItem := `
+	INSERT INTO "products_orders" (
+		"order_id",
+		"qty",
+		"product"
+	)
+	VALUES ($1, 1, $2);`
+
+	item := &products.Product{
+		Title: fmt.Sprintf("Gift card %.2f", req.Amount),
+		Price: req.Amount,
+	}
+	if _, err := tx.ExecContext(ctx, queryItem, orderId, item); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("insert products_orders failed: %v", err)
+	}
+
+	queryHistory := `
+	INSERT INTO "orders_histories" (
+		"order_id",
+		"status",
+		"note"
+	)
+	VALUES ($1, 'waiting', 'order created');`
+
+	if _, err := tx.ExecContext(ctx, queryHistory, orderId); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("insert orders_histories failed: %v", err)
+	}
+
+	queryGiftCard := `
+	INSERT INTO "gift_cards" (
+		"code",
+		"amount",
+		"purchased_by",
+		"order_id"
+	)
+	VALUES ($1, $2, $3, $4)
+		RETURNING "id";`
+
+	var giftCardId string
+	if err := tx.QueryRowxContext(ctx, queryGiftCard, code, req.Amount, req.UserId, orderId).Scan(&giftCardId); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("insert gift card failed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	giftCard, err := r.FindOneGiftCard(giftCardId)
+	if err != nil {
+		return nil, err
+	}
+	return &credits.GiftCardPurchase{
+		GiftCard: giftCard,
+		OrderId:  orderId,
+	}, nil
+}
+
+func (r *creditsRepository) FindStoreCredit(userId string) (*credits.StoreCredit, error) {
+	query := `
+	SELECT
+		"id",
+		"gift_card_id",
+		"user_id",
+		"order_id",
+		"kind",
+		"amount",
+		"note",
+		"created_by",
+		"created_at"
+	FROM "credits_ledger"
+	WHERE "user_id" = $1
+	ORDER BY "created_at" DESC;`
+
+	res := &credits.StoreCredit{
+		UserId:  userId,
+		Entries: make([]*credits.LedgerEntry, 0),
+	}
+	if err := r.db.Select(&res.Entries, query, userId); err != nil {
+		return nil, fmt.Errorf("get store credit failed: %v", err)
+	}
+	for _, e := range res.Entries {
+		res.Balance += e.Amount
+	}
+	res.Balance = math.Round(res.Balance*100) / 100
+	return res, nil
+}
+
+// AdjustStoreCredit refuses debits larger than the balance
+func (r *creditsRepository) AdjustStoreCredit(req *credits.AdjustReq) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := creditsPatterns.LockUser(ctx, tx, req.UserId); err != nil {
+		tx.Rollback()
+		return err
+	}
+	balance, err := creditsPatterns.UserBalance(ctx, tx, req.UserId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if balance+req.Amount < 0 {
+		tx.Rollback()
+		return fmt.Errorf("store credit balance is not enough")
+	}
+
+	if err := creditsPatterns.InsertEntry(ctx, tx, nil, &req.UserId, nil, "adjust", req.Amount, req.Note, &req.CreatedBy); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package creditsUsecases
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/LGROW101/lgrow-shop/modules/credits"
+	"github.com/LGROW101/lgrow-shop/modules/credits/creditsRepositories"
+)
+
+type ICreditsUsecase interface {
+	FindGiftCard(req *credits.GiftCardFilter) ([]*credits.GiftCard, error)
+	InsertGiftCard(req *credits.GiftCardReq) (*credits.GiftCard, error)
+	PurchaseGiftCard(req *credits.GiftCardPurchaseReq) (*credits.GiftCardPurchase, error)
+	CheckGiftCard(code string) (*credits.GiftCard, error)
+	FindStoreCredit(userId string) (*credits.StoreCredit, error)
+	AdjustStoreCredit(req *credits.AdjustReq) (*credits.StoreCredit, error)
+}
+
+type creditsUsecase struct {
+	creditsRepository creditsRepositories.ICreditsRepository
+}
+
+func CreditsUsecase(creditsRepository creditsRepositories.ICreditsRepository) ICreditsUsecase {
+	return &creditsUsecase{
+		creditsRepository: creditsRepository,
+	}
+}
+
+func (u *creditsUsecase) FindGiftCard(req *credits.GiftCardFilter) ([]*credits.GiftCard, error) {
+	giftCards, err := u.creditsRepository.FindGiftCard(req)
+	if err != nil {
+		return nil, err
+	}
+	return giftCards, nil
+}
+
+func (u *creditsUsecase) InsertGiftCard(req *credits.GiftCardReq) (*credits.GiftCard, error) {
+	req.Amount = math.Round(req.Amount*100) / 100
+
+	giftCardId, err := u.creditsRepository.InsertGiftCard(req)
+	if err != nil {
+		return nil, err
+	}
+
+	giftCard, err := u.creditsRepository.FindOneGiftCard(giftCardId)
+	if err != nil {
+		return nil, err
+	}
+	return giftCard, nil
+}
+
+func (u *creditsUsecase) PurchaseGiftCard(req *credits.GiftCardPurchaseReq) (*credits.GiftCardPurchase, error) {
+	req.Amount = math.Round(req.Amount*100) / 100
+
+	res, err := u.creditsRepository.PurchaseGiftCard(req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// CheckGiftCard only tells the balance, who issued or bought the card stays private
+func (u *creditsUsecase) CheckGiftCard(code string) (*credits.GiftCard, error) {
+	giftCard, err := u.creditsRepository.FindOneGiftCardByCode(code)
+	if err != nil {
+		return nil, err
+	}
+	if !giftCard.Active {
+		return nil, fmt.Errorf("gift card not found")
+	}
+
+	return &credits.GiftCard{
+		Code:      giftCard.Code,
+		Amount:    giftCard.Amount,
+		Balance:   giftCard.Balance,
+		Active:    giftCard.Active,
+		ExpiresAt: giftCard.ExpiresAt,
+	}, nil
+}
+
+func (u *creditsUsecase) FindStoreCredit(userId string) (*credits.StoreCredit, error) {
+	credit, err := u.creditsRepository.FindStoreCredit(userId)
+	if err != nil {
+		return nil, err
+	}
+	return credit, nil
+}
+
+func (u *creditsUsecase) AdjustStoreCredit(req *credits.AdjustReq) (*credits.StoreCredit, error) {
+	req.Amount = math.Round(req.Amount*100) / 100
+
+	if err := u.creditsRepository.AdjustStoreCredit(req); err != nil {
+		return nil, err
+	}
+	return u.FindStoreCredit(req.UserId)
+}
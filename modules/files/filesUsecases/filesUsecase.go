@@ -7,6 +7,7 @@ import (
 	"io"
 
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -20,6 +21,8 @@ type IFilesUsecase interface {
 	DeleteFileOnGCP(req []*files.DeleteFileReq) error
 	UploadToStorage(req []*files.FileReq) ([]*files.FileRes, error)
 	DeleteFileOnStorage(req []*files.DeleteFileReq) error
+	UploadPrivate(req *files.FileReq) (*files.FileRes, error)
+	ReadPrivate(destination string) ([]byte, error)
 }
 
 type filesUsecase struct {
@@ -292,3 +295,56 @@ func (u *filesUsecase) DeleteFileOnStorage(req []*files.DeleteFileReq) error {
 	}
 	return nil
 }
+
+// Private files are kept outside the streamed assets and can only be read through ReadPrivate
+const privateRoot = "./assets/private"
+
+func privatePath(destination string) (string, error) {
+	dest := filepath.Clean("/" + destination)
+	if dest == "/" {
+		return "", fmt.Errorf("destination is invalid")
+	}
+	return filepath.Join(privateRoot, dest), nil
+}
+
+func (u *filesUsecase) UploadPrivate(req *files.FileReq) (*files.FileRes, error) {
+	dest, err := privatePath(req.Destination)
+	if err != nil {
+		return nil, err
+	}
+
+	container, err := req.File.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer container.Close()
+
+	b, err := io.ReadAll(container)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+		return nil, fmt.Errorf("mkdir \"%s\" failed: %v", filepath.Dir(dest), err)
+	}
+	if err := os.WriteFile(dest, b, 0600); err != nil {
+		return nil, fmt.Errorf("write file failed: %v", err)
+	}
+
+	return &files.FileRes{
+		FileName: req.FileName,
+	}, nil
+}
+
+func (u *filesUsecase) ReadPrivate(destination string) ([]byte, error) {
+	dest, err := privatePath(destination)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(dest)
+	if err != nil {
+		return nil, fmt.Errorf("file not found")
+	}
+	return b, nil
+}
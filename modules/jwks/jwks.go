@@ -0,0 +1,12 @@
+package jwks
+
+import "time"
+
+type JwtKey struct {
+	Kid        string     `db:"kid"`
+	Alg        string     `db:"alg"`
+	PrivateKey string     `db:"private_key"` // encrypted, see auth.EncodePrivateKey
+	PublicKey  string     `db:"public_key"`  // PEM
+	ActiveFrom time.Time  `db:"active_from"`
+	ExpiresAt  *time.Time `db:"expires_at"`
+}
@@ -0,0 +1,30 @@
+package jwksHandlers
+
+import (
+	"github.com/LGROW101/lgrow-shop/config"
+	"github.com/LGROW101/lgrow-shop/modules/entities"
+	"github.com/LGROW101/lgrow-shop/modules/jwks/jwksUsecases"
+	"github.com/gofiber/fiber/v2"
+)
+
+type IJwksHandler interface {
+	FindJwks(c *fiber.Ctx) error
+}
+
+type jwksHandler struct {
+	cfg         config.IConfig
+	jwksUsecase jwksUsecases.IJwksUsecase
+}
+
+func JwksHandler(cfg config.IConfig, jwksUsecase jwksUsecases.IJwksUsecase) IJwksHandler {
+	return &jwksHandler{
+		cfg:         cfg,
+		jwksUsecase: jwksUsecase,
+	}
+}
+
+// FindJwks is public, verifiers cache it for less than the key check interval
+func (h *jwksHandler) FindJwks(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return entities.NewResponse(c).Success(fiber.StatusOK, h.jwksUsecase.FindJwks()).Res()
+}
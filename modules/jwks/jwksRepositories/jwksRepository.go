@@ -0,0 +1,118 @@
+package jwksRepositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/LGROW101/lgrow-shop/modules/jwks"
+	"github.com/jmoiron/sqlx"
+)
+
+type IJwksRepository interface {
+	FindKey(ctx context.Context) ([]*jwks.JwtKey, error)
+	InsertKey(ctx context.Context, req *jwks.JwtKey, latestKid string, retireAt time.Time) (bool, error)
+	RetireKey(ctx context.Context, retireAt time.Time) (int64, error)
+	DeleteExpiredKey(ctx context.Context) (int64, error)
+}
+
+type jwksRepository struct {
+	db *sqlx.DB
+}
+
+func JwksRepository(db *sqlx.DB) IJwksRepository {
+	return &jwksRepository{
+		db: db,
+	}
+}
+
+// FindKey returns the keys not expired yet, newest first
+func (r *jwksRepository) FindKey(ctx context.Context) ([]*jwks.JwtKey, error) {
+	query := `
+	SELECT
+		"kid",
+		"alg",
+		"private_key",
+		"public_key",
+		"active_from",
+		"expires_at"
+	FROM "jwt_keys"
+	WHERE "expires_at" IS NULL
+	OR "expires_at" > now()
+	ORDER BY "active_from" DESC;`
+
+	keys := make([]*jwks.JwtKey, 0)
+	if err := r.db.SelectContext(ctx, &keys, query); err != nil {
+		return nil, fmt.Errorf("get jwt keys failed: %v", err)
+	}
+	return keys, nil
+}
+
+// InsertKey adds the next key and retires the others, false when another instance
+// rotated since latestKid was read
+func (r *jwksRepository) InsertKey(ctx context.Context, req *jwks.JwtKey, latestKid string, retireAt time.Time) (bool, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `LOCK TABLE "jwt_keys" IN EXCLUSIVE MODE;`); err != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("lock jwt_keys failed: %v", err)
+	}
+
+	var kid string
+	if err := tx.GetContext(ctx, &kid, `SELECT "kid" FROM "jwt_keys" ORDER BY "active_from" DESC LIMIT 1;`); err != nil && err != sql.ErrNoRows {
+		tx.Rollback()
+		return false, fmt.Errorf("get latest jwt key failed: %v", err)
+	}
+	if kid != latestKid {
+		tx.Rollback()
+		return false, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE "jwt_keys" SET "expires_at" = $1 WHERE "expires_at" IS NULL;`, retireAt); err != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("update jwt_keys failed: %v", err)
+	}
+
+	query := `
+	INSERT INTO "jwt_keys" (
+		"kid",
+		"alg",
+		"private_key",
+		"public_key",
+		"active_from"
+	)
+	VALUES ($1, $2, $3, $4, $5);`
+
+	if _, err := tx.ExecContext(ctx, query, req.Kid, req.Alg, req.PrivateKey, req.PublicKey, req.ActiveFrom); err != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("insert jwt key failed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RetireKey expires every key at retireAt, used after switching back to HS256
+func (r *jwksRepository) RetireKey(ctx context.Context, retireAt time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `UPDATE "jwt_keys" SET "expires_at" = $1 WHERE "expires_at" IS NULL;`, retireAt)
+	if err != nil {
+		return 0, fmt.Errorf("update jwt_keys failed: %v", err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows, nil
+}
+
+func (r *jwksRepository) DeleteExpiredKey(ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM "jwt_keys" WHERE "expires_at" < now();`)
+	if err != nil {
+		return 0, fmt.Errorf("delete jwt_keys failed: %v", err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows, nil
+}
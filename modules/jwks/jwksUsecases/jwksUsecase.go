@@ -0,0 +1,162 @@
+package jwksUsecases
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/LGROW101/lgrow-shop/config"
+	"github.com/LGROW101/lgrow-shop/modules/jwks"
+	"github.com/LGROW101/lgrow-shop/modules/jwks/jwksRepositories"
+	"github.com/LGROW101/lgrow-shop/pkg/auth"
+)
+
+type IJwksUsecase interface {
+	RotateKeys(ctx context.Context) (string, error)
+	FindJwks() *auth.Jwks
+}
+
+type jwksUsecase struct {
+	cfg            config.IConfig
+	keys           *auth.KeySet
+	jwksRepository jwksRepositories.IJwksRepository
+}
+
+func JwksUsecase(cfg config.IConfig, keys *auth.KeySet, jwksRepository jwksRepositories.IJwksRepository) IJwksUsecase {
+	return &jwksUsecase{
+		cfg:            cfg,
+		keys:           keys,
+		jwksRepository: jwksRepository,
+	}
+}
+
+// tokenLifetime is the longest a token signed now stays valid, a retired key is kept that long
+func (u *jwksUsecase) tokenLifetime() time.Duration {
+	lifetime := time.Duration(u.cfg.Jwt().AccessExpiresAt()) * time.Second
+	for _, d := range []time.Duration{
+		time.Duration(u.cfg.Jwt().RefreshExpiresAt()) * time.Second,
+		u.cfg.User().VerifyEmailExpires(),
+		u.cfg.User().MfaChallengeExpires(),
+	} {
+		if d > lifetime {
+			lifetime = d
+		}
+	}
+	return lifetime
+}
+
+// rotateDue tells if the next key is needed, keys are newest first
+func (u *jwksUsecase) rotateDue(keys []*jwks.JwtKey, now time.Time) bool {
+	if len(keys) == 0 || keys[0].ExpiresAt != nil || keys[0].Alg != u.cfg.Jwt().SigningAlg() {
+		return true
+	}
+	return !now.Add(u.cfg.Jwt().KeyCheckInterval()).Before(keys[0].ActiveFrom.Add(u.cfg.Jwt().KeyRotateInterval()))
+}
+
+// RotateKeys runs on every instance, it adds the next key when the current one is due
+// and reloads the keys the tokens are signed and verified with
+func (u *jwksUsecase) RotateKeys(ctx context.Context) (string, error) {
+	keys, err := u.jwksRepository.FindKey(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	result := "no rotation"
+	now := time.Now()
+	alg := u.cfg.Jwt().SigningAlg()
+	switch {
+	case alg == "HS256":
+		// Back to the secret key, the keys stay until their tokens expired
+		retired, err := u.jwksRepository.RetireKey(ctx, now.Add(u.tokenLifetime()))
+		if err != nil {
+			return "", err
+		}
+		if retired > 0 {
+			result = fmt.Sprintf("%d keys retired", retired)
+		}
+	case u.rotateDue(keys, now):
+		// The next key signs after every instance loaded it, right away when none is left to sign
+		activeFrom := now.Add(u.cfg.Jwt().KeyCheckInterval())
+		latestKid := ""
+		if len(keys) > 0 {
+			latestKid = keys[0].Kid
+		}
+		if len(keys) == 0 || keys[0].ExpiresAt != nil {
+			activeFrom = now
+		}
+
+		key, err := auth.NewKey(alg, activeFrom)
+		if err != nil {
+			return "", err
+		}
+		privateKey, err := auth.EncodePrivateKey(u.cfg.Jwt().SecretKey(), key.Private)
+		if err != nil {
+			return "", err
+		}
+		publicKey, err := auth.EncodePublicKey(key.Public)
+		if err != nil {
+			return "", err
+		}
+
+		inserted, err := u.jwksRepository.InsertKey(ctx, &jwks.JwtKey{
+			Kid:        key.Kid,
+			Alg:        key.Alg,
+			PrivateKey: privateKey,
+			PublicKey:  publicKey,
+			ActiveFrom: activeFrom,
+		}, latestKid, activeFrom.Add(u.tokenLifetime()))
+		if err != nil {
+			return "", err
+		}
+		if inserted {
+			result = fmt.Sprintf("key %s signs from %s", key.Kid, activeFrom.Format(time.RFC3339))
+		}
+	}
+
+	if _, err := u.jwksRepository.DeleteExpiredKey(ctx); err != nil {
+		return "", err
+	}
+
+	loaded, err := u.loadKeys(ctx)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s, %d keys loaded", result, loaded), nil
+}
+
+func (u *jwksUsecase) loadKeys(ctx context.Context) (int, error) {
+	rows, err := u.jwksRepository.FindKey(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	keys := make([]*auth.Key, 0, len(rows))
+	for _, row := range rows {
+		public, err := auth.DecodePublicKey(row.PublicKey)
+		if err != nil {
+			log.Printf("load jwt key %s failed: %v", row.Kid, err)
+			continue
+		}
+		// A key that cannot be decrypted, after JWT_SECRET_KEY changed, still verifies
+		private, err := auth.DecodePrivateKey(u.cfg.Jwt().SecretKey(), row.PrivateKey)
+		if err != nil {
+			log.Printf("load jwt private key %s failed: %v", row.Kid, err)
+			private = nil
+		}
+		keys = append(keys, &auth.Key{
+			Kid:        row.Kid,
+			Alg:        row.Alg,
+			Private:    private,
+			Public:     public,
+			ActiveFrom: row.ActiveFrom,
+			ExpiresAt:  row.ExpiresAt,
+		})
+	}
+	u.keys.Set(keys)
+	return len(keys), nil
+}
+
+func (u *jwksUsecase) FindJwks() *auth.Jwks {
+	return u.keys.Jwks()
+}
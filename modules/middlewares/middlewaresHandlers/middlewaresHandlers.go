@@ -38,12 +38,14 @@ type IMiddlewaresHandler interface {
 
 type middlewaresHandler struct {
 	cfg                config.IConfig
+	keys               *auth.KeySet
 	middlewaresUsecase middlewaresUsecases.IMiddlewaresUsecase
 }
 
-func MiddlewaresHandler(cfg config.IConfig, middlewaresUsecase middlewaresUsecases.IMiddlewaresUsecase) IMiddlewaresHandler {
+func MiddlewaresHandler(cfg config.IConfig, keys *auth.KeySet, middlewaresUsecase middlewaresUsecases.IMiddlewaresUsecase) IMiddlewaresHandler {
 	return &middlewaresHandler{
 		cfg:                cfg,
+		keys:               keys,
 		middlewaresUsecase: middlewaresUsecase,
 	}
 }
@@ -80,7 +82,7 @@ func (h *middlewaresHandler) Logger() fiber.Handler {
 func (h *middlewaresHandler) JwtAuth() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		token := strings.TrimPrefix(c.Get("Authorization"), "Bearer ")
-		result, err := auth.ParseToken(h.cfg.Jwt(), token)
+		result, err := auth.ParseToken(h.cfg.Jwt(), h.keys, token)
 		if err != nil {
 			return entities.NewResponse(c).Error(
 				fiber.ErrUnauthorized.Code,
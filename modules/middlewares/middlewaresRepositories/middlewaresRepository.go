@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/LGROW101/lgrow-shop/modules/middlewares"
+	"github.com/LGROW101/lgrow-shop/pkg/auth"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -27,13 +28,14 @@ func (r *middlewaresRepository) FindAccessToken(userId, accessToken string) bool
 		(CASE WHEN COUNT(*) =1 THEN TRUE ELSE FALSE END)
 		FROM "oauth" 
 		WHERE "user_id" = $1 
-		AND "access_token" = $2;`
+		AND "access_token_hash" = $2;`
 
+	// A revoked session has no row, its access token stops working right away
 	var check bool
-	if err := r.db.Get(&check, query, userId, accessToken); err != nil {
+	if err := r.db.Get(&check, query, userId, auth.HashToken(accessToken)); err != nil {
 		return false
 	}
-	return true
+	return check
 }
 func (r *middlewaresRepository) FindRole() ([]*middlewares.Role, error) {
 	query := `
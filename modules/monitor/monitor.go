@@ -1,6 +1,9 @@
 package monitor
 
+import "github.com/LGROW101/lgrow-shop/pkg/scheduler"
+
 type Monitor struct {
-	Name    string `json:"name"`
-	Version string `json:"version"`
+	Name    string                 `json:"name"`
+	Version string                 `json:"version"`
+	Jobs    []*scheduler.JobStatus `json:"jobs,omitempty"`
 }
@@ -4,6 +4,7 @@ import (
 	"github.com/LGROW101/lgrow-shop/config"
 	"github.com/LGROW101/lgrow-shop/modules/entities"
 	"github.com/LGROW101/lgrow-shop/modules/monitor"
+	"github.com/LGROW101/lgrow-shop/pkg/scheduler"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -12,18 +13,21 @@ type IMonitorHandler interface {
 }
 
 type monitorHandler struct {
-	cfg config.IConfig
+	cfg       config.IConfig
+	scheduler scheduler.IScheduler
 }
 
-func MonitorHandler(cfg config.IConfig) IMonitorHandler {
+func MonitorHandler(cfg config.IConfig, scheduler scheduler.IScheduler) IMonitorHandler {
 	return &monitorHandler{
-		cfg: cfg,
+		cfg:       cfg,
+		scheduler: scheduler,
 	}
 }
 func (h *monitorHandler) HealthCheck(c *fiber.Ctx) error {
 	res := &monitor.Monitor{
 		Name:    h.cfg.App().Name(),
 		Version: h.cfg.App().Version(),
+		Jobs:    h.scheduler.Status(),
 	}
 	return entities.NewResponse(c).Success(fiber.StatusOK, res).Res()
 }
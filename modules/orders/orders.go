@@ -1,12 +1,19 @@
 package orders
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
 	"github.com/LGROW101/lgrow-shop/modules/entities"
 	"github.com/LGROW101/lgrow-shop/modules/products"
 )
 
 type OrderFilter struct {
-	Search    string `query:"search"` // user_id, address, contact
+	Search    string `query:"search"` // user_id or guest email, address, contact, notes
 	Status    string `query:"status"`
 	StartDate string `query:"start_date"`
 	EndDate   string `query:"end_date"`
@@ -15,23 +22,96 @@ type OrderFilter struct {
 }
 
 type Order struct {
-	Id           string           `db:"id" json:"id"`
-	UserId       string           `db:"user_id" json:"user_id"`
-	TransferSlip *TransferSlip    `db:"transfer_slip" json:"transfer_slip"`
-	Products     []*ProductsOrder `json:"products"`
-	Address      string           `db:"address" json:"address"`
-	Contact      string           `db:"contact" json:"contact"`
-	Status       string           `db:"status" json:"status"`
-	TotalPaid    float64          `db:"total_paid" json:"total_paid"`
-	CreatedAt    string           `db:"created_at" json:"created_at"`
-	UpdatedAt    string           `db:"updated_at" json:"updated_at"`
+	Id              string           `db:"id" json:"id"`
+	UserId          string           `db:"user_id" json:"user_id"`
+	GuestEmail      string           `db:"guest_email" json:"guest_email,omitempty"`
+	GuestToken      string           `db:"guest_token" json:"-"` // sha256 of the access token
+	TransferSlip    *TransferSlip    `db:"transfer_slip" json:"transfer_slip"`
+	Products        []*ProductsOrder `json:"products"`
+	Shipments       []*Shipment      `json:"shipments"`
+	AddressId       string           `json:"address_id,omitempty"`
+	Address         string           `db:"address" json:"address"`
+	ShippingAddress *OrderAddress    `db:"shipping_address" json:"shipping_address"`
+	Contact         string           `db:"contact" json:"contact"`
+	Shipping        *OrderShipping   `db:"shipping" json:"shipping"`
+	ShippingFee     float64          `db:"shipping_fee" json:"shipping_fee"`
+	Status          string           `db:"status" json:"status"`
+	GiftCardCode    string           `json:"gift_card_code,omitempty"`
+	UseStoreCredit  bool             `json:"use_store_credit,omitempty"`
+	CreditUsed      float64          `db:"credit_used" json:"credit_used"` // paid with gift cards and store credit
+	TotalPaid       float64          `db:"total_paid" json:"total_paid"`   // left to pay
+	TotalRefunded   float64          `db:"total_refunded" json:"total_refunded"`
+	Histories       []*OrderHistory  `json:"histories"`
+	Notes           []*OrderNote     `json:"notes"`
+	CreatedAt       string           `db:"created_at" json:"created_at"`
+	UpdatedAt       string           `db:"updated_at" json:"updated_at"`
 }
 
 type TransferSlip struct {
-	Id        string `json:"id"`
-	FileName  string `json:"filename"`
-	Url       string `json:"url"`
-	CreatedAt string `json:"created_at"`
+	Id            string  `db:"id" json:"id"`
+	OrderId       string  `db:"order_id" json:"order_id,omitempty"`
+	FileName      string  `db:"filename" json:"filename"`
+	Url           string  `db:"url" json:"url"`
+	Destination   string  `db:"destination" json:"-"`
+	Amount        float64 `db:"amount" json:"amount"`
+	TransferredAt string  `db:"transferred_at" json:"transferred_at"`
+	SendingBank   string  `db:"sending_bank" json:"sending_bank"`
+	TransRef      string  `db:"trans_ref" json:"trans_ref"`
+	Status        string  `db:"status" json:"status"` // pending | approved | rejected
+	Reason        string  `db:"reason" json:"reason"`
+	ReviewedBy    *string `db:"reviewed_by" json:"reviewed_by"`
+	ReviewedAt    *string `db:"reviewed_at" json:"reviewed_at"`
+	CreatedAt     string  `db:"created_at" json:"created_at"`
+}
+
+type TransferSlipFilter struct {
+	Status string `query:"status"`
+}
+
+// TransferSlipReview is a slip in the admin queue with the order total to reconcile against
+type TransferSlipReview struct {
+	*TransferSlip
+	UserId       string  `json:"user_id"`
+	TotalPaid    float64 `json:"total_paid"`
+	Difference   float64 `json:"difference"`    // amount - total_paid
+	QrUnreadable bool    `json:"qr_unreadable"` // no transaction reference, not checked for reuse
+}
+
+type ReviewTransferSlipReq struct {
+	Id         string `json:"id"`
+	Status     string `json:"status" form:"status"` // approved | rejected
+	Reason     string `json:"reason" form:"reason"`
+	ReviewedBy string `json:"reviewed_by"`
+}
+
+// OrderAddress is a snapshot of the address book entry used by the order
+type OrderAddress struct {
+	Recipient   string `json:"recipient"`
+	Phone       string `json:"phone"`
+	Line1       string `json:"line1"`
+	Line2       string `json:"line2"`
+	Subdistrict string `json:"subdistrict"`
+	District    string `json:"district"`
+	Province    string `json:"province"`
+	Postcode    string `json:"postcode"`
+}
+
+// String joins the address lines into the free text format of Order.Address
+func (obj *OrderAddress) String() string {
+	lines := make([]string, 0)
+	for _, v := range []string{
+		obj.Line1,
+		obj.Line2,
+		obj.Subdistrict,
+		obj.District,
+		obj.Province,
+		obj.Postcode,
+	} {
+		if strings.TrimSpace(v) != "" {
+			lines = append(lines, strings.TrimSpace(v))
+		}
+	}
+	return strings.Join(lines, " ")
 }
 
 type ProductsOrder struct {
@@ -39,3 +119,162 @@ type ProductsOrder struct {
 	Qty     int               `db:"qty" json:"qty"`
 	Product *products.Product `db:"product" json:"product"`
 }
+
+type OrderShipping struct {
+	MethodId int     `json:"method_id"`
+	ZoneId   int     `json:"zone_id"`
+	Title    string  `json:"title"`
+	Type     string  `json:"type"`
+	Province string  `json:"province"`
+	Postcode string  `json:"postcode"`
+	Fee      float64 `json:"fee"`
+}
+
+type ShippingQuoteReq struct {
+	Products []*ProductsOrder `json:"products"`
+	Province string           `json:"province"`
+	Postcode string           `json:"postcode"`
+}
+
+type Shipment struct {
+	Id             string          `db:"id" json:"id"`
+	OrderId        string          `db:"order_id" json:"order_id"`
+	Carrier        string          `db:"carrier" json:"carrier"`
+	TrackingNumber string          `db:"tracking_number" json:"tracking_number"`
+	Items          []*ShipmentItem `json:"items"`
+	ShippedAt      string          `db:"shipped_at" json:"shipped_at"`
+	DeliveredAt    *string         `db:"delivered_at" json:"delivered_at"`
+	CreatedAt      string          `db:"created_at" json:"created_at"`
+}
+
+type ShipmentItem struct {
+	Id              string `db:"id" json:"id"`
+	ProductsOrderId string `db:"products_order_id" json:"products_order_id"`
+	Qty             int    `db:"qty" json:"qty"`
+}
+
+type OrderHistory struct {
+	Id        string `db:"id" json:"id"`
+	Status    string `db:"status" json:"status"`
+	Note      string `db:"note" json:"note"`
+	CreatedAt string `db:"created_at" json:"created_at"`
+}
+
+type OrderNote struct {
+	Id        string `db:"id" json:"id"`
+	OrderId   string `db:"order_id" json:"order_id"`
+	UserId    string `db:"user_id" json:"user_id"`
+	Username  string `db:"username" json:"username"`
+	Internal  bool   `db:"internal" json:"internal"` // admin only
+	Note      string `db:"note" json:"note"`
+	CreatedAt string `db:"created_at" json:"created_at"`
+}
+
+func (obj *Order) IsGuestEmail() bool {
+	match, err := regexp.MatchString(`^[\w-\.]+@([\w-]+\.)+[\w-]{2,4}$`, obj.GuestEmail)
+	if err != nil {
+		return false
+	}
+	return match
+}
+
+// GuestCheckout is returned once, only the hash of the access token is stored
+type GuestCheckout struct {
+	Order       *Order `json:"order"`
+	AccessToken string `json:"access_token"`
+}
+
+// NewGuestToken returns a random order access token, only auth.HashToken of it is kept
+func NewGuestToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate guest token failed: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// IsTransitionValid reports whether the order may move from the current status to next,
+// paid is only set by a succeeded payment and customers can only cancel a waiting order
+//
+// waiting   -> canceled
+// paid      -> shipping | completed | canceled (admin)
+// shipping  -> completed | canceled (admin)
+// completed -> canceled (admin)
+func IsTransitionValid(current, next string, isAdmin bool) bool {
+	if !isAdmin {
+		return current == "waiting" && next == "canceled"
+	}
+	transitionMap := map[string][]string{
+		"waiting":   {"canceled"},
+		"paid":      {"shipping", "completed", "canceled"},
+		"shipping":  {"completed", "canceled"},
+		"completed": {"canceled"},
+	}
+	for _, s := range transitionMap[current] {
+		if s == next {
+			return true
+		}
+	}
+	return false
+}
+
+// HideInternalNotes drops the admin comments before the order goes to a customer
+func (obj *Order) HideInternalNotes() {
+	notes := make([]*OrderNote, 0)
+	for _, n := range obj.Notes {
+		if !n.Internal {
+			notes = append(notes, n)
+		}
+	}
+	obj.Notes = notes
+}
+
+// ReorderReq optionally replaces the address and shipping method of the previous order
+type ReorderReq struct {
+	AddressId string         `json:"address_id"`
+	Shipping  *OrderShipping `json:"shipping"`
+}
+
+type Reorder struct {
+	Order        *Order         `json:"order"`
+	Unavailable  []*ReorderItem `json:"unavailable"`
+	PriceChanged []*ReorderItem `json:"price_changed"`
+}
+
+type ReorderItem struct {
+	ProductId string  `json:"product_id"`
+	Title     string  `json:"title"`
+	Qty       int     `json:"qty"`
+	OldPrice  float64 `json:"old_price"`
+	Price     float64 `json:"price"`
+	Reason    string  `json:"reason,omitempty"`
+}
+
+type PaymentQR struct {
+	OrderId string  `json:"order_id"`
+	Amount  float64 `json:"amount"`
+	Payload string  `json:"payload"`
+	Image   []byte  `json:"image"` // PNG, base64 in json
+}
+
+type Invoice struct {
+	Id         string `db:"id" json:"id"`
+	OrderId    string `db:"order_id" json:"order_id"`
+	FiscalYear int    `db:"fiscal_year" json:"fiscal_year"`
+	Number     int    `db:"number" json:"number"`
+	InvoiceNo  string `db:"invoice_no" json:"invoice_no"`
+	IssuedAt   string `db:"issued_at" json:"issued_at"`
+}
+
+// FiscalYear is named after the calendar year it ends in, a year starting in October 2025 is 2026
+func FiscalYear(t time.Time, start time.Month) int {
+	if start > time.January && t.Month() >= start {
+		return t.Year() + 1
+	}
+	return t.Year()
+}
+
+// InvoiceNo formats the running number of a fiscal year, e.g. INV2026-000001
+func InvoiceNo(fiscalYear, number int) string {
+	return fmt.Sprintf("INV%d-%06d", fiscalYear, number)
+}
@@ -1,24 +1,42 @@
 package ordersHandlers
 
 import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/LGROW101/lgrow-shop/config"
 	"github.com/LGROW101/lgrow-shop/modules/entities"
+	"github.com/LGROW101/lgrow-shop/modules/files"
 	"github.com/LGROW101/lgrow-shop/modules/orders"
 	"github.com/LGROW101/lgrow-shop/modules/orders/ordersUsecases"
+	"github.com/LGROW101/lgrow-shop/pkg/utils"
 	"github.com/gofiber/fiber/v2"
-	"github.com/google/uuid"
 )
 
 type ordersHandlersErrCode string
 
 const (
-	findOneOrderErr ordersHandlersErrCode = "orders-001"
-	findOrderErr    ordersHandlersErrCode = "orders-002"
-	insertOrderErr  ordersHandlersErrCode = "orders-003"
-	updateOrderErr  ordersHandlersErrCode = "orders-004"
+	findOneOrderErr       ordersHandlersErrCode = "orders-001"
+	findOrderErr          ordersHandlersErrCode = "orders-002"
+	insertOrderErr        ordersHandlersErrCode = "orders-003"
+	updateOrderErr        ordersHandlersErrCode = "orders-004"
+	shippingQuoteErr      ordersHandlersErrCode = "orders-005"
+	insertShipmentErr     ordersHandlersErrCode = "orders-006"
+	deliverShipmentErr    ordersHandlersErrCode = "orders-007"
+	paymentQRErr          ordersHandlersErrCode = "orders-008"
+	uploadTransferSlipErr ordersHandlersErrCode = "orders-009"
+	findTransferSlipErr   ordersHandlersErrCode = "orders-010"
+	transferSlipFileErr   ordersHandlersErrCode = "orders-011"
+	reviewTransferSlipErr ordersHandlersErrCode = "orders-012"
+	invoiceErr            ordersHandlersErrCode = "orders-013"
+	insertNoteErr         ordersHandlersErrCode = "orders-014"
+	reorderErr            ordersHandlersErrCode = "orders-015"
+	insertGuestOrderErr   ordersHandlersErrCode = "orders-016"
+	findGuestOrderErr     ordersHandlersErrCode = "orders-017"
 )
 
 type IOrdersHandler interface {
@@ -26,6 +44,21 @@ type IOrdersHandler interface {
 	FindOrder(c *fiber.Ctx) error
 	InsertOrder(c *fiber.Ctx) error
 	UpdateOrder(c *fiber.Ctx) error
+	ShippingQuote(c *fiber.Ctx) error
+	InsertShipment(c *fiber.Ctx) error
+	DeliverShipment(c *fiber.Ctx) error
+	PaymentQR(c *fiber.Ctx) error
+	GuestPaymentQR(c *fiber.Ctx) error
+	UploadTransferSlip(c *fiber.Ctx) error
+	GuestUploadTransferSlip(c *fiber.Ctx) error
+	FindTransferSlip(c *fiber.Ctx) error
+	TransferSlipFile(c *fiber.Ctx) error
+	ReviewTransferSlip(c *fiber.Ctx) error
+	Invoice(c *fiber.Ctx) error
+	InsertNote(c *fiber.Ctx) error
+	Reorder(c *fiber.Ctx) error
+	InsertGuestOrder(c *fiber.Ctx) error
+	FindGuestOrder(c *fiber.Ctx) error
 }
 
 type ordersHandler struct {
@@ -43,7 +76,7 @@ func OrdersHandler(cfg config.IConfig, ordersUsecase ordersUsecases.IOrdersUseca
 func (h *ordersHandler) FindOneOrder(c *fiber.Ctx) error {
 	orderId := strings.Trim(c.Params("order_id"), " ")
 
-	order, err := h.ordersUsecase.FindOneOrder(orderId)
+	order, err := h.ordersUsecase.FindOneOrder(orderId, c.Locals("userRoleId").(int) == 2)
 	if err != nil {
 		return entities.NewResponse(c).Error(
 			fiber.ErrInternalServerError.Code,
@@ -150,19 +183,33 @@ func (h *ordersHandler) InsertOrder(c *fiber.Ctx) error {
 
 	req.Status = "waiting"
 	req.TotalPaid = 0
+	// Structured address only comes from the address book
+	req.ShippingAddress = nil
+	req.TransferSlip = nil
+	req.GuestEmail = ""
 
 	order, err := h.ordersUsecase.InsertOrder(req)
 	if err != nil {
-		return entities.NewResponse(c).Error(
-			fiber.ErrInternalServerError.Code,
-			string(insertOrderErr),
-			err.Error(),
-		).Res()
+		switch err.Error() {
+		case "gift card not found", "gift card has expired", "gift card has no balance":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(insertOrderErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(insertOrderErr),
+				err.Error(),
+			).Res()
+		}
 	}
 	return entities.NewResponse(c).Success(fiber.StatusCreated, order).Res()
 }
 
 func (h *ordersHandler) UpdateOrder(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
 	orderId := strings.Trim(c.Params("order_id"), " ")
 	req := new(orders.Order)
 	if err := c.BodyParser(req); err != nil {
@@ -180,40 +227,636 @@ func (h *ordersHandler) UpdateOrder(c *fiber.Ctx) error {
 		"completed": "completed",
 		"canceled":  "canceled",
 	}
-	if c.Locals("userRoleId").(int) == 2 {
+	isAdmin := c.Locals("userRoleId").(int) == 2
+	if isAdmin {
 		req.Status = statusMap[strings.ToLower(req.Status)]
-	} else if strings.ToLower(req.Status) == statusMap["canceled"] {
-		req.Status = statusMap["canceled"]
+	} else {
+		// Customers can only cancel
+		if strings.ToLower(req.Status) == statusMap["canceled"] {
+			req.Status = statusMap["canceled"]
+		} else {
+			req.Status = ""
+		}
+		// Only admins can edit items, address and contact
+		req.Products = nil
+		req.AddressId = ""
+		req.Address = ""
+		req.Contact = ""
+	}
+	for _, p := range req.Products {
+		if p.Product == nil || p.Product.Id == "" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(updateOrderErr),
+				"product id is required",
+			).Res()
+		}
+	}
+	req.Address = strings.TrimSpace(req.Address)
+	req.Contact = strings.TrimSpace(req.Contact)
+
+	// Slips go through the transfer slip verification instead
+	req.TransferSlip = nil
+
+	order, err := h.ordersUsecase.UpdateOrder(userId, req, isAdmin)
+	if err != nil {
+		switch {
+		case err.Error() == "order not found",
+			err.Error() == "order has not been paid",
+			strings.HasPrefix(err.Error(), "cannot change order status"),
+			err.Error() == "order can only be edited while waiting",
+			err.Error() == "address not found",
+			err.Error() == "qty must more than 0",
+			strings.HasPrefix(err.Error(), "shipping method"),
+			strings.HasSuffix(err.Error(), "is out of stock"):
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(updateOrderErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(updateOrderErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	if !isAdmin {
+		order.HideInternalNotes()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, order).Res()
+}
+
+func (h *ordersHandler) ShippingQuote(c *fiber.Ctx) error {
+	req := &orders.ShippingQuoteReq{
+		Products: make([]*orders.ProductsOrder, 0),
+	}
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(shippingQuoteErr),
+			err.Error(),
+		).Res()
+	}
+	if len(req.Products) == 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(shippingQuoteErr),
+			"products are empty",
+		).Res()
+	}
+	if strings.TrimSpace(req.Province) == "" && strings.TrimSpace(req.Postcode) == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(shippingQuoteErr),
+			"province or postcode is required",
+		).Res()
 	}
 
-	if req.TransferSlip != nil {
-		if req.TransferSlip.Id == "" {
-			req.TransferSlip.Id = uuid.NewString()
+	quotes, err := h.ordersUsecase.ShippingQuote(req)
+	if err != nil {
+		switch err.Error() {
+		case "shipping zone not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(shippingQuoteErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(shippingQuoteErr),
+				err.Error(),
+			).Res()
 		}
-		if req.TransferSlip.CreatedAt == "" {
-			loc, err := time.LoadLocation("Asia/Bangkok")
-			if err != nil {
-				return entities.NewResponse(c).Error(
-					fiber.ErrInternalServerError.Code,
-					string(updateOrderErr),
-					err.Error(),
-				).Res()
-			}
-			now := time.Now().In(loc)
-
-			// YYYY-MM-DD HH:MM:SS
-			// 2006-01-02 15:04:05
-			req.TransferSlip.CreatedAt = now.Format("2006-01-02 15:04:05")
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, quotes).Res()
+}
+
+func (h *ordersHandler) InsertShipment(c *fiber.Ctx) error {
+	orderId := strings.Trim(c.Params("order_id"), " ")
+
+	req := &orders.Shipment{
+		Items: make([]*orders.ShipmentItem, 0),
+	}
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertShipmentErr),
+			err.Error(),
+		).Res()
+	}
+	req.OrderId = orderId
+
+	if strings.TrimSpace(req.Carrier) == "" || strings.TrimSpace(req.TrackingNumber) == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertShipmentErr),
+			"carrier and tracking number are required",
+		).Res()
+	}
+	if len(req.Items) == 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertShipmentErr),
+			"items are empty",
+		).Res()
+	}
+
+	// YYYY-MM-DD HH:MM:SS
+	if req.ShippedAt != "" {
+		if _, err := time.Parse("2006-01-02 15:04:05", req.ShippedAt); err != nil {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(insertShipmentErr),
+				"shipped at is invalid",
+			).Res()
+		}
+	}
+
+	order, err := h.ordersUsecase.InsertShipment(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertShipmentErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, order).Res()
+}
+
+func (h *ordersHandler) DeliverShipment(c *fiber.Ctx) error {
+	orderId := strings.Trim(c.Params("order_id"), " ")
+	shipmentId := strings.Trim(c.Params("shipment_id"), " ")
+
+	order, err := h.ordersUsecase.DeliverShipment(orderId, shipmentId)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(deliverShipmentErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, order).Res()
+}
+
+func (h *ordersHandler) PaymentQR(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+	orderId := strings.Trim(c.Params("order_id"), " ")
+
+	qr, err := h.ordersUsecase.PaymentQR(userId, orderId)
+	return paymentQRRes(c, qr, err)
+}
+
+func (h *ordersHandler) GuestPaymentQR(c *fiber.Ctx) error {
+	orderId := strings.Trim(c.Params("order_id"), " ")
+
+	token := guestToken(c)
+	if token == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(paymentQRErr),
+			"token is required",
+		).Res()
+	}
+
+	qr, err := h.ordersUsecase.GuestPaymentQR(orderId, token)
+	return paymentQRRes(c, qr, err)
+}
+
+func paymentQRRes(c *fiber.Ctx, qr *orders.PaymentQR, err error) error {
+	if err != nil {
+		switch err.Error() {
+		case "order not found", "order is not waiting for payment":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(paymentQRErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(paymentQRErr),
+				err.Error(),
+			).Res()
 		}
 	}
 
-	order, err := h.ordersUsecase.UpdateOrder(req)
+	// ?format=png returns only the image
+	if strings.ToLower(c.Query("format")) == "png" {
+		c.Set(fiber.HeaderContentType, "image/png")
+		return c.Status(fiber.StatusOK).Send(qr.Image)
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, qr).Res()
+}
+
+func (h *ordersHandler) UploadTransferSlip(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+	orderId := strings.Trim(c.Params("order_id"), " ")
+
+	req, file, err := h.transferSlipReq(c, orderId)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(uploadTransferSlipErr),
+			err.Error(),
+		).Res()
+	}
+
+	order, err := h.ordersUsecase.UploadTransferSlip(userId, req, file)
+	if err != nil {
+		return uploadTransferSlipErrRes(c, err)
+	}
+	if c.Locals("userRoleId").(int) != 2 {
+		order.HideInternalNotes()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, order).Res()
+}
+
+func (h *ordersHandler) GuestUploadTransferSlip(c *fiber.Ctx) error {
+	orderId := strings.Trim(c.Params("order_id"), " ")
+
+	token := guestToken(c)
+	if token == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(uploadTransferSlipErr),
+			"token is required",
+		).Res()
+	}
+
+	req, file, err := h.transferSlipReq(c, orderId)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(uploadTransferSlipErr),
+			err.Error(),
+		).Res()
+	}
+
+	order, err := h.ordersUsecase.GuestUploadTransferSlip(token, req, file)
+	if err != nil {
+		return uploadTransferSlipErrRes(c, err)
+	}
+	order.HideInternalNotes()
+	return entities.NewResponse(c).Success(fiber.StatusCreated, order).Res()
+}
+
+// transferSlipReq reads the slip image, amount and transfer time of the multipart form
+func (h *ordersHandler) transferSlipReq(c *fiber.Ctx, orderId string) (*orders.TransferSlip, *files.FileReq, error) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		return nil, nil, fmt.Errorf("file is required")
+	}
+
+	// Files ext validation
+	extMap := map[string]string{
+		"png":  "png",
+		"jpg":  "jpg",
+		"jpeg": "jpeg",
+	}
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(file.Filename), "."))
+	if extMap[ext] == "" {
+		return nil, nil, fmt.Errorf("extension is not acceptable")
+	}
+	if file.Size > int64(h.cfg.App().FileLimit()) {
+		return nil, nil, fmt.Errorf("file size must less than %d MiB", int(math.Ceil(float64(h.cfg.App().FileLimit())/math.Pow(1024, 2))))
+	}
+
+	amount, err := strconv.ParseFloat(c.FormValue("amount"), 64)
+	if err != nil || amount <= 0 {
+		return nil, nil, fmt.Errorf("amount is invalid")
+	}
+
+	// YYYY-MM-DD HH:MM:SS
+	transferredAt := c.FormValue("transferred_at")
+	if _, err := time.Parse("2006-01-02 15:04:05", transferredAt); err != nil {
+		return nil, nil, fmt.Errorf("transferred at is invalid")
+	}
+
+	filename := utils.RandFileName(ext)
+	req := &orders.TransferSlip{
+		OrderId:       orderId,
+		Amount:        amount,
+		TransferredAt: transferredAt,
+	}
+	fileReq := &files.FileReq{
+		File:        file,
+		Destination: fmt.Sprintf("transfer-slips/%s/%s", orderId, filename),
+		FileName:    filename,
+		Extension:   ext,
+	}
+	return req, fileReq, nil
+}
+
+func uploadTransferSlipErrRes(c *fiber.Ctx, err error) error {
+	switch err.Error() {
+	case "order not found", "order is not waiting for payment", "transfer slip is waiting for verification", "transfer slip has been used", "transfer slip qr is unreadable":
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(uploadTransferSlipErr),
+			err.Error(),
+		).Res()
+	default:
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(uploadTransferSlipErr),
+			err.Error(),
+		).Res()
+	}
+}
+
+func (h *ordersHandler) FindTransferSlip(c *fiber.Ctx) error {
+	req := new(orders.TransferSlipFilter)
+	if err := c.QueryParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(findTransferSlipErr),
+			err.Error(),
+		).Res()
+	}
+
+	statusMap := map[string]string{
+		"pending":  "pending",
+		"approved": "approved",
+		"rejected": "rejected",
+	}
+	req.Status = statusMap[strings.ToLower(req.Status)]
+	if req.Status == "" {
+		req.Status = statusMap["pending"]
+	}
+
+	slips, err := h.ordersUsecase.FindTransferSlip(req)
 	if err != nil {
 		return entities.NewResponse(c).Error(
 			fiber.ErrInternalServerError.Code,
-			string(updateOrderErr),
+			string(findTransferSlipErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, slips).Res()
+}
+
+func (h *ordersHandler) TransferSlipFile(c *fiber.Ctx) error {
+	slipId := strings.Trim(c.Params("slip_id"), " ")
+
+	slip, b, err := h.ordersUsecase.TransferSlipFile(slipId)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(transferSlipFileErr),
+			err.Error(),
+		).Res()
+	}
+
+	c.Type(strings.TrimPrefix(filepath.Ext(slip.FileName), "."))
+	return c.Status(fiber.StatusOK).Send(b)
+}
+
+func (h *ordersHandler) ReviewTransferSlip(c *fiber.Ctx) error {
+	req := new(orders.ReviewTransferSlipReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(reviewTransferSlipErr),
+			err.Error(),
+		).Res()
+	}
+	req.Id = strings.Trim(c.Params("slip_id"), " ")
+	req.ReviewedBy = c.Locals("userId").(string)
+	req.Status = strings.ToLower(req.Status)
+	req.Reason = strings.TrimSpace(req.Reason)
+
+	if req.Status != "approved" && req.Status != "rejected" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(reviewTransferSlipErr),
+			"status is invalid",
+		).Res()
+	}
+	if req.Status == "rejected" && req.Reason == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(reviewTransferSlipErr),
+			"reason is required",
+		).Res()
+	}
+
+	order, err := h.ordersUsecase.ReviewTransferSlip(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(reviewTransferSlipErr),
 			err.Error(),
 		).Res()
 	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, order).Res()
+}
+
+func (h *ordersHandler) Invoice(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+	orderId := strings.Trim(c.Params("order_id"), " ")
+
+	invoice, file, err := h.ordersUsecase.Invoice(userId, orderId)
+	if err != nil {
+		switch err.Error() {
+		case "order not found", "order has not been paid":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(invoiceErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(invoiceErr),
+				err.Error(),
+			).Res()
+		}
+	}
+
+	c.Type("pdf")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("inline; filename=\"%s.pdf\"", invoice.InvoiceNo))
+	return c.Status(fiber.StatusOK).Send(file)
+}
+
+func (h *ordersHandler) InsertNote(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+	isAdmin := c.Locals("userRoleId").(int) == 2
+
+	req := new(orders.OrderNote)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertNoteErr),
+			err.Error(),
+		).Res()
+	}
+	req.OrderId = strings.Trim(c.Params("order_id"), " ")
+	req.UserId = c.Locals("userId").(string)
+	req.Note = strings.TrimSpace(req.Note)
+
+	if req.Note == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertNoteErr),
+			"note is required",
+		).Res()
+	}
+	// Customers can only leave notes they see
+	if !isAdmin {
+		req.Internal = false
+	}
+
+	order, err := h.ordersUsecase.InsertNote(userId, req, isAdmin)
+	if err != nil {
+		switch err.Error() {
+		case "order not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(insertNoteErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(insertNoteErr),
+				err.Error(),
+			).Res()
+		}
+	}
 	return entities.NewResponse(c).Success(fiber.StatusCreated, order).Res()
 }
+
+func (h *ordersHandler) Reorder(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+	orderId := strings.Trim(c.Params("order_id"), " ")
+
+	req := new(orders.ReorderReq)
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(req); err != nil {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(reorderErr),
+				err.Error(),
+			).Res()
+		}
+	}
+
+	res, err := h.ordersUsecase.Reorder(userId, orderId, req)
+	if err != nil {
+		switch err.Error() {
+		case "order not found", "no products available to reorder", "address not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(reorderErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(reorderErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, res).Res()
+}
+
+func (h *ordersHandler) InsertGuestOrder(c *fiber.Ctx) error {
+	req := &orders.Order{
+		Products: make([]*orders.ProductsOrder, 0),
+	}
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertGuestOrderErr),
+			err.Error(),
+		).Res()
+	}
+	if len(req.Products) == 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertGuestOrderErr),
+			"products are empty",
+		).Res()
+	}
+
+	req.GuestEmail = strings.ToLower(strings.TrimSpace(req.GuestEmail))
+	if !req.IsGuestEmail() {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertGuestOrderErr),
+			"email pattern is invalid",
+		).Res()
+	}
+	req.Address = strings.TrimSpace(req.Address)
+	req.Contact = strings.TrimSpace(req.Contact)
+	if req.Address == "" || req.Contact == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertGuestOrderErr),
+			"address and contact are required",
+		).Res()
+	}
+
+	// Guests have no account or address book
+	req.UserId = ""
+	req.AddressId = ""
+	req.UseStoreCredit = false // guests have no store credit
+	req.Status = "waiting"
+	req.TotalPaid = 0
+	req.ShippingAddress = nil
+	req.TransferSlip = nil
+
+	res, err := h.ordersUsecase.InsertGuestOrder(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(insertGuestOrderErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, res).Res()
+}
+
+func (h *ordersHandler) FindGuestOrder(c *fiber.Ctx) error {
+	orderId := strings.Trim(c.Params("order_id"), " ")
+
+	token := guestToken(c)
+	if token == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(findGuestOrderErr),
+			"token is required",
+		).Res()
+	}
+
+	order, err := h.ordersUsecase.FindGuestOrder(orderId, token)
+	if err != nil {
+		switch err.Error() {
+		case "order not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(findGuestOrderErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(findGuestOrderErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, order).Res()
+}
+
+// guestToken is the access token of a guest order, X-Order-Token header or ?token= for links in emails
+func guestToken(c *fiber.Ctx) string {
+	if token := c.Get("X-Order-Token"); token != "" {
+		return token
+	}
+	return c.Query("token")
+}
@@ -0,0 +1,383 @@
+package ordersPatterns
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/LGROW101/lgrow-shop/modules/credits/creditsPatterns"
+	"github.com/LGROW101/lgrow-shop/modules/orders"
+	"github.com/jmoiron/sqlx"
+)
+
+// ShippingFeeFunc calculates the fee of the order shipping method from the subtotal and weight (kg)
+type ShippingFeeFunc func(subtotal, weight float64) float64
+
+type IEditOrderBuilder interface {
+	initTransaction() error
+	checkOrder() error
+	updateItems() error
+	updateAddress() error
+	updateShipping() error
+	cancelPayments() error
+	adjustCredits() error
+	insertHistory() error
+	commit() error
+}
+
+type editOrderBuilder struct {
+	db       *sqlx.DB
+	req      *orders.Order
+	fee      ShippingFeeFunc
+	tx       *sqlx.Tx
+	oldTotal float64
+	newTotal float64
+	changes  []string
+}
+
+type editOrderEngineer struct {
+	builder IEditOrderBuilder
+}
+
+// EditOrderBuilder replaces the items (when req.Products is not empty), address and contact of a waiting order,
+// fee is nil when the order has no shipping method. Credit above the new total is returned
+func EditOrderBuilder(db *sqlx.DB, req *orders.Order, fee ShippingFeeFunc) IEditOrderBuilder {
+	return &editOrderBuilder{
+		db:      db,
+		req:     req,
+		fee:     fee,
+		changes: make([]string, 0),
+	}
+}
+
+func EditOrderEngineer(b IEditOrderBuilder) *editOrderEngineer {
+	return &editOrderEngineer{builder: b}
+}
+
+const queryOrderTotal = `
+	SELECT
+		COALESCE((
+			SELECT
+				SUM(("po"."product"->>'price')::FLOAT * "po"."qty")
+			FROM "products_orders" "po"
+			WHERE "po"."order_id" = "o"."id"
+		), 0) + "o"."shipping_fee"
+	FROM "orders" "o"
+	WHERE "o"."id" = $1;`
+
+func (b *editOrderBuilder) initTransaction() error {
+	tx, err := b.db.BeginTxx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	b.tx = tx
+	return nil
+}
+func (b *editOrderBuilder) checkOrder() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	var status string
+	if err := b.tx.QueryRowxContext(ctx, `SELECT "status" FROM "orders" WHERE "id" = $1 FOR UPDATE;`, b.req.Id).Scan(&status); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("order not found")
+	}
+	if status != "waiting" {
+		b.tx.Rollback()
+		return fmt.Errorf("order can only be edited while waiting")
+	}
+
+	if err := b.tx.GetContext(ctx, &b.oldTotal, queryOrderTotal, b.req.Id); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("get order total failed: %v", err)
+	}
+	return nil
+}
+func (b *editOrderBuilder) updateItems() error {
+	if len(b.req.Products) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	// Give the old qty back first so the new qty is checked against the full stock
+	if err := ReleaseStock(ctx, b.tx, b.req.Id); err != nil {
+		b.tx.Rollback()
+		return err
+	}
+
+	// Sum qty of the same product, keep the request order for new lines
+	qtyMap := make(map[string]int)
+	productMap := make(map[string]*orders.ProductsOrder)
+	productIds := make([]string, 0)
+	for _, p := range b.req.Products {
+		if _, ok := qtyMap[p.Product.Id]; !ok {
+			productIds = append(productIds, p.Product.Id)
+			productMap[p.Product.Id] = p
+		}
+		qtyMap[p.Product.Id] += p.Qty
+	}
+
+	queryLines := `
+	SELECT
+		"id",
+		"qty",
+		"product"->>'id' AS "product_id"
+	FROM "products_orders"
+	WHERE "order_id" = $1
+	ORDER BY "id";`
+
+	lines := make([]struct {
+		Id        string `db:"id"`
+		Qty       int    `db:"qty"`
+		ProductId string `db:"product_id"`
+	}, 0)
+	if err := b.tx.SelectContext(ctx, &lines, queryLines, b.req.Id); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("get products_orders failed: %v", err)
+	}
+
+	// Lines already in the order keep the price of their snapshot
+	kept := make(map[string]bool)
+	for _, line := range lines {
+		qty, ok := qtyMap[line.ProductId]
+		if !ok || kept[line.ProductId] {
+			if _, err := b.tx.ExecContext(ctx, `DELETE FROM "products_orders" WHERE "id" = $1;`, line.Id); err != nil {
+				b.tx.Rollback()
+				return fmt.Errorf("delete products_orders failed: %v", err)
+			}
+			b.changes = append(b.changes, fmt.Sprintf("removed product %s", line.ProductId))
+			continue
+		}
+		kept[line.ProductId] = true
+
+		if qty != line.Qty {
+			if _, err := b.tx.ExecContext(ctx, `UPDATE "products_orders" SET "qty" = $1 WHERE "id" = $2;`, qty, line.Id); err != nil {
+				b.tx.Rollback()
+				return fmt.Errorf("update products_orders failed: %v", err)
+			}
+			b.changes = append(b.changes, fmt.Sprintf("product %s qty %d -> %d", line.ProductId, line.Qty, qty))
+		}
+	}
+
+	queryInsert := `
+	INSERT INTO "products_orders" (
+		"order_id",
+		"qty",
+		"product"
+	)
+	VALUES ($1, $2, $3);`
+
+	for _, id := range productIds {
+		if kept[id] {
+			continue
+		}
+		if _, err := b.tx.ExecContext(ctx, queryInsert, b.req.Id, qtyMap[id], productMap[id].Product); err != nil {
+			b.tx.Rollback()
+			return fmt.Errorf("insert products_orders failed: %v", err)
+		}
+		b.changes = append(b.changes, fmt.Sprintf("added product %s qty %d", id, qtyMap[id]))
+	}
+
+	if err := ReserveStock(ctx, b.tx, b.req.Id); err != nil {
+		b.tx.Rollback()
+		return err
+	}
+	return nil
+}
+func (b *editOrderBuilder) updateAddress() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	if b.req.Address != "" {
+		query := `
+		UPDATE "orders" SET
+			"address" = $1,
+			"shipping_address" = $2
+		WHERE "id" = $3;`
+
+		if _, err := b.tx.ExecContext(ctx, query, b.req.Address, b.req.ShippingAddress, b.req.Id); err != nil {
+			b.tx.Rollback()
+			return fmt.Errorf("update order address failed: %v", err)
+		}
+		b.changes = append(b.changes, "address changed")
+	}
+	if b.req.Contact != "" {
+		if _, err := b.tx.ExecContext(ctx, `UPDATE "orders" SET "contact" = $1 WHERE "id" = $2;`, b.req.Contact, b.req.Id); err != nil {
+			b.tx.Rollback()
+			return fmt.Errorf("update order contact failed: %v", err)
+		}
+		b.changes = append(b.changes, "contact changed")
+	}
+	return nil
+}
+func (b *editOrderBuilder) updateShipping() error {
+	if b.fee == nil || b.req.Shipping == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	// From the snapshots, so the fee matches what the order contains after the edit
+	querySum := `
+	SELECT
+		COALESCE(SUM(("product"->>'price')::FLOAT * "qty"), 0) AS "subtotal",
+		COALESCE(SUM(COALESCE(("product"->>'weight')::FLOAT, 0) * "qty"), 0) AS "weight"
+	FROM "products_orders"
+	WHERE "order_id" = $1;`
+
+	var subtotal, weight float64
+	if err := b.tx.QueryRowxContext(ctx, querySum, b.req.Id).Scan(&subtotal, &weight); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("get order subtotal failed: %v", err)
+	}
+
+	b.req.Shipping.Fee = b.fee(subtotal, weight)
+	b.req.ShippingFee = b.req.Shipping.Fee
+
+	query := `
+	UPDATE "orders" SET
+		"shipping" = $1,
+		"shipping_fee" = $2
+	WHERE "id" = $3;`
+
+	if _, err := b.tx.ExecContext(ctx, query, b.req.Shipping, b.req.ShippingFee, b.req.Id); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("update order shipping failed: %v", err)
+	}
+	return nil
+}
+func (b *editOrderBuilder) cancelPayments() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	if err := b.tx.GetContext(ctx, &b.newTotal, queryOrderTotal, b.req.Id); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("get order total failed: %v", err)
+	}
+	if b.newTotal == b.oldTotal {
+		return nil
+	}
+
+	// Pending payments were created for the old amount
+	query := `
+	UPDATE "payments" SET
+		"status" = 'failed',
+		"error" = 'order was edited'
+	WHERE "order_id" = $1
+	AND "status" = 'pending';`
+
+	result, err := b.tx.ExecContext(ctx, query, b.req.Id)
+	if err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("update payments failed: %v", err)
+	}
+	if canceled, _ := result.RowsAffected(); canceled > 0 {
+		b.changes = append(b.changes, fmt.Sprintf("%d pending payments canceled", canceled))
+	}
+	return nil
+}
+func (b *editOrderBuilder) adjustCredits() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	query := `
+	SELECT
+		COALESCE(-SUM("amount"), 0)
+	FROM "credits_ledger"
+	WHERE "order_id" = $1
+	AND "kind" IN ('redeem', 'refund');`
+
+	var creditUsed float64
+	if err := b.tx.GetContext(ctx, &creditUsed, query, b.req.Id); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("get order credit failed: %v", err)
+	}
+	creditUsed = math.Round(creditUsed*100) / 100
+	if creditUsed <= 0 {
+		return nil
+	}
+
+	// Credit above the new total goes back to the gift cards and store credit it came from
+	newTotal := math.Round(b.newTotal*100) / 100
+	if excess := math.Round((creditUsed-newTotal)*100) / 100; excess > 0 {
+		if err := creditsPatterns.RefundPart(ctx, b.tx, b.req.Id, excess, fmt.Sprintf("order %s edited", b.req.Id)); err != nil {
+			b.tx.Rollback()
+			return err
+		}
+		b.changes = append(b.changes, fmt.Sprintf("credit %.2f returned", excess))
+		creditUsed = newTotal
+	}
+
+	// Fully paid with credit, as at checkout
+	if creditUsed >= newTotal {
+		if _, err := b.tx.ExecContext(ctx, `UPDATE "orders" SET "status" = 'paid' WHERE "id" = $1;`, b.req.Id); err != nil {
+			b.tx.Rollback()
+			return fmt.Errorf("update order status failed: %v", err)
+		}
+		if err := creditsPatterns.ActivateGiftCards(ctx, b.tx, b.req.Id); err != nil {
+			b.tx.Rollback()
+			return err
+		}
+		b.changes = append(b.changes, fmt.Sprintf("paid %.2f with gift card and store credit", creditUsed))
+	}
+	return nil
+}
+func (b *editOrderBuilder) insertHistory() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	if b.newTotal != b.oldTotal {
+		b.changes = append(b.changes, fmt.Sprintf("total %.2f -> %.2f", b.oldTotal, b.newTotal))
+	}
+	if len(b.changes) == 0 {
+		return nil
+	}
+
+	if err := InsertOrderHistory(ctx, b.tx, b.req.Id, "edited: "+strings.Join(b.changes, ", ")); err != nil {
+		b.tx.Rollback()
+		return err
+	}
+	return nil
+}
+func (b *editOrderBuilder) commit() error {
+	if err := b.tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (en *editOrderEngineer) EditOrder() error {
+	if err := en.builder.initTransaction(); err != nil {
+		return err
+	}
+	if err := en.builder.checkOrder(); err != nil {
+		return err
+	}
+	if err := en.builder.updateItems(); err != nil {
+		return err
+	}
+	if err := en.builder.updateAddress(); err != nil {
+		return err
+	}
+	if err := en.builder.updateShipping(); err != nil {
+		return err
+	}
+	if err := en.builder.cancelPayments(); err != nil {
+		return err
+	}
+	if err := en.builder.adjustCredits(); err != nil {
+		return err
+	}
+	if err := en.builder.insertHistory(); err != nil {
+		return err
+	}
+	if err := en.builder.commit(); err != nil {
+		return err
+	}
+	return nil
+}
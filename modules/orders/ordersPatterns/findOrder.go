@@ -62,6 +62,7 @@ func (b *findOrderBuilder) initQuery() {
 		SELECT
 			"o"."id",
 			"o"."user_id",
+			"o"."guest_email",
 			"o"."transfer_slip",
 			"o"."status",
 			(
@@ -77,13 +78,35 @@ func (b *findOrderBuilder) initQuery() {
 				) AS "pt"
 			) AS "products",
 			"o"."address",
+			"o"."shipping_address",
 			"o"."contact",
+			"o"."shipping",
+			"o"."shipping_fee",
 			(
+				SELECT
+					COALESCE(-SUM("cl"."amount"), 0)
+				FROM "credits_ledger" "cl"
+				WHERE "cl"."order_id" = "o"."id"
+				AND "cl"."kind" IN ('redeem', 'refund')
+			) AS "credit_used",
+			GREATEST((
 				SELECT
 					SUM(COALESCE(("po"."product"->>'price')::FLOAT*("po"."qty")::FLOAT, 0))
 				FROM "products_orders" "po"
 				WHERE "po"."order_id" = "o"."id"
-			) AS "total_paid",
+			) + "o"."shipping_fee" - (
+				SELECT
+					COALESCE(-SUM("cl"."amount"), 0)
+				FROM "credits_ledger" "cl"
+				WHERE "cl"."order_id" = "o"."id"
+				AND "cl"."kind" IN ('redeem', 'refund')
+			), 0) AS "total_paid",
+			(
+				SELECT
+					COALESCE(SUM("rf"."amount"), 0)
+				FROM "refunds" "rf"
+				WHERE "rf"."order_id" = "o"."id"
+			) AS "total_refunded",
 			"o"."created_at",
 			"o"."updated_at"
 		FROM "orders" "o"
@@ -105,17 +128,25 @@ func (b *findOrderBuilder) buildWhereSearch() {
 			"%"+strings.ToLower(b.req.Search)+"%",
 			"%"+strings.ToLower(b.req.Search)+"%",
 			"%"+strings.ToLower(b.req.Search)+"%",
+			"%"+strings.ToLower(b.req.Search)+"%",
 		)
 
 		query := fmt.Sprintf(`
 		AND (
-			LOWER("o"."user_id") LIKE $%d OR
+			LOWER(COALESCE("o"."user_id", "o"."guest_email")) LIKE $%d OR
 			LOWER("o"."address") LIKE $%d OR
-			LOWER("o"."contact") LIKE $%d
+			LOWER("o"."contact") LIKE $%d OR
+			EXISTS (
+				SELECT 1
+				FROM "orders_notes" "n"
+				WHERE "n"."order_id" = "o"."id"
+				AND LOWER("n"."note") LIKE $%d
+			)
 		)`,
 			b.lastIndex+1,
 			b.lastIndex+2,
 			b.lastIndex+3,
+			b.lastIndex+4,
 		)
 		temp := b.getQuery()
 		temp += query
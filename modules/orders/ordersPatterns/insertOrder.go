@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/LGROW101/lgrow-shop/modules/credits"
+	"github.com/LGROW101/lgrow-shop/modules/credits/creditsPatterns"
 	"github.com/LGROW101/lgrow-shop/modules/orders"
 	"github.com/jmoiron/sqlx"
 )
@@ -13,6 +15,9 @@ type IInsertOrderBuilder interface {
 	initTransaction() error
 	insertOrder() error
 	insertProductsOrder() error
+	reserveStock() error
+	insertHistory() error
+	redeemCredits() error
 	getOrderId() string
 	commit() error
 }
@@ -45,22 +50,32 @@ func (b *insertOrderBuilder) insertOrder() error {
 	query := `
 	INSERT INTO "orders" (
 		"user_id",
+		"guest_email",
+		"guest_token",
 		"contact",
 		"address",
+		"shipping_address",
 		"transfer_slip",
+		"shipping",
+		"shipping_fee",
 		"status"
 	)
 	VALUES
-	($1, $2, $3, $4, $5)
+	(NULLIF($1, ''), NULLIF($2, ''), NULLIF($3, ''), $4, $5, $6, $7, $8, $9, $10)
 		RETURNING "id";`
 
 	if err := b.tx.QueryRowxContext(
 		ctx,
 		query,
 		b.req.UserId,
+		b.req.GuestEmail,
+		b.req.GuestToken,
 		b.req.Contact,
 		b.req.Address,
+		b.req.ShippingAddress,
 		b.req.TransferSlip,
+		b.req.Shipping,
+		b.req.ShippingFee,
 		b.req.Status,
 	).Scan(&b.req.Id); err != nil {
 		b.tx.Rollback()
@@ -107,6 +122,115 @@ func (b *insertOrderBuilder) insertProductsOrder() error {
 	}
 	return nil
 }
+func (b *insertOrderBuilder) reserveStock() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	// Sum qty of the same product
+	qtyMap := make(map[string]int)
+	productIds := make([]string, 0)
+	for i := range b.req.Products {
+		id := b.req.Products[i].Product.Id
+		if _, ok := qtyMap[id]; !ok {
+			productIds = append(productIds, id)
+		}
+		qtyMap[id] += b.req.Products[i].Qty
+	}
+
+	querySelect := `
+	SELECT
+		"stock"
+	FROM "products"
+	WHERE "id" = $1
+	FOR UPDATE;`
+
+	queryUpdate := `
+	UPDATE "products" SET
+		"stock" = "stock" - $1
+	WHERE "id" = $2;`
+
+	for _, id := range productIds {
+		var stock *int
+		if err := b.tx.QueryRowxContext(ctx, querySelect, id).Scan(&stock); err != nil {
+			b.tx.Rollback()
+			return fmt.Errorf("get product stock failed: %v", err)
+		}
+		// Not tracked
+		if stock == nil {
+			continue
+		}
+		if *stock < qtyMap[id] {
+			b.tx.Rollback()
+			return fmt.Errorf("product %s is out of stock", id)
+		}
+
+		if _, err := b.tx.ExecContext(ctx, queryUpdate, qtyMap[id], id); err != nil {
+			b.tx.Rollback()
+			return fmt.Errorf("update product stock failed: %v", err)
+		}
+	}
+	return nil
+}
+func (b *insertOrderBuilder) insertHistory() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	query := `
+	INSERT INTO "orders_histories" (
+		"order_id",
+		"status",
+		"note"
+	)
+	VALUES
+	($1, $2, 'order created');`
+
+	if _, err := b.tx.ExecContext(ctx, query, b.req.Id, b.req.Status); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("insert orders_histories failed: %v", err)
+	}
+	return nil
+}
+func (b *insertOrderBuilder) redeemCredits() error {
+	if b.req.GiftCardCode == "" && !b.req.UseStoreCredit {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	used, err := creditsPatterns.Redeem(ctx, b.tx, &credits.RedeemReq{
+		OrderId:        b.req.Id,
+		UserId:         b.req.UserId,
+		GiftCardCode:   b.req.GiftCardCode,
+		UseStoreCredit: b.req.UseStoreCredit,
+		Amount:         b.req.TotalPaid,
+	})
+	if err != nil {
+		b.tx.Rollback()
+		return err
+	}
+	if used <= 0 {
+		return nil
+	}
+
+	// Fully paid with credit
+	note := fmt.Sprintf("paid %.2f with gift card and store credit", used)
+	if used >= b.req.TotalPaid {
+		if _, err := b.tx.ExecContext(ctx, `UPDATE "orders" SET "status" = 'paid' WHERE "id" = $1;`, b.req.Id); err != nil {
+			b.tx.Rollback()
+			return fmt.Errorf("update order status failed: %v", err)
+		}
+		if err := creditsPatterns.ActivateGiftCards(ctx, b.tx, b.req.Id); err != nil {
+			b.tx.Rollback()
+			return err
+		}
+	}
+	if err := InsertOrderHistory(ctx, b.tx, b.req.Id, note); err != nil {
+		b.tx.Rollback()
+		return err
+	}
+	return nil
+}
 func (b *insertOrderBuilder) commit() error {
 	if err := b.tx.Commit(); err != nil {
 		return err
@@ -135,6 +259,15 @@ func (en *insertOrderEngineer) InsertOrder() (string, error) {
 	if err := en.builder.insertProductsOrder(); err != nil {
 		return "", err
 	}
+	if err := en.builder.reserveStock(); err != nil {
+		return "", err
+	}
+	if err := en.builder.insertHistory(); err != nil {
+		return "", err
+	}
+	if err := en.builder.redeemCredits(); err != nil {
+		return "", err
+	}
 	if err := en.builder.commit(); err != nil {
 		return "", err
 	}
@@ -0,0 +1,243 @@
+package ordersPatterns
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/LGROW101/lgrow-shop/modules/orders"
+	"github.com/jmoiron/sqlx"
+)
+
+type IInsertShipmentBuilder interface {
+	initTransaction() error
+	checkOrder() error
+	checkItems() error
+	insertShipment() error
+	insertShipmentItems() error
+	updateOrderStatus() error
+	getShipmentId() string
+	commit() error
+}
+
+type insertShipmentBuilder struct {
+	db  *sqlx.DB
+	req *orders.Shipment
+	tx  *sqlx.Tx
+}
+
+type insertShipmentEngineer struct {
+	builder IInsertShipmentBuilder
+}
+
+func InsertShipmentBuilder(db *sqlx.DB, req *orders.Shipment) IInsertShipmentBuilder {
+	return &insertShipmentBuilder{
+		db:  db,
+		req: req,
+	}
+}
+
+func InsertShipmentEngineer(b IInsertShipmentBuilder) *insertShipmentEngineer {
+	return &insertShipmentEngineer{builder: b}
+}
+
+func (b *insertShipmentBuilder) getShipmentId() string {
+	return b.req.Id
+}
+func (b *insertShipmentBuilder) initTransaction() error {
+	tx, err := b.db.BeginTxx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	b.tx = tx
+	return nil
+}
+func (b *insertShipmentBuilder) checkOrder() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	query := `
+	SELECT
+		"status"
+	FROM "orders"
+	WHERE "id" = $1
+	FOR UPDATE;`
+
+	var status string
+	if err := b.tx.QueryRowxContext(ctx, query, b.req.OrderId).Scan(&status); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("order not found")
+	}
+	if status == "waiting" {
+		b.tx.Rollback()
+		return fmt.Errorf("order has not been paid")
+	}
+	if status == "canceled" || status == "completed" {
+		b.tx.Rollback()
+		return fmt.Errorf("order has been %s", status)
+	}
+	return nil
+}
+func (b *insertShipmentBuilder) checkItems() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	// Remaining qty = ordered qty - qty in every previous shipment
+	query := `
+	SELECT
+		"po"."qty" - COALESCE((
+			SELECT
+				SUM("si"."qty")
+			FROM "shipments_items" "si"
+			WHERE "si"."products_order_id" = "po"."id"
+		), 0)
+	FROM "products_orders" "po"
+	WHERE "po"."id" = $1
+	AND "po"."order_id" = $2;`
+
+	for _, item := range b.req.Items {
+		if item.Qty < 1 {
+			b.tx.Rollback()
+			return fmt.Errorf("qty must more than 0")
+		}
+
+		var remaining int
+		if err := b.tx.QueryRowxContext(ctx, query, item.ProductsOrderId, b.req.OrderId).Scan(&remaining); err != nil {
+			b.tx.Rollback()
+			return fmt.Errorf("item %s not found in order", item.ProductsOrderId)
+		}
+		if item.Qty > remaining {
+			b.tx.Rollback()
+			return fmt.Errorf("item %s has only %d left to ship", item.ProductsOrderId, remaining)
+		}
+	}
+	return nil
+}
+func (b *insertShipmentBuilder) insertShipment() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	query := `
+	INSERT INTO "shipments" (
+		"order_id",
+		"carrier",
+		"tracking_number",
+		"shipped_at"
+	)
+	VALUES
+	($1, $2, $3, COALESCE(NULLIF($4, '')::TIMESTAMP, now()))
+		RETURNING "id";`
+
+	if err := b.tx.QueryRowxContext(
+		ctx,
+		query,
+		b.req.OrderId,
+		b.req.Carrier,
+		b.req.TrackingNumber,
+		b.req.ShippedAt,
+	).Scan(&b.req.Id); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("insert shipment failed: %v", err)
+	}
+	return nil
+}
+func (b *insertShipmentBuilder) insertShipmentItems() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	query := `
+	INSERT INTO "shipments_items" (
+		"shipment_id",
+		"products_order_id",
+		"qty"
+	)
+	VALUES`
+
+	values := make([]any, 0)
+	lastIndex := 0
+	for i := range b.req.Items {
+		values = append(
+			values,
+			b.req.Id,
+			b.req.Items[i].ProductsOrderId,
+			b.req.Items[i].Qty,
+		)
+
+		if i != len(b.req.Items)-1 {
+			query += fmt.Sprintf(`
+			($%d, $%d, $%d),`, lastIndex+1, lastIndex+2, lastIndex+3)
+		} else {
+			query += fmt.Sprintf(`
+			($%d, $%d, $%d);`, lastIndex+1, lastIndex+2, lastIndex+3)
+		}
+
+		lastIndex += 3
+	}
+
+	if _, err := b.tx.ExecContext(ctx, query, values...); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("insert shipments_items failed: %v", err)
+	}
+	return nil
+}
+func (b *insertShipmentBuilder) updateOrderStatus() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	// The first shipment moves the order to shipping
+	query := `
+	WITH "updated" AS (
+		UPDATE "orders" SET
+			"status" = 'shipping'
+		WHERE "id" = $1
+		AND "status" = 'paid'
+		RETURNING "id", "status"
+	)
+	INSERT INTO "orders_histories" (
+		"order_id",
+		"status",
+		"note"
+	)
+	SELECT
+		"id",
+		"status",
+		$2
+	FROM "updated";`
+
+	if _, err := b.tx.ExecContext(ctx, query, b.req.OrderId, fmt.Sprintf("shipped by %s (%s)", b.req.Carrier, b.req.TrackingNumber)); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("update order status failed: %v", err)
+	}
+	return nil
+}
+func (b *insertShipmentBuilder) commit() error {
+	if err := b.tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (en *insertShipmentEngineer) InsertShipment() (string, error) {
+	if err := en.builder.initTransaction(); err != nil {
+		return "", err
+	}
+	if err := en.builder.checkOrder(); err != nil {
+		return "", err
+	}
+	if err := en.builder.checkItems(); err != nil {
+		return "", err
+	}
+	if err := en.builder.insertShipment(); err != nil {
+		return "", err
+	}
+	if err := en.builder.insertShipmentItems(); err != nil {
+		return "", err
+	}
+	if err := en.builder.updateOrderStatus(); err != nil {
+		return "", err
+	}
+	if err := en.builder.commit(); err != nil {
+		return "", err
+	}
+	return en.builder.getShipmentId(), nil
+}
@@ -0,0 +1,138 @@
+package ordersPatterns
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// InsertOrderHistory records the current status of the order with a note
+func InsertOrderHistory(ctx context.Context, tx *sqlx.Tx, orderId, note string) error {
+	query := `
+	INSERT INTO "orders_histories" (
+		"order_id",
+		"status",
+		"note"
+	)
+	SELECT
+		"o"."id",
+		"o"."status",
+		$2
+	FROM "orders" "o"
+	WHERE "o"."id" = $1;`
+
+	if _, err := tx.ExecContext(ctx, query, orderId, note); err != nil {
+		return fmt.Errorf("insert orders_histories failed: %v", err)
+	}
+	return nil
+}
+
+// AmountDue is what the order costs less the credit it used, the same as total_paid of FindOneOrder
+func AmountDue(ctx context.Context, tx *sqlx.Tx, orderId string) (float64, error) {
+	query := `
+	SELECT
+		GREATEST(COALESCE((
+			SELECT
+				SUM(("po"."product"->>'price')::FLOAT * "po"."qty")
+			FROM "products_orders" "po"
+			WHERE "po"."order_id" = "o"."id"
+		), 0) + "o"."shipping_fee" - (
+			SELECT
+				COALESCE(-SUM("cl"."amount"), 0)
+			FROM "credits_ledger" "cl"
+			WHERE "cl"."order_id" = "o"."id"
+			AND "cl"."kind" IN ('redeem', 'refund')
+		), 0)
+	FROM "orders" "o"
+	WHERE "o"."id" = $1;`
+
+	var due float64
+	if err := tx.GetContext(ctx, &due, query, orderId); err != nil {
+		return 0, fmt.Errorf("get order amount due failed: %v", err)
+	}
+	return math.Round(due*100) / 100, nil
+}
+
+// ReleaseStock gives the reserved qty of every tracked product in the order back,
+// less what a received return already put back
+func ReleaseStock(ctx context.Context, tx *sqlx.Tx, orderId string) error {
+	query := `
+	UPDATE "products" "p" SET
+		"stock" = "p"."stock" + "r"."qty"
+	FROM (
+		SELECT
+			"po"."product"->>'id' AS "product_id",
+			SUM("po"."qty" - COALESCE((
+				SELECT SUM("ri"."qty")
+				FROM "returns_items" "ri"
+					JOIN "returns" "rt" ON "rt"."id" = "ri"."return_id"
+				WHERE "ri"."products_order_id" = "po"."id"
+				AND "rt"."status" IN ('received', 'refunded')
+			), 0)) AS "qty"
+		FROM "products_orders" "po"
+		WHERE "po"."order_id" = $1
+		GROUP BY "po"."product"->>'id'
+	) AS "r"
+	WHERE "p"."id" = "r"."product_id"
+	AND "p"."stock" IS NOT NULL
+	AND "r"."qty" > 0;`
+
+	if _, err := tx.ExecContext(ctx, query, orderId); err != nil {
+		return fmt.Errorf("release stock failed: %v", err)
+	}
+	return nil
+}
+
+// ReserveStock takes the qty of every tracked product in the order from the stock
+func ReserveStock(ctx context.Context, tx *sqlx.Tx, orderId string) error {
+	queryQty := `
+	SELECT
+		"po"."product"->>'id' AS "product_id",
+		SUM("po"."qty") AS "qty"
+	FROM "products_orders" "po"
+	WHERE "po"."order_id" = $1
+	GROUP BY "po"."product"->>'id';`
+
+	items := make([]struct {
+		ProductId string `db:"product_id"`
+		Qty       int    `db:"qty"`
+	}, 0)
+	if err := tx.SelectContext(ctx, &items, queryQty, orderId); err != nil {
+		return fmt.Errorf("get products_orders failed: %v", err)
+	}
+
+	querySelect := `
+	SELECT
+		"stock"
+	FROM "products"
+	WHERE "id" = $1
+	FOR UPDATE;`
+
+	queryUpdate := `
+	UPDATE "products" SET
+		"stock" = "stock" - $1
+	WHERE "id" = $2;`
+
+	for _, item := range items {
+		var stock *int
+		err := tx.QueryRowxContext(ctx, querySelect, item.ProductId).Scan(&stock)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("get product stock failed: %v", err)
+		}
+		// Deleted or not tracked
+		if stock == nil {
+			continue
+		}
+		if *stock < item.Qty {
+			return fmt.Errorf("product %s is out of stock", item.ProductId)
+		}
+
+		if _, err := tx.ExecContext(ctx, queryUpdate, item.Qty, item.ProductId); err != nil {
+			return fmt.Errorf("update product stock failed: %v", err)
+		}
+	}
+	return nil
+}
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/LGROW101/lgrow-shop/modules/credits/creditsPatterns"
 	"github.com/LGROW101/lgrow-shop/modules/orders"
 	"github.com/LGROW101/lgrow-shop/modules/orders/ordersPatterns"
 	"github.com/jmoiron/sqlx"
@@ -15,7 +17,18 @@ type IOrdersRepository interface {
 	FindOneOrder(orderId string) (*orders.Order, error)
 	FindOrder(req *orders.OrderFilter) ([]*orders.Order, int)
 	InsertOrder(req *orders.Order) (string, error)
-	UpdateOrder(req *orders.Order) error
+	UpdateOrder(req *orders.Order, isAdmin bool) error
+	InsertShipment(req *orders.Shipment) (string, error)
+	DeliverShipment(orderId, shipmentId string) error
+	FindOneTransferSlip(slipId string) (*orders.TransferSlip, error)
+	FindTransferSlip(req *orders.TransferSlipFilter) ([]*orders.TransferSlipReview, error)
+	InsertTransferSlip(req *orders.TransferSlip) error
+	ReviewTransferSlip(req *orders.ReviewTransferSlipReq) error
+	CancelUnpaidOrders(ctx context.Context, olderThan time.Duration) ([]string, error)
+	InsertInvoice(orderId string, fiscalYear int) (*orders.Invoice, error)
+	InsertNote(req *orders.OrderNote) error
+	EditOrder(req *orders.Order, fee ordersPatterns.ShippingFeeFunc) error
+	FindOneGuestOrder(orderId, tokenHash string) (*orders.Order, error)
 }
 
 type ordersRepository struct {
@@ -34,6 +47,7 @@ func (r *ordersRepository) FindOneOrder(orderId string) (*orders.Order, error) {
 		SELECT
 			"o"."id",
 			"o"."user_id",
+			"o"."guest_email",
 			"o"."transfer_slip",
 			"o"."status",
 			(
@@ -48,14 +62,97 @@ func (r *ordersRepository) FindOneOrder(orderId string) (*orders.Order, error) {
 					WHERE "spo"."order_id" = "o"."id"
 				) AS "pt"
 			) AS "products",
+			(
+				SELECT
+					COALESCE(array_to_json(array_agg("st")), '[]'::json)
+				FROM (
+					SELECT
+						"s"."id",
+						"s"."order_id",
+						"s"."carrier",
+						"s"."tracking_number",
+						(
+							SELECT
+								COALESCE(array_to_json(array_agg("sit")), '[]'::json)
+							FROM (
+								SELECT
+									"si"."id",
+									"si"."products_order_id",
+									"si"."qty"
+								FROM "shipments_items" "si"
+								WHERE "si"."shipment_id" = "s"."id"
+							) AS "sit"
+						) AS "items",
+						"s"."shipped_at",
+						"s"."delivered_at",
+						"s"."created_at"
+					FROM "shipments" "s"
+					WHERE "s"."order_id" = "o"."id"
+					ORDER BY "s"."shipped_at" ASC
+				) AS "st"
+			) AS "shipments",
 			"o"."address",
+			"o"."shipping_address",
 			"o"."contact",
+			"o"."shipping",
+			"o"."shipping_fee",
 			(
+				SELECT
+					COALESCE(-SUM("cl"."amount"), 0)
+				FROM "credits_ledger" "cl"
+				WHERE "cl"."order_id" = "o"."id"
+				AND "cl"."kind" IN ('redeem', 'refund')
+			) AS "credit_used",
+			GREATEST((
 				SELECT
 					SUM(COALESCE(("po"."product"->>'price')::FLOAT*("po"."qty")::FLOAT, 0))
 				FROM "products_orders" "po"
 				WHERE "po"."order_id" = "o"."id"
-			) AS "total_paid",
+			) + "o"."shipping_fee" - (
+				SELECT
+					COALESCE(-SUM("cl"."amount"), 0)
+				FROM "credits_ledger" "cl"
+				WHERE "cl"."order_id" = "o"."id"
+				AND "cl"."kind" IN ('redeem', 'refund')
+			), 0) AS "total_paid",
+			(
+				SELECT
+					COALESCE(SUM("rf"."amount"), 0)
+				FROM "refunds" "rf"
+				WHERE "rf"."order_id" = "o"."id"
+			) AS "total_refunded",
+			(
+				SELECT
+					COALESCE(array_to_json(array_agg("ht")), '[]'::json)
+				FROM (
+					SELECT
+						"h"."id",
+						"h"."status",
+						"h"."note",
+						"h"."created_at"
+					FROM "orders_histories" "h"
+					WHERE "h"."order_id" = "o"."id"
+					ORDER BY "h"."created_at" ASC
+				) AS "ht"
+			) AS "histories",
+			(
+				SELECT
+					COALESCE(array_to_json(array_agg("nt")), '[]'::json)
+				FROM (
+					SELECT
+						"n"."id",
+						"n"."order_id",
+						"n"."user_id",
+						"u"."username",
+						"n"."internal",
+						"n"."note",
+						"n"."created_at"
+					FROM "orders_notes" "n"
+						LEFT JOIN "users" "u" ON "u"."id" = "n"."user_id"
+					WHERE "n"."order_id" = "o"."id"
+					ORDER BY "n"."created_at" ASC
+				) AS "nt"
+			) AS "notes",
 			"o"."created_at",
 			"o"."updated_at"
 		FROM "orders" "o"
@@ -92,7 +189,33 @@ func (r *ordersRepository) InsertOrder(req *orders.Order) (string, error) {
 	return orderId, nil
 }
 
-func (r *ordersRepository) UpdateOrder(req *orders.Order) error {
+func (r *ordersRepository) EditOrder(req *orders.Order, fee ordersPatterns.ShippingFeeFunc) error {
+	builder := ordersPatterns.EditOrderBuilder(r.db, req, fee)
+	return ordersPatterns.EditOrderEngineer(builder).EditOrder()
+}
+
+func (r *ordersRepository) UpdateOrder(req *orders.Order, isAdmin bool) error {
+	ctx := context.Background()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	var oldStatus string
+	if err := tx.QueryRowxContext(ctx, `SELECT "status" FROM "orders" WHERE "id" = $1 FOR UPDATE;`, req.Id).Scan(&oldStatus); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("order not found")
+	}
+	if req.Status != "" && req.Status != oldStatus && !orders.IsTransitionValid(oldStatus, req.Status, isAdmin) {
+		tx.Rollback()
+		// Only a succeeded payment moves the order out of waiting
+		if oldStatus == "waiting" && req.Status != "canceled" {
+			return fmt.Errorf("order has not been paid")
+		}
+		return fmt.Errorf("cannot change order status from %s to %s", oldStatus, req.Status)
+	}
+
 	query := `
 	UPDATE "orders" SET`
 
@@ -118,6 +241,11 @@ func (r *ordersRepository) UpdateOrder(req *orders.Order) error {
 		lastIndex++
 	}
 
+	if len(queryWhereStack) == 0 {
+		tx.Rollback()
+		return nil
+	}
+
 	values = append(values, req.Id)
 
 	queryClose := fmt.Sprintf(`
@@ -132,8 +260,567 @@ func (r *ordersRepository) UpdateOrder(req *orders.Order) error {
 	}
 	query += queryClose
 
-	if _, err := r.db.ExecContext(context.Background(), query, values...); err != nil {
+	if _, err := tx.ExecContext(ctx, query, values...); err != nil {
+		tx.Rollback()
 		return fmt.Errorf("update order failed: %v", err)
 	}
+
+	if req.Status != "" && req.Status != oldStatus {
+		note := fmt.Sprintf("status changed from %s to %s", oldStatus, req.Status)
+		if req.Status == "canceled" {
+			// Shipped items have left the warehouse, they come back through a return
+			var shipped bool
+			if err := tx.GetContext(ctx, &shipped, `SELECT EXISTS (SELECT 1 FROM "shipments" WHERE "order_id" = $1);`, req.Id); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("get shipments failed: %v", err)
+			}
+			if (oldStatus == "waiting" || oldStatus == "paid") && !shipped {
+				if err := ordersPatterns.ReleaseStock(ctx, tx, req.Id); err != nil {
+					tx.Rollback()
+					return err
+				}
+			}
+			// Redeemed credit only comes back before the order is paid, after that the
+			// money goes back through the payment refund or a store credit adjustment
+			if oldStatus == "waiting" {
+				if err := creditsPatterns.RefundRedemptions(ctx, tx, req.Id); err != nil {
+					tx.Rollback()
+					return err
+				}
+			} else {
+				note += ", payment has to be refunded"
+			}
+		}
+		if err := ordersPatterns.InsertOrderHistory(ctx, tx, req.Id, note); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
 	return nil
 }
+
+func (r *ordersRepository) InsertShipment(req *orders.Shipment) (string, error) {
+	builder := ordersPatterns.InsertShipmentBuilder(r.db, req)
+	shipmentId, err := ordersPatterns.InsertShipmentEngineer(builder).InsertShipment()
+	if err != nil {
+		return "", err
+	}
+	return shipmentId, nil
+}
+
+func (r *ordersRepository) DeliverShipment(orderId, shipmentId string) error {
+	ctx := context.Background()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	queryDeliver := `
+	UPDATE "shipments" SET
+		"delivered_at" = now()
+	WHERE "id" = $1
+	AND "order_id" = $2
+	AND "delivered_at" IS NULL;`
+
+	result, err := tx.ExecContext(ctx, queryDeliver, shipmentId, orderId)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("update shipment failed: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		tx.Rollback()
+		return fmt.Errorf("shipment not found or already delivered")
+	}
+
+	// Every item has been delivered -> completed
+	queryComplete := `
+	UPDATE "orders" SET
+		"status" = 'completed'
+	WHERE "id" = $1
+	AND "status" = 'shipping'
+	AND NOT EXISTS (
+		SELECT
+			1
+		FROM "products_orders" "po"
+		WHERE "po"."order_id" = $1
+		AND "po"."qty" > COALESCE((
+			SELECT
+				SUM("si"."qty")
+			FROM "shipments_items" "si"
+				LEFT JOIN "shipments" "s" ON "s"."id" = "si"."shipment_id"
+			WHERE "si"."products_order_id" = "po"."id"
+			AND "s"."delivered_at" IS NOT NULL
+		), 0)
+	);`
+
+	completed, err := tx.ExecContext(ctx, queryComplete, orderId)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("update order status failed: %v", err)
+	}
+	if rows, _ := completed.RowsAffected(); rows > 0 {
+		if err := ordersPatterns.InsertOrderHistory(ctx, tx, orderId, "all items delivered"); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *ordersRepository) FindOneTransferSlip(slipId string) (*orders.TransferSlip, error) {
+	query := `
+	SELECT
+		"id",
+		"order_id",
+		"filename",
+		"destination",
+		"amount",
+		"transferred_at",
+		"sending_bank",
+		"trans_ref",
+		"status",
+		"reason",
+		"reviewed_by",
+		"reviewed_at",
+		"created_at"
+	FROM "transfer_slips"
+	WHERE "id" = $1;`
+
+	slip := new(orders.TransferSlip)
+	if err := r.db.Get(slip, query, slipId); err != nil {
+		return nil, fmt.Errorf("transfer slip not found")
+	}
+	return slip, nil
+}
+
+func (r *ordersRepository) FindTransferSlip(req *orders.TransferSlipFilter) ([]*orders.TransferSlipReview, error) {
+	query := `
+	SELECT
+		COALESCE(array_to_json(array_agg("t")), '[]'::json)
+	FROM (
+		SELECT
+			"ts"."id",
+			"ts"."order_id",
+			"ts"."filename",
+			"ts"."amount",
+			"ts"."transferred_at",
+			"ts"."sending_bank",
+			"ts"."trans_ref",
+			"ts"."status",
+			"ts"."reason",
+			"ts"."reviewed_by",
+			"ts"."reviewed_at",
+			"ts"."created_at",
+			"o"."user_id",
+			"p"."total_paid",
+			"ts"."amount" - "p"."total_paid" AS "difference"
+		FROM "transfer_slips" "ts"
+			LEFT JOIN "orders" "o" ON "o"."id" = "ts"."order_id"
+			LEFT JOIN LATERAL (
+				SELECT
+					GREATEST(COALESCE(SUM(("po"."product"->>'price')::FLOAT*("po"."qty")::FLOAT), 0) + "o"."shipping_fee" - (
+						SELECT
+							COALESCE(-SUM("cl"."amount"), 0)
+						FROM "credits_ledger" "cl"
+						WHERE "cl"."order_id" = "o"."id"
+						AND "cl"."kind" IN ('redeem', 'refund')
+					), 0) AS "total_paid"
+				FROM "products_orders" "po"
+				WHERE "po"."order_id" = "o"."id"
+			) AS "p" ON TRUE
+		WHERE "ts"."status" = $1
+		ORDER BY "ts"."created_at" ASC
+	) AS "t";`
+
+	if req.Status == "" {
+		req.Status = "pending"
+	}
+
+	raw := make([]byte, 0)
+	if err := r.db.Get(&raw, query, req.Status); err != nil {
+		return nil, fmt.Errorf("get transfer slips failed: %v", err)
+	}
+
+	slips := make([]*orders.TransferSlipReview, 0)
+	if err := json.Unmarshal(raw, &slips); err != nil {
+		return nil, fmt.Errorf("unmarshal transfer slips failed: %v", err)
+	}
+	return slips, nil
+}
+
+func (r *ordersRepository) InsertTransferSlip(req *orders.TransferSlip) error {
+	ctx := context.Background()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	var status string
+	if err := tx.QueryRowxContext(ctx, `SELECT "status" FROM "orders" WHERE "id" = $1 FOR UPDATE;`, req.OrderId).Scan(&status); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("order not found")
+	}
+	if status != "waiting" {
+		tx.Rollback()
+		return fmt.Errorf("order is not waiting for payment")
+	}
+
+	var pending int
+	if err := tx.GetContext(ctx, &pending, `SELECT COUNT(*) FROM "transfer_slips" WHERE "order_id" = $1 AND "status" = 'pending';`, req.OrderId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("get transfer slips failed: %v", err)
+	}
+	if pending > 0 {
+		tx.Rollback()
+		return fmt.Errorf("transfer slip is waiting for verification")
+	}
+
+	// A transaction reference can only pay for one order
+	if req.TransRef != "" {
+		var used int
+		if err := tx.GetContext(ctx, &used, `SELECT COUNT(*) FROM "transfer_slips" WHERE "trans_ref" = $1 AND "status" <> 'rejected';`, req.TransRef); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("get transfer slips failed: %v", err)
+		}
+		if used > 0 {
+			tx.Rollback()
+			return fmt.Errorf("transfer slip has been used")
+		}
+	}
+
+	query := `
+	INSERT INTO "transfer_slips" (
+		"order_id",
+		"filename",
+		"destination",
+		"amount",
+		"transferred_at",
+		"sending_bank",
+		"trans_ref"
+	)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING "id", "status", "created_at";`
+
+	if err := tx.QueryRowxContext(
+		ctx,
+		query,
+		req.OrderId,
+		req.FileName,
+		req.Destination,
+		req.Amount,
+		req.TransferredAt,
+		req.SendingBank,
+		req.TransRef,
+	).Scan(&req.Id, &req.Status, &req.CreatedAt); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("insert transfer slip failed: %v", err)
+	}
+
+	// The order keeps the latest slip
+	if _, err := tx.ExecContext(ctx, `UPDATE "orders" SET "transfer_slip" = $1 WHERE "id" = $2;`, req, req.OrderId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("update order failed: %v", err)
+	}
+
+	if err := ordersPatterns.InsertOrderHistory(ctx, tx, req.OrderId, fmt.Sprintf("transfer slip %s uploaded, amount %.2f", req.Id, req.Amount)); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ReviewTransferSlip approves or rejects a pending slip,
+// an approved slip is recorded as a succeeded payment and the order becomes paid
+func (r *ordersRepository) ReviewTransferSlip(req *orders.ReviewTransferSlipReq) error {
+	ctx := context.Background()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	var orderId, status string
+	var amount float64
+	if err := tx.QueryRowxContext(ctx, `SELECT "order_id", "status", "amount" FROM "transfer_slips" WHERE "id" = $1 FOR UPDATE;`, req.Id).Scan(&orderId, &status, &amount); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("transfer slip not found")
+	}
+	if status != "pending" {
+		tx.Rollback()
+		return fmt.Errorf("transfer slip has been %s", status)
+	}
+
+	var orderStatus string
+	if err := tx.QueryRowxContext(ctx, `SELECT "status" FROM "orders" WHERE "id" = $1 FOR UPDATE;`, orderId).Scan(&orderStatus); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("order not found")
+	}
+	if req.Status == "approved" && orderStatus != "waiting" {
+		tx.Rollback()
+		return fmt.Errorf("order is not waiting for payment")
+	}
+
+	query := `
+	UPDATE "transfer_slips" SET
+		"status" = $1,
+		"reason" = $2,
+		"reviewed_by" = $3,
+		"reviewed_at" = now()
+	WHERE "id" = $4
+		RETURNING "id", "order_id", "filename", "amount", "transferred_at", "sending_bank", "trans_ref", "status", "reason", "reviewed_by", "reviewed_at", "created_at";`
+
+	slip := new(orders.TransferSlip)
+	if err := tx.QueryRowxContext(ctx, query, req.Status, req.Reason, req.ReviewedBy, req.Id).StructScan(slip); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("update transfer slip failed: %v", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE "orders" SET "transfer_slip" = $1 WHERE "id" = $2;`, slip, orderId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("update order failed: %v", err)
+	}
+
+	note := fmt.Sprintf("transfer slip %s %s", req.Id, req.Status)
+	if req.Reason != "" {
+		note += ": " + req.Reason
+	}
+
+	if req.Status == "approved" {
+		queryPayment := `
+		INSERT INTO "payments" (
+			"order_id",
+			"provider",
+			"provider_ref",
+			"amount",
+			"status"
+		)
+		VALUES ($1, 'transfer_slip', $2, $3, 'succeeded');`
+
+		if _, err := tx.ExecContext(ctx, queryPayment, orderId, req.Id, amount); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("insert payment failed: %v", err)
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE "orders" SET "status" = 'paid' WHERE "id" = $1;`, orderId); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("update order status failed: %v", err)
+		}
+		if err := creditsPatterns.ActivateGiftCards(ctx, tx, orderId); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := ordersPatterns.InsertOrderHistory(ctx, tx, orderId, note); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// CancelUnpaidOrders cancels waiting orders created before olderThan without a transfer slip
+// or a pending payment and releases their reserved stock
+func (r *ordersRepository) CancelUnpaidOrders(ctx context.Context, olderThan time.Duration) ([]string, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Orders locked by another transaction are picked up in the next run
+	query := `
+	SELECT
+		"o"."id"
+	FROM "orders" "o"
+	WHERE "o"."status" = 'waiting'
+	AND "o"."created_at" < now() - ($1 * INTERVAL '1 second')
+	AND NOT EXISTS (
+		SELECT 1
+		FROM "transfer_slips" "ts"
+		WHERE "ts"."order_id" = "o"."id"
+		AND "ts"."status" <> 'rejected'
+	)
+	AND NOT EXISTS (
+		SELECT 1
+		FROM "payments" "p"
+		WHERE "p"."order_id" = "o"."id"
+		AND "p"."status" = 'pending'
+	)
+	FOR UPDATE SKIP LOCKED;`
+
+	orderIds := make([]string, 0)
+	if err := tx.SelectContext(ctx, &orderIds, query, int64(olderThan.Seconds())); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("get unpaid orders failed: %v", err)
+	}
+
+	note := fmt.Sprintf("canceled automatically: not paid within %s", olderThan)
+	for _, id := range orderIds {
+		if _, err := tx.ExecContext(ctx, `UPDATE "orders" SET "status" = 'canceled' WHERE "id" = $1;`, id); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("update order failed: %v", err)
+		}
+		if err := ordersPatterns.ReleaseStock(ctx, tx, id); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err := creditsPatterns.RefundRedemptions(ctx, tx, id); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err := ordersPatterns.InsertOrderHistory(ctx, tx, id, note); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return orderIds, nil
+}
+
+// InsertInvoice issues the next invoice number of the fiscal year to a paid order,
+// an order that already has an invoice gets the same one back
+func (r *ordersRepository) InsertInvoice(orderId string, fiscalYear int) (*orders.Invoice, error) {
+	ctx := context.Background()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var status string
+	if err := tx.QueryRowxContext(ctx, `SELECT "status" FROM "orders" WHERE "id" = $1 FOR UPDATE;`, orderId).Scan(&status); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("order not found")
+	}
+
+	querySelect := `
+	SELECT
+		"id",
+		"order_id",
+		"fiscal_year",
+		"number",
+		"invoice_no",
+		"issued_at"
+	FROM "invoices"
+	WHERE "order_id" = $1;`
+
+	invoice := new(orders.Invoice)
+	if err := tx.GetContext(ctx, invoice, querySelect, orderId); err == nil {
+		tx.Rollback()
+		return invoice, nil
+	}
+
+	if status != "paid" && status != "shipping" && status != "completed" {
+		tx.Rollback()
+		return nil, fmt.Errorf("order has not been paid")
+	}
+
+	// The sequence row stays locked until commit, a failed insert rolls the number back
+	queryNumber := `
+	INSERT INTO "invoice_sequences" (
+		"fiscal_year",
+		"last_number"
+	)
+	VALUES ($1, 1)
+	ON CONFLICT ("fiscal_year") DO UPDATE SET
+		"last_number" = "invoice_sequences"."last_number" + 1
+	RETURNING "last_number";`
+
+	var number int
+	if err := tx.QueryRowxContext(ctx, queryNumber, fiscalYear).Scan(&number); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("get invoice number failed: %v", err)
+	}
+
+	queryInsert := `
+	INSERT INTO "invoices" (
+		"order_id",
+		"fiscal_year",
+		"number",
+		"invoice_no"
+	)
+	VALUES ($1, $2, $3, $4)
+		RETURNING "id", "order_id", "fiscal_year", "number", "invoice_no", "issued_at";`
+
+	if err := tx.GetContext(
+		ctx,
+		invoice,
+		queryInsert,
+		orderId,
+		fiscalYear,
+		number,
+		orders.InvoiceNo(fiscalYear, number),
+	); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("insert invoice failed: %v", err)
+	}
+
+	if err := ordersPatterns.InsertOrderHistory(ctx, tx, orderId, fmt.Sprintf("invoice %s issued", invoice.InvoiceNo)); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+func (r *ordersRepository) InsertNote(req *orders.OrderNote) error {
+	query := `
+	INSERT INTO "orders_notes" (
+		"order_id",
+		"user_id",
+		"internal",
+		"note"
+	)
+	VALUES ($1, $2, $3, $4)
+		RETURNING "id", "created_at";`
+
+	if err := r.db.QueryRowx(
+		query,
+		req.OrderId,
+		req.UserId,
+		req.Internal,
+		req.Note,
+	).Scan(&req.Id, &req.CreatedAt); err != nil {
+		return fmt.Errorf("insert note failed: %v", err)
+	}
+	return nil
+}
+
+// FindOneGuestOrder returns the unclaimed guest order only when the access token matches
+func (r *ordersRepository) FindOneGuestOrder(orderId, tokenHash string) (*orders.Order, error) {
+	query := `
+	SELECT
+		"id"
+	FROM "orders"
+	WHERE "id" = $1
+	AND "user_id" IS NULL
+	AND "guest_token" = $2;`
+
+	var id string
+	if err := r.db.Get(&id, query, orderId, tokenHash); err != nil {
+		return nil, fmt.Errorf("order not found")
+	}
+	return r.FindOneOrder(id)
+}
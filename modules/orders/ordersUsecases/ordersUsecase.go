@@ -1,40 +1,79 @@
 package ordersUsecases
 
 import (
+	"context"
 	"fmt"
 	"math"
+	"time"
 
+	"github.com/LGROW101/lgrow-shop/config"
 	"github.com/LGROW101/lgrow-shop/modules/entities"
+	"github.com/LGROW101/lgrow-shop/modules/files"
+	"github.com/LGROW101/lgrow-shop/modules/files/filesUsecases"
 	"github.com/LGROW101/lgrow-shop/modules/orders"
+	"github.com/LGROW101/lgrow-shop/modules/orders/ordersPatterns"
 	"github.com/LGROW101/lgrow-shop/modules/orders/ordersRepositories"
 	"github.com/LGROW101/lgrow-shop/modules/products/productsRepositories"
-	"github.com/LGROW101/lgrow-shop/pkg/utils"
+	"github.com/LGROW101/lgrow-shop/modules/shipping"
+	"github.com/LGROW101/lgrow-shop/modules/shipping/shippingRepositories"
+	"github.com/LGROW101/lgrow-shop/modules/users/usersRepositories"
+	"github.com/LGROW101/lgrow-shop/pkg/auth"
+	"github.com/LGROW101/lgrow-shop/pkg/invoicepdf"
+	"github.com/LGROW101/lgrow-shop/pkg/promptpay"
+	"github.com/LGROW101/lgrow-shop/pkg/slipqr"
 )
 
 type IOrdersUsecase interface {
-	FindOneOrder(orderId string) (*orders.Order, error)
+	FindOneOrder(orderId string, isAdmin bool) (*orders.Order, error)
 	FindOrder(req *orders.OrderFilter) *entities.PaginateRes
 	InsertOrder(req *orders.Order) (*orders.Order, error)
-	UpdateOrder(req *orders.Order) (*orders.Order, error)
+	UpdateOrder(userId string, req *orders.Order, isAdmin bool) (*orders.Order, error)
+	ShippingQuote(req *orders.ShippingQuoteReq) ([]*shipping.Quote, error)
+	InsertShipment(req *orders.Shipment) (*orders.Order, error)
+	DeliverShipment(orderId, shipmentId string) (*orders.Order, error)
+	PaymentQR(userId, orderId string) (*orders.PaymentQR, error)
+	GuestPaymentQR(orderId, token string) (*orders.PaymentQR, error)
+	UploadTransferSlip(userId string, req *orders.TransferSlip, file *files.FileReq) (*orders.Order, error)
+	GuestUploadTransferSlip(token string, req *orders.TransferSlip, file *files.FileReq) (*orders.Order, error)
+	FindTransferSlip(req *orders.TransferSlipFilter) ([]*orders.TransferSlipReview, error)
+	TransferSlipFile(slipId string) (*orders.TransferSlip, []byte, error)
+	ReviewTransferSlip(req *orders.ReviewTransferSlipReq) (*orders.Order, error)
+	CancelUnpaidOrders(ctx context.Context) (string, error)
+	Invoice(userId, orderId string) (*orders.Invoice, []byte, error)
+	InsertNote(userId string, req *orders.OrderNote, isAdmin bool) (*orders.Order, error)
+	Reorder(userId, orderId string, req *orders.ReorderReq) (*orders.Reorder, error)
+	InsertGuestOrder(req *orders.Order) (*orders.GuestCheckout, error)
+	FindGuestOrder(orderId, token string) (*orders.Order, error)
 }
 
 type ordersUsecase struct {
+	cfg                config.IConfig
 	ordersRepository   ordersRepositories.IOrdersRepository
 	productsRepository productsRepositories.IProductsRepository
+	shippingRepository shippingRepositories.IShippingRepository
+	usersRepository    usersRepositories.IUsersRepository
+	filesUsecase       filesUsecases.IFilesUsecase
 }
 
-func OrdersUsecase(ordersRepository ordersRepositories.IOrdersRepository, productsRepository productsRepositories.IProductsRepository) IOrdersUsecase {
+func OrdersUsecase(cfg config.IConfig, ordersRepository ordersRepositories.IOrdersRepository, productsRepository productsRepositories.IProductsRepository, shippingRepository shippingRepositories.IShippingRepository, usersRepository usersRepositories.IUsersRepository, filesUsecase filesUsecases.IFilesUsecase) IOrdersUsecase {
 	return &ordersUsecase{
+		cfg:                cfg,
 		ordersRepository:   ordersRepository,
 		productsRepository: productsRepository,
+		shippingRepository: shippingRepository,
+		usersRepository:    usersRepository,
+		filesUsecase:       filesUsecase,
 	}
 }
 
-func (u *ordersUsecase) FindOneOrder(orderId string) (*orders.Order, error) {
+func (u *ordersUsecase) FindOneOrder(orderId string, isAdmin bool) (*orders.Order, error) {
 	order, err := u.ordersRepository.FindOneOrder(orderId)
 	if err != nil {
 		return nil, err
 	}
+	if !isAdmin {
+		order.HideInternalNotes()
+	}
 	return order, nil
 }
 
@@ -49,23 +88,89 @@ func (u *ordersUsecase) FindOrder(req *orders.OrderFilter) *entities.PaginateRes
 	}
 }
 
+// resolveProducts replaces each requested product with the current one from the database
+// and returns the subtotal and total weight (kg) of the items
+func (u *ordersUsecase) resolveProducts(req []*orders.ProductsOrder) (float64, float64, error) {
+	var subtotal, weight float64
+	for i := range req {
+		if req[i].Product == nil {
+			return 0, 0, fmt.Errorf("product is nil")
+		}
+		if req[i].Qty < 1 {
+			return 0, 0, fmt.Errorf("qty must more than 0")
+		}
+
+		prod, err := u.productsRepository.FindOneProduct(req[i].Product.Id)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		subtotal += prod.Price * float64(req[i].Qty)
+		weight += prod.Weight * float64(req[i].Qty)
+		req[i].Product = prod
+	}
+	return subtotal, weight, nil
+}
+
 func (u *ordersUsecase) InsertOrder(req *orders.Order) (*orders.Order, error) {
 	// Check if products is exists
-	for i := range req.Products {
-		if req.Products[i].Product == nil {
-			return nil, fmt.Errorf("product is nil")
+	subtotal, weight, err := u.resolveProducts(req.Products)
+	if err != nil {
+		return nil, err
+	}
+
+	// Address book
+	if req.AddressId != "" {
+		address, err := u.usersRepository.FindOneAddress(req.AddressId)
+		if err != nil {
+			return nil, err
+		}
+		if address.UserId != req.UserId {
+			return nil, fmt.Errorf("address not found")
+		}
+
+		req.ShippingAddress = &orders.OrderAddress{
+			Recipient:   address.Recipient,
+			Phone:       address.Phone,
+			Line1:       address.Line1,
+			Line2:       address.Line2,
+			Subdistrict: address.Subdistrict,
+			District:    address.District,
+			Province:    address.Province,
+			Postcode:    address.Postcode,
+		}
+		req.Address = req.ShippingAddress.String()
+		req.Contact = fmt.Sprintf("%s %s", address.Recipient, address.Phone)
+
+		if req.Shipping != nil {
+			req.Shipping.Province = address.Province
+			req.Shipping.Postcode = address.Postcode
+		}
+	}
+
+	// Shipping
+	req.ShippingFee = 0
+	if req.Shipping != nil {
+		method, err := u.shippingRepository.FindOneMethod(req.Shipping.MethodId)
+		if err != nil {
+			return nil, fmt.Errorf("shipping method not found")
+		}
+		if method.IsActive != nil && !*method.IsActive {
+			return nil, fmt.Errorf("shipping method is not available")
 		}
 
-		prod, err := u.productsRepository.FindOneProduct(req.Products[i].Product.Id)
+		zone, err := u.shippingZone(method, req.Shipping.Province, req.Shipping.Postcode)
 		if err != nil {
 			return nil, err
 		}
-		utils.Debug(prod)
 
-		// Set price
-		req.TotalPaid += req.Products[i].Product.Price * float64(req.Products[i].Qty)
-		req.Products[i].Product = prod
+		req.Shipping.ZoneId = zone.Id
+		req.Shipping.Title = method.Title
+		req.Shipping.Type = method.Type
+		req.Shipping.Fee = method.CalculateFee(subtotal, weight)
+		req.ShippingFee = req.Shipping.Fee
 	}
+	req.TotalPaid = subtotal + req.ShippingFee
 
 	orderId, err := u.ordersRepository.InsertOrder(req)
 	if err != nil {
@@ -80,14 +185,518 @@ func (u *ordersUsecase) InsertOrder(req *orders.Order) (*orders.Order, error) {
 	return order, nil
 }
 
-func (u *ordersUsecase) UpdateOrder(req *orders.Order) (*orders.Order, error) {
-	if err := u.ordersRepository.UpdateOrder(req); err != nil {
+func (u *ordersUsecase) UpdateOrder(userId string, req *orders.Order, isAdmin bool) (*orders.Order, error) {
+	if !isAdmin {
+		order, err := u.ordersRepository.FindOneOrder(req.Id)
+		if err != nil || order.UserId != userId {
+			return nil, fmt.Errorf("order not found")
+		}
+	}
+
+	if len(req.Products) > 0 || req.AddressId != "" || req.Address != "" || req.Contact != "" {
+		if err := u.editOrder(req); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := u.ordersRepository.UpdateOrder(req, isAdmin); err != nil {
+		return nil, err
+	}
+
+	order, err := u.ordersRepository.FindOneOrder(req.Id)
+	if err != nil {
 		return nil, err
 	}
+	return order, nil
+}
 
+// editOrder resolves the new items and address of a waiting order,
+// the shipping fee is recalculated with the order shipping method inside the edit transaction
+func (u *ordersUsecase) editOrder(req *orders.Order) error {
 	order, err := u.ordersRepository.FindOneOrder(req.Id)
+	if err != nil {
+		return fmt.Errorf("order not found")
+	}
+	if order.Status != "waiting" {
+		return fmt.Errorf("order can only be edited while waiting")
+	}
+
+	if len(req.Products) > 0 {
+		if _, _, err := u.resolveProducts(req.Products); err != nil {
+			return err
+		}
+	}
+
+	req.ShippingAddress = nil
+	if req.AddressId != "" {
+		address, err := u.usersRepository.FindOneAddress(req.AddressId)
+		if err != nil || address.UserId != order.UserId {
+			return fmt.Errorf("address not found")
+		}
+
+		req.ShippingAddress = &orders.OrderAddress{
+			Recipient:   address.Recipient,
+			Phone:       address.Phone,
+			Line1:       address.Line1,
+			Line2:       address.Line2,
+			Subdistrict: address.Subdistrict,
+			District:    address.District,
+			Province:    address.Province,
+			Postcode:    address.Postcode,
+		}
+		req.Address = req.ShippingAddress.String()
+		req.Contact = fmt.Sprintf("%s %s", address.Recipient, address.Phone)
+	}
+
+	req.Shipping = nil
+	var fee ordersPatterns.ShippingFeeFunc
+	if order.Shipping != nil {
+		req.Shipping = order.Shipping
+		if req.ShippingAddress != nil {
+			req.Shipping.Province = req.ShippingAddress.Province
+			req.Shipping.Postcode = req.ShippingAddress.Postcode
+		}
+
+		method, err := u.shippingRepository.FindOneMethod(order.Shipping.MethodId)
+		if err != nil {
+			return fmt.Errorf("shipping method not found")
+		}
+		if _, err := u.shippingZone(method, req.Shipping.Province, req.Shipping.Postcode); err != nil {
+			return err
+		}
+		fee = method.CalculateFee
+	}
+
+	if err := u.ordersRepository.EditOrder(req, fee); err != nil {
+		return err
+	}
+	return nil
+}
+
+// shippingZone is the zone ShippingQuote picks for the address, the method has to belong to it
+// so a catch-all method cannot be chosen where a more specific zone applies
+func (u *ordersUsecase) shippingZone(method *shipping.Method, province, postcode string) (*shipping.Zone, error) {
+	zone, err := u.shippingRepository.FindZoneByDestination(&shipping.Destination{
+		Province: province,
+		Postcode: postcode,
+	})
+	if err != nil {
+		if err.Error() == "shipping zone not found" {
+			return nil, fmt.Errorf("shipping method is not available for this address")
+		}
+		return nil, err
+	}
+	if method.ZoneId != zone.Id {
+		return nil, fmt.Errorf("shipping method is not available for this address")
+	}
+	return zone, nil
+}
+
+func (u *ordersUsecase) ShippingQuote(req *orders.ShippingQuoteReq) ([]*shipping.Quote, error) {
+	subtotal, weight, err := u.resolveProducts(req.Products)
+	if err != nil {
+		return nil, err
+	}
+
+	zone, err := u.shippingRepository.FindZoneByDestination(&shipping.Destination{
+		Province: req.Province,
+		Postcode: req.Postcode,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	methods, err := u.shippingRepository.FindMethod(&shipping.MethodFilter{
+		ZoneId: zone.Id,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	quotes := make([]*shipping.Quote, 0)
+	for _, m := range methods {
+		if m.IsActive != nil && !*m.IsActive {
+			continue
+		}
+		quotes = append(quotes, &shipping.Quote{
+			MethodId: m.Id,
+			ZoneId:   zone.Id,
+			Title:    m.Title,
+			Type:     m.Type,
+			Fee:      m.CalculateFee(subtotal, weight),
+		})
+	}
+	return quotes, nil
+}
+
+func (u *ordersUsecase) InsertShipment(req *orders.Shipment) (*orders.Order, error) {
+	// Merge the same products_order_id
+	itemsMap := make(map[string]*orders.ShipmentItem)
+	items := make([]*orders.ShipmentItem, 0)
+	for _, item := range req.Items {
+		if itemsMap[item.ProductsOrderId] != nil {
+			itemsMap[item.ProductsOrderId].Qty += item.Qty
+			continue
+		}
+		itemsMap[item.ProductsOrderId] = item
+		items = append(items, item)
+	}
+	req.Items = items
+
+	if _, err := u.ordersRepository.InsertShipment(req); err != nil {
+		return nil, err
+	}
+
+	order, err := u.ordersRepository.FindOneOrder(req.OrderId)
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func (u *ordersUsecase) DeliverShipment(orderId, shipmentId string) (*orders.Order, error) {
+	if err := u.ordersRepository.DeliverShipment(orderId, shipmentId); err != nil {
+		return nil, err
+	}
+
+	order, err := u.ordersRepository.FindOneOrder(orderId)
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func (u *ordersUsecase) PaymentQR(userId, orderId string) (*orders.PaymentQR, error) {
+	order, err := u.ordersRepository.FindOneOrder(orderId)
+	if err != nil {
+		return nil, err
+	}
+	if order.UserId != userId {
+		return nil, fmt.Errorf("order not found")
+	}
+	return u.paymentQR(order)
+}
+
+func (u *ordersUsecase) GuestPaymentQR(orderId, token string) (*orders.PaymentQR, error) {
+	order, err := u.ordersRepository.FindOneGuestOrder(orderId, auth.HashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	return u.paymentQR(order)
+}
+
+func (u *ordersUsecase) paymentQR(order *orders.Order) (*orders.PaymentQR, error) {
+	if order.Status != "waiting" {
+		return nil, fmt.Errorf("order is not waiting for payment")
+	}
+
+	payload, err := promptpay.Payload(u.cfg.Order().PromptPayId(), order.TotalPaid, order.Id)
+	if err != nil {
+		return nil, err
+	}
+	image, err := promptpay.PNG(payload, 512)
+	if err != nil {
+		return nil, err
+	}
+
+	return &orders.PaymentQR{
+		OrderId: order.Id,
+		Amount:  order.TotalPaid,
+		Payload: payload,
+		Image:   image,
+	}, nil
+}
+
+func (u *ordersUsecase) UploadTransferSlip(userId string, req *orders.TransferSlip, file *files.FileReq) (*orders.Order, error) {
+	order, err := u.ordersRepository.FindOneOrder(req.OrderId)
+	if err != nil || order.UserId != userId {
+		return nil, fmt.Errorf("order not found")
+	}
+	return u.uploadTransferSlip(order, req, file)
+}
+
+func (u *ordersUsecase) GuestUploadTransferSlip(token string, req *orders.TransferSlip, file *files.FileReq) (*orders.Order, error) {
+	order, err := u.ordersRepository.FindOneGuestOrder(req.OrderId, auth.HashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	return u.uploadTransferSlip(order, req, file)
+}
+
+func (u *ordersUsecase) uploadTransferSlip(order *orders.Order, req *orders.TransferSlip, file *files.FileReq) (*orders.Order, error) {
+	if order.Status != "waiting" {
+		return nil, fmt.Errorf("order is not waiting for payment")
+	}
+
+	// The transaction reference of the mini-QR keeps a slip from being used twice,
+	// slips without it are only accepted when configured and checked by admins
+	slip, err := u.readSlipQR(file)
+	switch {
+	case err == nil:
+		req.SendingBank = slip.Bank
+		if req.SendingBank == "" {
+			req.SendingBank = slip.BankCode
+		}
+		req.TransRef = slip.TransRef
+	case !u.cfg.Order().AllowUnreadableSlip():
+		return nil, fmt.Errorf("transfer slip qr is unreadable")
+	}
+
+	res, err := u.filesUsecase.UploadPrivate(file)
+	if err != nil {
+		return nil, err
+	}
+	req.FileName = res.FileName
+	req.Destination = file.Destination
+
+	if err := u.ordersRepository.InsertTransferSlip(req); err != nil {
+		return nil, err
+	}
+
+	return u.ordersRepository.FindOneOrder(req.OrderId)
+}
+
+func (u *ordersUsecase) readSlipQR(file *files.FileReq) (*slipqr.Slip, error) {
+	f, err := file.File.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	payload, err := slipqr.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	return slipqr.Parse(payload)
+}
+
+func (u *ordersUsecase) FindTransferSlip(req *orders.TransferSlipFilter) ([]*orders.TransferSlipReview, error) {
+	slips, err := u.ordersRepository.FindTransferSlip(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, slip := range slips {
+		slip.QrUnreadable = slip.TransRef == ""
+	}
+	return slips, nil
+}
+
+func (u *ordersUsecase) TransferSlipFile(slipId string) (*orders.TransferSlip, []byte, error) {
+	slip, err := u.ordersRepository.FindOneTransferSlip(slipId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b, err := u.filesUsecase.ReadPrivate(slip.Destination)
+	if err != nil {
+		return nil, nil, err
+	}
+	return slip, b, nil
+}
+
+func (u *ordersUsecase) ReviewTransferSlip(req *orders.ReviewTransferSlipReq) (*orders.Order, error) {
+	if err := u.ordersRepository.ReviewTransferSlip(req); err != nil {
+		return nil, err
+	}
+
+	slip, err := u.ordersRepository.FindOneTransferSlip(req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := u.ordersRepository.FindOneOrder(slip.OrderId)
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func (u *ordersUsecase) CancelUnpaidOrders(ctx context.Context) (string, error) {
+	orderIds, err := u.ordersRepository.CancelUnpaidOrders(ctx, u.cfg.Order().UnpaidTimeout())
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d orders canceled", len(orderIds)), nil
+}
+
+func (u *ordersUsecase) Invoice(userId, orderId string) (*orders.Invoice, []byte, error) {
+	order, err := u.ordersRepository.FindOneOrder(orderId)
+	if err != nil || order.UserId != userId {
+		return nil, nil, fmt.Errorf("order not found")
+	}
+
+	invoice, err := u.ordersRepository.InsertInvoice(order.Id, orders.FiscalYear(time.Now(), u.cfg.Store().FiscalYearStart()))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req := &invoicepdf.Invoice{
+		No:          invoice.InvoiceNo,
+		IssuedAt:    invoice.IssuedAt,
+		OrderId:     order.Id,
+		Address:     order.Address,
+		Contact:     order.Contact,
+		Lines:       make([]*invoicepdf.Line, 0),
+		ShippingFee: order.ShippingFee,
+		VatRate:     u.cfg.Store().VatRate(),
+		Store: &invoicepdf.Store{
+			Name:    u.cfg.Store().Name(),
+			Address: u.cfg.Store().Address(),
+			TaxId:   u.cfg.Store().TaxId(),
+			Phone:   u.cfg.Store().Phone(),
+		},
+	}
+	if issuedAt, err := time.Parse(time.RFC3339Nano, invoice.IssuedAt); err == nil {
+		req.IssuedAt = issuedAt.Format("2006-01-02")
+	}
+	if order.ShippingAddress != nil {
+		req.Customer = order.ShippingAddress.Recipient
+		req.Address = order.ShippingAddress.String()
+	}
+	if req.Customer == "" {
+		if profile, err := u.usersRepository.GetProfile(order.UserId); err == nil {
+			req.Customer = profile.Username
+		}
+	}
+	if order.Shipping != nil {
+		req.ShippingTitle = order.Shipping.Title
+	}
+	for _, p := range order.Products {
+		if p.Product == nil {
+			continue
+		}
+		req.Lines = append(req.Lines, &invoicepdf.Line{
+			Title: p.Product.Title,
+			Qty:   p.Qty,
+			Price: p.Product.Price,
+		})
+	}
+
+	file, err := invoicepdf.Render(req, u.cfg.Store().InvoiceFont())
+	if err != nil {
+		return nil, nil, err
+	}
+	return invoice, file, nil
+}
+
+func (u *ordersUsecase) InsertNote(userId string, req *orders.OrderNote, isAdmin bool) (*orders.Order, error) {
+	order, err := u.ordersRepository.FindOneOrder(req.OrderId)
+	if err != nil || order.UserId != userId {
+		return nil, fmt.Errorf("order not found")
+	}
+
+	if err := u.ordersRepository.InsertNote(req); err != nil {
+		return nil, err
+	}
+	return u.FindOneOrder(req.OrderId, isAdmin)
+}
+
+// Reorder places the items of a previous order again at the current prices,
+// items that cannot be bought anymore are left out and reported
+func (u *ordersUsecase) Reorder(userId, orderId string, req *orders.ReorderReq) (*orders.Reorder, error) {
+	prev, err := u.ordersRepository.FindOneOrder(orderId)
+	if err != nil || prev.UserId != userId {
+		return nil, fmt.Errorf("order not found")
+	}
+
+	res := &orders.Reorder{
+		Unavailable:  make([]*orders.ReorderItem, 0),
+		PriceChanged: make([]*orders.ReorderItem, 0),
+	}
+	newOrder := &orders.Order{
+		UserId:          userId,
+		Products:        make([]*orders.ProductsOrder, 0),
+		AddressId:       req.AddressId,
+		Address:         prev.Address,
+		ShippingAddress: prev.ShippingAddress,
+		Contact:         prev.Contact,
+		Status:          "waiting",
+	}
+
+	for _, p := range prev.Products {
+		if p.Product == nil {
+			continue
+		}
+		item := &orders.ReorderItem{
+			ProductId: p.Product.Id,
+			Title:     p.Product.Title,
+			Qty:       p.Qty,
+			OldPrice:  p.Product.Price,
+		}
+
+		prod, err := u.productsRepository.FindOneProduct(p.Product.Id)
+		if err != nil {
+			item.Reason = "product not found"
+			res.Unavailable = append(res.Unavailable, item)
+			continue
+		}
+		item.Title = prod.Title
+		item.Price = prod.Price
+
+		if prod.Stock != nil && *prod.Stock < p.Qty {
+			item.Reason = "out of stock"
+			res.Unavailable = append(res.Unavailable, item)
+			continue
+		}
+		if prod.Price != p.Product.Price {
+			res.PriceChanged = append(res.PriceChanged, item)
+		}
+
+		newOrder.Products = append(newOrder.Products, &orders.ProductsOrder{
+			Qty:     p.Qty,
+			Product: prod,
+		})
+	}
+	if len(newOrder.Products) == 0 {
+		return nil, fmt.Errorf("no products available to reorder")
+	}
+
+	// Same shipping method as before unless a new one is chosen
+	switch {
+	case req.Shipping != nil:
+		newOrder.Shipping = &orders.OrderShipping{
+			MethodId: req.Shipping.MethodId,
+			Province: req.Shipping.Province,
+			Postcode: req.Shipping.Postcode,
+		}
+	case prev.Shipping != nil:
+		newOrder.Shipping = &orders.OrderShipping{
+			MethodId: prev.Shipping.MethodId,
+			Province: prev.Shipping.Province,
+			Postcode: prev.Shipping.Postcode,
+		}
+	}
+
+	order, err := u.InsertOrder(newOrder)
+	if err != nil {
+		return nil, err
+	}
+	res.Order = order
+	return res, nil
+}
+
+func (u *ordersUsecase) InsertGuestOrder(req *orders.Order) (*orders.GuestCheckout, error) {
+	token, err := orders.NewGuestToken()
+	if err != nil {
+		return nil, err
+	}
+	req.GuestToken = auth.HashToken(token)
+
+	order, err := u.InsertOrder(req)
+	if err != nil {
+		return nil, err
+	}
+	return &orders.GuestCheckout{
+		Order:       order,
+		AccessToken: token,
+	}, nil
+}
+
+func (u *ordersUsecase) FindGuestOrder(orderId, token string) (*orders.Order, error) {
+	order, err := u.ordersRepository.FindOneGuestOrder(orderId, auth.HashToken(token))
 	if err != nil {
 		return nil, err
 	}
+	order.HideInternalNotes()
 	return order, nil
 }
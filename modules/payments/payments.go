@@ -0,0 +1,50 @@
+package payments
+
+type Payment struct {
+	Id          string  `db:"id" json:"id"`
+	OrderId     string  `db:"order_id" json:"order_id"`
+	Provider    string  `db:"provider" json:"provider"`
+	ProviderRef string  `db:"provider_ref" json:"provider_ref"`
+	Amount      float64 `db:"amount" json:"amount"`
+	Status      string  `db:"status" json:"status"` // pending | succeeded | failed | refunded
+	Error       string  `db:"error" json:"error"`
+	CreatedAt   string  `db:"created_at" json:"created_at"`
+	UpdatedAt   string  `db:"updated_at" json:"updated_at"`
+}
+
+type PaymentReq struct {
+	Provider string `json:"provider" form:"provider"`
+}
+
+// Intent is what the client needs to complete the payment with the provider
+type Intent struct {
+	Payment      *Payment `json:"payment"`
+	ClientSecret string   `json:"client_secret"`
+}
+
+type ConfirmReq struct {
+	PaymentId string `json:"payment_id"`
+	Token     string `json:"token" form:"token"`
+}
+
+type RefundReq struct {
+	PaymentId string  `json:"payment_id"`
+	Amount    float64 `json:"amount" form:"amount"`
+}
+
+// ProviderResult is the state of a payment on the provider side
+type ProviderResult struct {
+	ProviderRef string `json:"provider_ref"`
+	Status      string `json:"status"`
+	Error       string `json:"error"`
+}
+
+type WebhookEvent struct {
+	ProviderRef string `json:"provider_ref"`
+	Status      string `json:"status"`
+	Error       string `json:"error"`
+}
+
+func (obj *Payment) IsFinal() bool {
+	return obj.Status == "succeeded" || obj.Status == "refunded"
+}
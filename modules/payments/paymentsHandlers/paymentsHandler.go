@@ -0,0 +1,222 @@
+package paymentsHandlers
+
+import (
+	"strings"
+
+	"github.com/LGROW101/lgrow-shop/config"
+	"github.com/LGROW101/lgrow-shop/modules/entities"
+	"github.com/LGROW101/lgrow-shop/modules/payments"
+	"github.com/LGROW101/lgrow-shop/modules/payments/paymentsUsecases"
+	"github.com/gofiber/fiber/v2"
+)
+
+type paymentsHandlersErrCode string
+
+const (
+	findPaymentErr    paymentsHandlersErrCode = "payments-001"
+	createPaymentErr  paymentsHandlersErrCode = "payments-002"
+	confirmPaymentErr paymentsHandlersErrCode = "payments-003"
+	refundPaymentErr  paymentsHandlersErrCode = "payments-004"
+	webhookErr        paymentsHandlersErrCode = "payments-005"
+)
+
+type IPaymentsHandler interface {
+	FindPayment(c *fiber.Ctx) error
+	CreatePayment(c *fiber.Ctx) error
+	CreateGuestPayment(c *fiber.Ctx) error
+	ConfirmPayment(c *fiber.Ctx) error
+	ConfirmGuestPayment(c *fiber.Ctx) error
+	RefundPayment(c *fiber.Ctx) error
+	Webhook(c *fiber.Ctx) error
+}
+
+type paymentsHandler struct {
+	cfg             config.IConfig
+	paymentsUsecase paymentsUsecases.IPaymentsUsecase
+}
+
+func PaymentsHandler(cfg config.IConfig, paymentsUsecase paymentsUsecases.IPaymentsUsecase) IPaymentsHandler {
+	return &paymentsHandler{
+		cfg:             cfg,
+		paymentsUsecase: paymentsUsecase,
+	}
+}
+
+func (h *paymentsHandler) FindPayment(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+	orderId := strings.Trim(c.Params("order_id"), " ")
+
+	paymentsData, err := h.paymentsUsecase.FindPayment(userId, orderId)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(findPaymentErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, paymentsData).Res()
+}
+
+func (h *paymentsHandler) CreatePayment(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+	orderId := strings.Trim(c.Params("order_id"), " ")
+
+	req := new(payments.PaymentReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(createPaymentErr),
+			err.Error(),
+		).Res()
+	}
+	req.Provider = strings.ToLower(strings.TrimSpace(req.Provider))
+
+	intent, err := h.paymentsUsecase.CreatePayment(userId, orderId, req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(createPaymentErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, intent).Res()
+}
+
+func (h *paymentsHandler) CreateGuestPayment(c *fiber.Ctx) error {
+	orderId := strings.Trim(c.Params("order_id"), " ")
+
+	// X-Order-Token header of the guest order
+	token := c.Get("X-Order-Token")
+	if token == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(createPaymentErr),
+			"token is required",
+		).Res()
+	}
+
+	req := new(payments.PaymentReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(createPaymentErr),
+			err.Error(),
+		).Res()
+	}
+	req.Provider = strings.ToLower(strings.TrimSpace(req.Provider))
+
+	intent, err := h.paymentsUsecase.CreateGuestPayment(orderId, token, req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(createPaymentErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, intent).Res()
+}
+
+func (h *paymentsHandler) ConfirmPayment(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+
+	req := new(payments.ConfirmReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(confirmPaymentErr),
+			err.Error(),
+		).Res()
+	}
+	req.PaymentId = strings.Trim(c.Params("payment_id"), " ")
+
+	payment, err := h.paymentsUsecase.ConfirmPayment(userId, req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(confirmPaymentErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, payment).Res()
+}
+
+func (h *paymentsHandler) ConfirmGuestPayment(c *fiber.Ctx) error {
+	token := c.Get("X-Order-Token")
+	if token == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(confirmPaymentErr),
+			"token is required",
+		).Res()
+	}
+
+	req := new(payments.ConfirmReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(confirmPaymentErr),
+			err.Error(),
+		).Res()
+	}
+	req.PaymentId = strings.Trim(c.Params("payment_id"), " ")
+
+	payment, err := h.paymentsUsecase.ConfirmGuestPayment(token, req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(confirmPaymentErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, payment).Res()
+}
+
+func (h *paymentsHandler) RefundPayment(c *fiber.Ctx) error {
+	req := new(payments.RefundReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(refundPaymentErr),
+			err.Error(),
+		).Res()
+	}
+	req.PaymentId = strings.Trim(c.Params("payment_id"), " ")
+
+	payment, err := h.paymentsUsecase.RefundPayment(req, c.Locals("userId").(string))
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(refundPaymentErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, payment).Res()
+}
+
+func (h *paymentsHandler) Webhook(c *fiber.Ctx) error {
+	provider := strings.ToLower(strings.Trim(c.Params("provider"), " "))
+
+	headers := make(map[string]string)
+	c.Request().Header.VisitAll(func(key, value []byte) {
+		headers[string(key)] = string(value)
+	})
+
+	payment, err := h.paymentsUsecase.Webhook(provider, headers, c.Body())
+	if err != nil {
+		switch err.Error() {
+		case "signature is invalid":
+			return entities.NewResponse(c).Error(
+				fiber.ErrUnauthorized.Code,
+				string(webhookErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(webhookErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, payment).Res()
+}
@@ -0,0 +1,133 @@
+package paymentsProviders
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/LGROW101/lgrow-shop/modules/payments"
+	"github.com/google/uuid"
+)
+
+const (
+	MockProviderName    = "mock"
+	MockSignatureHeader = "X-Mock-Signature"
+	// Confirming with this token simulates a declined payment
+	MockDeclineToken = "tok_declined"
+)
+
+type mockIntent struct {
+	amount   float64
+	refunded float64
+	status   string
+}
+
+// mockProvider keeps intents in memory, used for tests and local development
+type mockProvider struct {
+	secret  string
+	mu      sync.Mutex
+	intents map[string]*mockIntent
+}
+
+func MockProvider(secret string) IPaymentProvider {
+	return &mockProvider{
+		secret:  secret,
+		intents: make(map[string]*mockIntent),
+	}
+}
+
+func (p *mockProvider) Name() string { return MockProviderName }
+
+func (p *mockProvider) CreateIntent(req *payments.Payment) (*payments.Intent, error) {
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("amount must more than 0")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ref := "mock_" + uuid.NewString()
+	p.intents[ref] = &mockIntent{
+		amount: req.Amount,
+		status: "pending",
+	}
+
+	req.ProviderRef = ref
+	return &payments.Intent{
+		Payment:      req,
+		ClientSecret: ref + "_secret",
+	}, nil
+}
+
+func (p *mockProvider) Confirm(providerRef string, req *payments.ConfirmReq) (*payments.ProviderResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	intent, ok := p.intents[providerRef]
+	if !ok {
+		return nil, fmt.Errorf("payment intent not found")
+	}
+	if intent.status != "pending" {
+		return nil, fmt.Errorf("payment intent has been %s", intent.status)
+	}
+
+	result := &payments.ProviderResult{ProviderRef: providerRef}
+	if req.Token == MockDeclineToken {
+		intent.status = "failed"
+		result.Error = "card declined"
+	} else {
+		intent.status = "succeeded"
+	}
+	result.Status = intent.status
+	return result, nil
+}
+
+func (p *mockProvider) Refund(providerRef string, amount float64) (*payments.ProviderResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	intent, ok := p.intents[providerRef]
+	if !ok {
+		return nil, fmt.Errorf("payment intent not found")
+	}
+	if intent.status != "succeeded" {
+		return nil, fmt.Errorf("payment intent has not succeeded")
+	}
+	if amount <= 0 || intent.refunded+amount > intent.amount {
+		return nil, fmt.Errorf("refund amount is invalid")
+	}
+
+	intent.refunded += amount
+	if intent.refunded == intent.amount {
+		intent.status = "refunded"
+	}
+	return &payments.ProviderResult{
+		ProviderRef: providerRef,
+		Status:      intent.status,
+	}, nil
+}
+
+// MockSignature returns the signature the mock provider expects in X-Mock-Signature
+func MockSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (p *mockProvider) VerifyWebhook(headers map[string]string, body []byte) (*payments.WebhookEvent, error) {
+	if p.secret == "" || !hmac.Equal([]byte(headers[MockSignatureHeader]), []byte(MockSignature(p.secret, body))) {
+		return nil, fmt.Errorf("signature is invalid")
+	}
+
+	event := new(payments.WebhookEvent)
+	if err := json.Unmarshal(body, event); err != nil {
+		return nil, fmt.Errorf("unmarshal webhook event failed: %v", err)
+	}
+	if event.ProviderRef == "" {
+		return nil, fmt.Errorf("provider ref is empty")
+	}
+	return event, nil
+}
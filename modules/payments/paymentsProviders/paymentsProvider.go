@@ -0,0 +1,54 @@
+package paymentsProviders
+
+import (
+	"fmt"
+
+	"github.com/LGROW101/lgrow-shop/modules/payments"
+)
+
+type IPaymentProvider interface {
+	Name() string
+	CreateIntent(req *payments.Payment) (*payments.Intent, error)
+	Confirm(providerRef string, req *payments.ConfirmReq) (*payments.ProviderResult, error)
+	Refund(providerRef string, amount float64) (*payments.ProviderResult, error)
+	VerifyWebhook(headers map[string]string, body []byte) (*payments.WebhookEvent, error)
+}
+
+type IPaymentProviders interface {
+	Get(name string) (IPaymentProvider, error)
+	Default() string
+}
+
+type paymentProviders struct {
+	defaultName string
+	providers   map[string]IPaymentProvider
+}
+
+// PaymentProviders registers the given providers, the first one is used when none is requested
+func PaymentProviders(providers ...IPaymentProvider) IPaymentProviders {
+	p := &paymentProviders{
+		providers: make(map[string]IPaymentProvider),
+	}
+	for _, provider := range providers {
+		if p.defaultName == "" {
+			p.defaultName = provider.Name()
+		}
+		p.providers[provider.Name()] = provider
+	}
+	return p
+}
+
+func (p *paymentProviders) Get(name string) (IPaymentProvider, error) {
+	if name == "" {
+		name = p.defaultName
+	}
+	provider, ok := p.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("payment provider not found")
+	}
+	return provider, nil
+}
+
+func (p *paymentProviders) Default() string {
+	return p.defaultName
+}
@@ -0,0 +1,241 @@
+package paymentsRepositories
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/LGROW101/lgrow-shop/modules/credits/creditsPatterns"
+	"github.com/LGROW101/lgrow-shop/modules/orders/ordersPatterns"
+	"github.com/LGROW101/lgrow-shop/modules/payments"
+	"github.com/jmoiron/sqlx"
+)
+
+type IPaymentsRepository interface {
+	FindOnePayment(paymentId string) (*payments.Payment, error)
+	FindOnePaymentByRef(provider, providerRef string) (*payments.Payment, error)
+	FindPayment(orderId string) ([]*payments.Payment, error)
+	InsertPayment(req *payments.Payment) error
+	UpdatePayment(req *payments.Payment) (bool, error)
+	RefundPayment(req *payments.Payment, amount float64, createdBy string) error
+}
+
+type paymentsRepository struct {
+	db *sqlx.DB
+}
+
+func PaymentsRepository(db *sqlx.DB) IPaymentsRepository {
+	return &paymentsRepository{db: db}
+}
+
+func (r *paymentsRepository) FindOnePayment(paymentId string) (*payments.Payment, error) {
+	query := `
+	SELECT
+		"id",
+		"order_id",
+		"provider",
+		"provider_ref",
+		"amount",
+		"status",
+		"error",
+		"created_at",
+		"updated_at"
+	FROM "payments"
+	WHERE "id" = $1;`
+
+	payment := new(payments.Payment)
+	if err := r.db.Get(payment, query, paymentId); err != nil {
+		return nil, fmt.Errorf("payment not found")
+	}
+	return payment, nil
+}
+
+func (r *paymentsRepository) FindOnePaymentByRef(provider, providerRef string) (*payments.Payment, error) {
+	query := `
+	SELECT
+		"id",
+		"order_id",
+		"provider",
+		"provider_ref",
+		"amount",
+		"status",
+		"error",
+		"created_at",
+		"updated_at"
+	FROM "payments"
+	WHERE "provider" = $1
+	AND "provider_ref" = $2;`
+
+	payment := new(payments.Payment)
+	if err := r.db.Get(payment, query, provider, providerRef); err != nil {
+		return nil, fmt.Errorf("payment not found")
+	}
+	return payment, nil
+}
+
+func (r *paymentsRepository) FindPayment(orderId string) ([]*payments.Payment, error) {
+	query := `
+	SELECT
+		"id",
+		"order_id",
+		"provider",
+		"provider_ref",
+		"amount",
+		"status",
+		"error",
+		"created_at",
+		"updated_at"
+	FROM "payments"
+	WHERE "order_id" = $1
+	ORDER BY "created_at" DESC;`
+
+	paymentsData := make([]*payments.Payment, 0)
+	if err := r.db.Select(&paymentsData, query, orderId); err != nil {
+		return nil, fmt.Errorf("get payments failed: %v", err)
+	}
+	return paymentsData, nil
+}
+
+func (r *paymentsRepository) InsertPayment(req *payments.Payment) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := `
+	INSERT INTO "payments" (
+		"order_id",
+		"provider",
+		"amount"
+	)
+	VALUES ($1, $2, $3)
+		RETURNING "id", "status";`
+
+	if err := r.db.QueryRowxContext(
+		ctx,
+		query,
+		req.OrderId,
+		req.Provider,
+		req.Amount,
+	).Scan(&req.Id, &req.Status); err != nil {
+		return fmt.Errorf("insert payment failed: %v", err)
+	}
+	return nil
+}
+
+// UpdatePayment saves the provider state, a succeeded payment moves the order from waiting to paid
+// when it covers the amount due. It tells when the payment succeeded after the order left waiting
+// or for less than is due, that payment has to be refunded
+func (r *paymentsRepository) UpdatePayment(req *payments.Payment) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	// The order first, as the order edit does
+	var orderStatus string
+	if err := tx.GetContext(ctx, &orderStatus, `SELECT "status" FROM "orders" WHERE "id" = $1 FOR UPDATE;`, req.OrderId); err != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("order not found")
+	}
+
+	var oldStatus string
+	if err := tx.GetContext(ctx, &oldStatus, `SELECT "status" FROM "payments" WHERE "id" = $1 FOR UPDATE;`, req.Id); err != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("payment not found")
+	}
+
+	query := `
+	UPDATE "payments" SET
+		"provider_ref" = $1,
+		"status" = $2,
+		"error" = $3
+	WHERE "id" = $4;`
+
+	if _, err := tx.ExecContext(ctx, query, req.ProviderRef, req.Status, req.Error, req.Id); err != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("update payment failed: %v", err)
+	}
+
+	late := false
+	if req.Status == "succeeded" && oldStatus != "succeeded" {
+		due, err := ordersPatterns.AmountDue(ctx, tx, req.OrderId)
+		if err != nil {
+			tx.Rollback()
+			return false, err
+		}
+
+		note := fmt.Sprintf("paid %.2f by %s (%s)", req.Amount, req.Provider, req.ProviderRef)
+		switch {
+		case orderStatus != "waiting":
+			// Canceled or paid by another payment in the meantime, the order stays as it is
+			late = true
+			note += fmt.Sprintf(" after the order was %s, payment has to be refunded", orderStatus)
+		case math.Round(req.Amount*100)/100 < due:
+			// The order was edited after the payment was created
+			late = true
+			note += fmt.Sprintf(" but %.2f is due, payment has to be refunded", due)
+		default:
+			if _, err := tx.ExecContext(ctx, `UPDATE "orders" SET "status" = 'paid' WHERE "id" = $1;`, req.OrderId); err != nil {
+				tx.Rollback()
+				return false, fmt.Errorf("update order status failed: %v", err)
+			}
+			if err := creditsPatterns.ActivateGiftCards(ctx, tx, req.OrderId); err != nil {
+				tx.Rollback()
+				return false, err
+			}
+		}
+		if err := ordersPatterns.InsertOrderHistory(ctx, tx, req.OrderId, note); err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return late, nil
+}
+
+// RefundPayment records the refund against the order and updates the payment status
+func (r *paymentsRepository) RefundPayment(req *payments.Payment, amount float64, createdBy string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE "payments" SET "status" = $1 WHERE "id" = $2;`, req.Status, req.Id); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("update payment failed: %v", err)
+	}
+
+	queryRefund := `
+	INSERT INTO "refunds" (
+		"order_id",
+		"amount",
+		"method",
+		"reference",
+		"created_by"
+	)
+	VALUES ($1, $2, $3, $4, $5);`
+
+	if _, err := tx.ExecContext(ctx, queryRefund, req.OrderId, amount, req.Provider, req.ProviderRef, createdBy); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("insert refund failed: %v", err)
+	}
+
+	if err := ordersPatterns.InsertOrderHistory(ctx, tx, req.OrderId, fmt.Sprintf("refunded %.2f by %s (%s)", amount, req.Provider, req.ProviderRef)); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
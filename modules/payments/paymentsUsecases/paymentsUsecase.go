@@ -0,0 +1,253 @@
+package paymentsUsecases
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/LGROW101/lgrow-shop/modules/orders"
+	"github.com/LGROW101/lgrow-shop/modules/orders/ordersRepositories"
+	"github.com/LGROW101/lgrow-shop/modules/payments"
+	"github.com/LGROW101/lgrow-shop/modules/payments/paymentsProviders"
+	"github.com/LGROW101/lgrow-shop/modules/payments/paymentsRepositories"
+	"github.com/LGROW101/lgrow-shop/pkg/auth"
+)
+
+type IPaymentsUsecase interface {
+	FindPayment(userId, orderId string) ([]*payments.Payment, error)
+	CreatePayment(userId, orderId string, req *payments.PaymentReq) (*payments.Intent, error)
+	CreateGuestPayment(orderId, token string, req *payments.PaymentReq) (*payments.Intent, error)
+	ConfirmPayment(userId string, req *payments.ConfirmReq) (*payments.Payment, error)
+	ConfirmGuestPayment(token string, req *payments.ConfirmReq) (*payments.Payment, error)
+	RefundPayment(req *payments.RefundReq, createdBy string) (*payments.Payment, error)
+	Webhook(provider string, headers map[string]string, body []byte) (*payments.Payment, error)
+}
+
+type paymentsUsecase struct {
+	providers          paymentsProviders.IPaymentProviders
+	paymentsRepository paymentsRepositories.IPaymentsRepository
+	ordersRepository   ordersRepositories.IOrdersRepository
+}
+
+func PaymentsUsecase(providers paymentsProviders.IPaymentProviders, paymentsRepository paymentsRepositories.IPaymentsRepository, ordersRepository ordersRepositories.IOrdersRepository) IPaymentsUsecase {
+	return &paymentsUsecase{
+		providers:          providers,
+		paymentsRepository: paymentsRepository,
+		ordersRepository:   ordersRepository,
+	}
+}
+
+func (u *paymentsUsecase) FindPayment(userId, orderId string) ([]*payments.Payment, error) {
+	order, err := u.ordersRepository.FindOneOrder(orderId)
+	if err != nil || order.UserId != userId {
+		return nil, fmt.Errorf("order not found")
+	}
+
+	paymentsData, err := u.paymentsRepository.FindPayment(orderId)
+	if err != nil {
+		return nil, err
+	}
+	return paymentsData, nil
+}
+
+func (u *paymentsUsecase) CreatePayment(userId, orderId string, req *payments.PaymentReq) (*payments.Intent, error) {
+	order, err := u.ordersRepository.FindOneOrder(orderId)
+	if err != nil || order.UserId != userId {
+		return nil, fmt.Errorf("order not found")
+	}
+	return u.createPayment(order, req)
+}
+
+func (u *paymentsUsecase) CreateGuestPayment(orderId, token string, req *payments.PaymentReq) (*payments.Intent, error) {
+	order, err := u.ordersRepository.FindOneGuestOrder(orderId, auth.HashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	return u.createPayment(order, req)
+}
+
+func (u *paymentsUsecase) createPayment(order *orders.Order, req *payments.PaymentReq) (*payments.Intent, error) {
+	provider, err := u.providers.Get(req.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	if order.Status != "waiting" {
+		return nil, fmt.Errorf("order is not waiting for payment")
+	}
+
+	payment := &payments.Payment{
+		OrderId:  order.Id,
+		Provider: provider.Name(),
+		Amount:   order.TotalPaid,
+	}
+	if err := u.paymentsRepository.InsertPayment(payment); err != nil {
+		return nil, err
+	}
+
+	intent, err := provider.CreateIntent(payment)
+	if err != nil {
+		payment.Status = "failed"
+		payment.Error = err.Error()
+		if _, err := u.paymentsRepository.UpdatePayment(payment); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("create payment intent failed: %v", payment.Error)
+	}
+
+	late, err := u.paymentsRepository.UpdatePayment(intent.Payment)
+	if err != nil {
+		return nil, err
+	}
+	if late {
+		u.refundLatePayment(provider, intent.Payment)
+	}
+	return intent, nil
+}
+
+func (u *paymentsUsecase) ConfirmPayment(userId string, req *payments.ConfirmReq) (*payments.Payment, error) {
+	payment, err := u.paymentsRepository.FindOnePayment(req.PaymentId)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := u.ordersRepository.FindOneOrder(payment.OrderId)
+	if err != nil || order.UserId != userId {
+		return nil, fmt.Errorf("payment not found")
+	}
+	return u.confirmPayment(payment, req)
+}
+
+func (u *paymentsUsecase) ConfirmGuestPayment(token string, req *payments.ConfirmReq) (*payments.Payment, error) {
+	payment, err := u.paymentsRepository.FindOnePayment(req.PaymentId)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := u.ordersRepository.FindOneGuestOrder(payment.OrderId, auth.HashToken(token)); err != nil {
+		return nil, fmt.Errorf("payment not found")
+	}
+	return u.confirmPayment(payment, req)
+}
+
+func (u *paymentsUsecase) confirmPayment(payment *payments.Payment, req *payments.ConfirmReq) (*payments.Payment, error) {
+	if payment.Status != "pending" {
+		return nil, fmt.Errorf("payment has been %s", payment.Status)
+	}
+
+	provider, err := u.providers.Get(payment.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := provider.Confirm(payment.ProviderRef, req)
+	if err != nil {
+		return nil, err
+	}
+
+	payment.Status = result.Status
+	payment.Error = result.Error
+	late, err := u.paymentsRepository.UpdatePayment(payment)
+	if err != nil {
+		return nil, err
+	}
+	if late {
+		u.refundLatePayment(provider, payment)
+	}
+	return u.paymentsRepository.FindOnePayment(payment.Id)
+}
+
+func (u *paymentsUsecase) RefundPayment(req *payments.RefundReq, createdBy string) (*payments.Payment, error) {
+	payment, err := u.paymentsRepository.FindOnePayment(req.PaymentId)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Status != "succeeded" {
+		return nil, fmt.Errorf("payment has not succeeded")
+	}
+
+	// Refunds of returns count against the same payment
+	order, err := u.ordersRepository.FindOneOrder(payment.OrderId)
+	if err != nil {
+		return nil, err
+	}
+	if req.Amount <= 0 {
+		req.Amount = payment.Amount - order.TotalRefunded
+	}
+	if req.Amount <= 0 || req.Amount > payment.Amount-order.TotalRefunded {
+		return nil, fmt.Errorf("refund amount is invalid")
+	}
+
+	provider, err := u.providers.Get(payment.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := provider.Refund(payment.ProviderRef, req.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	payment.Status = "succeeded"
+	if result.Status == "refunded" {
+		payment.Status = "refunded"
+	}
+	if err := u.paymentsRepository.RefundPayment(payment, req.Amount, createdBy); err != nil {
+		return nil, err
+	}
+	return u.paymentsRepository.FindOnePayment(payment.Id)
+}
+
+func (u *paymentsUsecase) Webhook(providerName string, headers map[string]string, body []byte) (*payments.Payment, error) {
+	provider, err := u.providers.Get(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := provider.VerifyWebhook(headers, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if event.Status != "succeeded" && event.Status != "failed" {
+		return nil, fmt.Errorf("event status is invalid")
+	}
+
+	payment, err := u.paymentsRepository.FindOnePaymentByRef(provider.Name(), event.ProviderRef)
+	if err != nil {
+		return nil, err
+	}
+
+	// Providers may deliver the same event more than once
+	if payment.IsFinal() || payment.Status == event.Status {
+		return payment, nil
+	}
+
+	payment.Status = event.Status
+	payment.Error = event.Error
+	late, err := u.paymentsRepository.UpdatePayment(payment)
+	if err != nil {
+		return nil, err
+	}
+	if late {
+		u.refundLatePayment(provider, payment)
+	}
+	return u.paymentsRepository.FindOnePayment(payment.Id)
+}
+
+// refundLatePayment gives back a payment that succeeded after its order was canceled or paid or for less than is due,
+// when the provider refuses it the order history keeps asking for the refund
+func (u *paymentsUsecase) refundLatePayment(provider paymentsProviders.IPaymentProvider, payment *payments.Payment) {
+	result, err := provider.Refund(payment.ProviderRef, payment.Amount)
+	if err != nil {
+		log.Printf("refund late payment %s failed: %v", payment.Id, err)
+		return
+	}
+
+	payment.Status = "succeeded"
+	if result.Status == "refunded" {
+		payment.Status = "refunded"
+	}
+	if err := u.paymentsRepository.RefundPayment(payment, payment.Amount, "system"); err != nil {
+		log.Printf("refund late payment %s failed: %v", payment.Id, err)
+	}
+}
@@ -13,6 +13,8 @@ type Product struct {
 	CreatedAt   string            `json:"created_at"`
 	UpdatedAt   string            `json:"updated_at"`
 	Price       float64           `json:"price"`
+	Weight      float64           `json:"weight"` // kg
+	Stock       *int              `json:"stock"`  // nil -> not tracked
 	Images      []*entities.Image `json:"images"`
 }
 
@@ -56,6 +56,8 @@ func (b *findProductBuilder) initQuery() {
 			"p"."title",
 			"p"."description",
 			"p"."price",
+			"p"."weight",
+			"p"."stock",
 			(
 				SELECT
 					to_jsonb("ct")
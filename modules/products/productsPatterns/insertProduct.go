@@ -51,9 +51,11 @@ func (b *insertProductBuilder) insertProduct() error {
 	INSERT INTO "products" (
 		"title",
 		"description",
-		"price"
+		"price",
+		"weight",
+		"stock"
 	)
-	VALUES ($1, $2, $3)
+	VALUES ($1, $2, $3, $4, $5)
 		RETURNING "id";`
 
 	if err := b.tx.QueryRowxContext(
@@ -62,6 +64,8 @@ func (b *insertProductBuilder) insertProduct() error {
 		b.req.Title,
 		b.req.Description,
 		b.req.Price,
+		b.req.Weight,
+		b.req.Stock,
 	).Scan(&b.req.Id); err != nil {
 		b.tx.Rollback()
 		return fmt.Errorf("insert product failed: %v", err)
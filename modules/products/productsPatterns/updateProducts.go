@@ -17,6 +17,8 @@ type IUpdateProductBuilder interface {
 	updateTitleQuery()
 	updateDescriptionQuery()
 	updatePriceQuery()
+	updateWeightQuery()
+	updateStockQuery()
 	updateCategory() error
 	insertImages() error
 	getOldImages() []*entities.Image
@@ -95,6 +97,24 @@ func (b *updateProductBuilder) updatePriceQuery() {
 		"price" = $%d`, b.lastStackIndex))
 	}
 }
+func (b *updateProductBuilder) updateWeightQuery() {
+	if b.req.Weight != 0 {
+		b.values = append(b.values, b.req.Weight)
+		b.lastStackIndex = len(b.values)
+
+		b.queryFields = append(b.queryFields, fmt.Sprintf(`
+		"weight" = $%d`, b.lastStackIndex))
+	}
+}
+func (b *updateProductBuilder) updateStockQuery() {
+	if b.req.Stock != nil {
+		b.values = append(b.values, *b.req.Stock)
+		b.lastStackIndex = len(b.values)
+
+		b.queryFields = append(b.queryFields, fmt.Sprintf(`
+		"stock" = $%d`, b.lastStackIndex))
+	}
+}
 func (b *updateProductBuilder) updateCategory() error {
 	if b.req.Category == nil {
 		return nil
@@ -236,6 +256,8 @@ func (en *updateProductEngineer) sumQueryFields() {
 	en.builder.updateTitleQuery()
 	en.builder.updateDescriptionQuery()
 	en.builder.updatePriceQuery()
+	en.builder.updateWeightQuery()
+	en.builder.updateStockQuery()
 
 	fields := en.builder.getQueryFields()
 
@@ -45,6 +45,8 @@ func (r *productsRepository) FindOneProduct(productId string) (*products.Product
 			"p"."title",
 			"p"."description",
 			"p"."price",
+			"p"."weight",
+			"p"."stock",
 			(
 				SELECT
 					to_jsonb("ct")
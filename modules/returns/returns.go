@@ -0,0 +1,75 @@
+package returns
+
+import "github.com/LGROW101/lgrow-shop/modules/products"
+
+type ReturnFilter struct {
+	UserId  string `query:"user_id"`
+	OrderId string `query:"order_id"`
+	Status  string `query:"status"`
+}
+
+type Return struct {
+	Id        string        `db:"id" json:"id"`
+	OrderId   string        `db:"order_id" json:"order_id"`
+	UserId    string        `db:"user_id" json:"user_id"`
+	Reason    string        `db:"reason" json:"reason"`
+	Status    string        `db:"status" json:"status"`
+	Note      string        `db:"note" json:"note"`
+	Items     []*ReturnItem `json:"items"`
+	Refunds   []*Refund     `json:"refunds"`
+	CreatedAt string        `db:"created_at" json:"created_at"`
+	UpdatedAt string        `db:"updated_at" json:"updated_at"`
+}
+
+type ReturnItem struct {
+	Id              string            `db:"id" json:"id"`
+	ProductsOrderId string            `db:"products_order_id" json:"products_order_id"`
+	Qty             int               `db:"qty" json:"qty"`
+	Product         *products.Product `db:"product" json:"product"`
+}
+
+type Refund struct {
+	Id        string  `db:"id" json:"id"`
+	OrderId   string  `db:"order_id" json:"order_id"`
+	ReturnId  string  `db:"return_id" json:"return_id"`
+	Amount    float64 `db:"amount" json:"amount"`
+	Method    string  `db:"method" json:"method"` // bank_transfer | promptpay | cash | other
+	Reference string  `db:"reference" json:"reference"`
+	CreatedBy string  `db:"created_by" json:"created_by"`
+	CreatedAt string  `db:"created_at" json:"created_at"`
+}
+
+type UpdateReturnReq struct {
+	Id     string `json:"id"`
+	Status string `json:"status"`
+	Note   string `json:"note"`
+}
+
+// IsTransitionValid reports whether an admin may move a return from the current status to next
+//
+// requested -> approved | rejected
+// approved  -> received
+// received  -> refunded (by recording a refund)
+func IsTransitionValid(current, next string) bool {
+	transitionMap := map[string][]string{
+		"requested": {"approved", "rejected"},
+		"approved":  {"received"},
+		"received":  {"refunded"},
+	}
+	for _, s := range transitionMap[current] {
+		if s == next {
+			return true
+		}
+	}
+	return false
+}
+
+func (obj *Refund) IsMethodValid() bool {
+	methodMap := map[string]string{
+		"bank_transfer": "bank_transfer",
+		"promptpay":     "promptpay",
+		"cash":          "cash",
+		"other":         "other",
+	}
+	return methodMap[obj.Method] != ""
+}
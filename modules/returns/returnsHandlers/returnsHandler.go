@@ -0,0 +1,208 @@
+package returnsHandlers
+
+import (
+	"strings"
+
+	"github.com/LGROW101/lgrow-shop/config"
+	"github.com/LGROW101/lgrow-shop/modules/entities"
+	"github.com/LGROW101/lgrow-shop/modules/returns"
+	"github.com/LGROW101/lgrow-shop/modules/returns/returnsUsecases"
+	"github.com/gofiber/fiber/v2"
+)
+
+type returnsHandlersErrCode string
+
+const (
+	findOneReturnErr returnsHandlersErrCode = "returns-001"
+	findReturnErr    returnsHandlersErrCode = "returns-002"
+	insertReturnErr  returnsHandlersErrCode = "returns-003"
+	updateReturnErr  returnsHandlersErrCode = "returns-004"
+	insertRefundErr  returnsHandlersErrCode = "returns-005"
+)
+
+type IReturnsHandler interface {
+	FindOneReturn(c *fiber.Ctx) error
+	FindReturn(c *fiber.Ctx) error
+	FindUserReturn(c *fiber.Ctx) error
+	InsertReturn(c *fiber.Ctx) error
+	UpdateReturn(c *fiber.Ctx) error
+	InsertRefund(c *fiber.Ctx) error
+}
+
+type returnsHandler struct {
+	cfg            config.IConfig
+	returnsUsecase returnsUsecases.IReturnsUsecase
+}
+
+func ReturnsHandler(cfg config.IConfig, returnsUsecase returnsUsecases.IReturnsUsecase) IReturnsHandler {
+	return &returnsHandler{
+		cfg:            cfg,
+		returnsUsecase: returnsUsecase,
+	}
+}
+
+func (h *returnsHandler) FindOneReturn(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+	returnId := strings.Trim(c.Params("return_id"), " ")
+
+	returnData, err := h.returnsUsecase.FindOneReturn(returnId)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findOneReturnErr),
+			err.Error(),
+		).Res()
+	}
+	if c.Locals("userRoleId").(int) != 2 && returnData.UserId != userId {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(findOneReturnErr),
+			"return not found",
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, returnData).Res()
+}
+
+func (h *returnsHandler) FindReturn(c *fiber.Ctx) error {
+	req := new(returns.ReturnFilter)
+	if err := c.QueryParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(findReturnErr),
+			err.Error(),
+		).Res()
+	}
+
+	returnsData, err := h.returnsUsecase.FindReturn(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findReturnErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, returnsData).Res()
+}
+
+func (h *returnsHandler) FindUserReturn(c *fiber.Ctx) error {
+	req := &returns.ReturnFilter{
+		UserId: strings.Trim(c.Params("user_id"), " "),
+	}
+
+	returnsData, err := h.returnsUsecase.FindReturn(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findReturnErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, returnsData).Res()
+}
+
+func (h *returnsHandler) InsertReturn(c *fiber.Ctx) error {
+	req := &returns.Return{
+		Items: make([]*returns.ReturnItem, 0),
+	}
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertReturnErr),
+			err.Error(),
+		).Res()
+	}
+	req.UserId = strings.Trim(c.Params("user_id"), " ")
+
+	if strings.TrimSpace(req.OrderId) == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertReturnErr),
+			"order id is required",
+		).Res()
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertReturnErr),
+			"reason is required",
+		).Res()
+	}
+	if len(req.Items) == 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertReturnErr),
+			"items are empty",
+		).Res()
+	}
+
+	returnData, err := h.returnsUsecase.InsertReturn(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertReturnErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, returnData).Res()
+}
+
+func (h *returnsHandler) UpdateReturn(c *fiber.Ctx) error {
+	req := new(returns.UpdateReturnReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateReturnErr),
+			err.Error(),
+		).Res()
+	}
+	req.Id = strings.Trim(c.Params("return_id"), " ")
+	req.Status = strings.ToLower(req.Status)
+
+	returnData, err := h.returnsUsecase.UpdateReturn(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateReturnErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, returnData).Res()
+}
+
+func (h *returnsHandler) InsertRefund(c *fiber.Ctx) error {
+	req := new(returns.Refund)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertRefundErr),
+			err.Error(),
+		).Res()
+	}
+	req.ReturnId = strings.Trim(c.Params("return_id"), " ")
+	req.CreatedBy = c.Locals("userId").(string)
+
+	if req.Amount <= 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertRefundErr),
+			"amount must more than 0",
+		).Res()
+	}
+	if !req.IsMethodValid() {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertRefundErr),
+			"method is invalid",
+		).Res()
+	}
+
+	returnData, err := h.returnsUsecase.InsertRefund(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertRefundErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, returnData).Res()
+}
@@ -0,0 +1,240 @@
+package returnsPatterns
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/LGROW101/lgrow-shop/modules/orders/ordersPatterns"
+	"github.com/LGROW101/lgrow-shop/modules/returns"
+	"github.com/jmoiron/sqlx"
+)
+
+type IInsertReturnBuilder interface {
+	initTransaction() error
+	checkOrder() error
+	checkItems() error
+	insertReturn() error
+	insertReturnItems() error
+	insertHistory() error
+	getReturnId() string
+	commit() error
+}
+
+type insertReturnBuilder struct {
+	db     *sqlx.DB
+	req    *returns.Return
+	window time.Duration
+	tx     *sqlx.Tx
+}
+
+type insertReturnEngineer struct {
+	builder IInsertReturnBuilder
+}
+
+func InsertReturnBuilder(db *sqlx.DB, req *returns.Return, window time.Duration) IInsertReturnBuilder {
+	return &insertReturnBuilder{
+		db:     db,
+		req:    req,
+		window: window,
+	}
+}
+
+func InsertReturnEngineer(b IInsertReturnBuilder) *insertReturnEngineer {
+	return &insertReturnEngineer{builder: b}
+}
+
+func (b *insertReturnBuilder) getReturnId() string {
+	return b.req.Id
+}
+func (b *insertReturnBuilder) initTransaction() error {
+	tx, err := b.db.BeginTxx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	b.tx = tx
+	return nil
+}
+func (b *insertReturnBuilder) checkOrder() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	// The window starts when the order was completed
+	query := `
+	SELECT
+		"o"."user_id",
+		"o"."status",
+		COALESCE((
+			SELECT
+				MAX("h"."created_at")
+			FROM "orders_histories" "h"
+			WHERE "h"."order_id" = "o"."id"
+			AND "h"."status" = 'completed'
+		), "o"."updated_at") >= now() - ($2 * INTERVAL '1 second') AS "in_window"
+	FROM "orders" "o"
+	WHERE "o"."id" = $1
+	FOR UPDATE;`
+
+	var userId, status string
+	var inWindow bool
+	if err := b.tx.QueryRowxContext(ctx, query, b.req.OrderId, int64(b.window.Seconds())).Scan(&userId, &status, &inWindow); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("order not found")
+	}
+	if userId != b.req.UserId {
+		b.tx.Rollback()
+		return fmt.Errorf("order not found")
+	}
+	if status != "completed" {
+		b.tx.Rollback()
+		return fmt.Errorf("order is not completed")
+	}
+	if !inWindow {
+		b.tx.Rollback()
+		return fmt.Errorf("return window has expired")
+	}
+	return nil
+}
+func (b *insertReturnBuilder) checkItems() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	// Returnable qty = ordered qty - qty in every return that was not rejected
+	query := `
+	SELECT
+		"po"."qty" - COALESCE((
+			SELECT
+				SUM("ri"."qty")
+			FROM "returns_items" "ri"
+				LEFT JOIN "returns" "r" ON "r"."id" = "ri"."return_id"
+			WHERE "ri"."products_order_id" = "po"."id"
+			AND "r"."status" <> 'rejected'
+		), 0)
+	FROM "products_orders" "po"
+	WHERE "po"."id" = $1
+	AND "po"."order_id" = $2;`
+
+	for _, item := range b.req.Items {
+		if item.Qty < 1 {
+			b.tx.Rollback()
+			return fmt.Errorf("qty must more than 0")
+		}
+
+		var remaining int
+		if err := b.tx.QueryRowxContext(ctx, query, item.ProductsOrderId, b.req.OrderId).Scan(&remaining); err != nil {
+			b.tx.Rollback()
+			return fmt.Errorf("item %s not found in order", item.ProductsOrderId)
+		}
+		if item.Qty > remaining {
+			b.tx.Rollback()
+			return fmt.Errorf("item %s has only %d left to return", item.ProductsOrderId, remaining)
+		}
+	}
+	return nil
+}
+func (b *insertReturnBuilder) insertReturn() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	query := `
+	INSERT INTO "returns" (
+		"order_id",
+		"user_id",
+		"reason"
+	)
+	VALUES
+	($1, $2, $3)
+		RETURNING "id";`
+
+	if err := b.tx.QueryRowxContext(
+		ctx,
+		query,
+		b.req.OrderId,
+		b.req.UserId,
+		b.req.Reason,
+	).Scan(&b.req.Id); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("insert return failed: %v", err)
+	}
+	return nil
+}
+func (b *insertReturnBuilder) insertReturnItems() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	query := `
+	INSERT INTO "returns_items" (
+		"return_id",
+		"products_order_id",
+		"qty"
+	)
+	VALUES`
+
+	values := make([]any, 0)
+	lastIndex := 0
+	for i := range b.req.Items {
+		values = append(
+			values,
+			b.req.Id,
+			b.req.Items[i].ProductsOrderId,
+			b.req.Items[i].Qty,
+		)
+
+		if i != len(b.req.Items)-1 {
+			query += fmt.Sprintf(`
+			($%d, $%d, $%d),`, lastIndex+1, lastIndex+2, lastIndex+3)
+		} else {
+			query += fmt.Sprintf(`
+			($%d, $%d, $%d);`, lastIndex+1, lastIndex+2, lastIndex+3)
+		}
+
+		lastIndex += 3
+	}
+
+	if _, err := b.tx.ExecContext(ctx, query, values...); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("insert returns_items failed: %v", err)
+	}
+	return nil
+}
+func (b *insertReturnBuilder) insertHistory() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	if err := ordersPatterns.InsertOrderHistory(ctx, b.tx, b.req.OrderId, fmt.Sprintf("return %s requested", b.req.Id)); err != nil {
+		b.tx.Rollback()
+		return err
+	}
+	return nil
+}
+func (b *insertReturnBuilder) commit() error {
+	if err := b.tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (en *insertReturnEngineer) InsertReturn() (string, error) {
+	if err := en.builder.initTransaction(); err != nil {
+		return "", err
+	}
+	if err := en.builder.checkOrder(); err != nil {
+		return "", err
+	}
+	if err := en.builder.checkItems(); err != nil {
+		return "", err
+	}
+	if err := en.builder.insertReturn(); err != nil {
+		return "", err
+	}
+	if err := en.builder.insertReturnItems(); err != nil {
+		return "", err
+	}
+	if err := en.builder.insertHistory(); err != nil {
+		return "", err
+	}
+	if err := en.builder.commit(); err != nil {
+		return "", err
+	}
+	return en.builder.getReturnId(), nil
+}
@@ -0,0 +1,286 @@
+package returnsRepositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/LGROW101/lgrow-shop/modules/orders/ordersPatterns"
+	"github.com/LGROW101/lgrow-shop/modules/returns"
+	"github.com/LGROW101/lgrow-shop/modules/returns/returnsPatterns"
+	"github.com/jmoiron/sqlx"
+)
+
+type IReturnsRepository interface {
+	FindOneReturn(returnId string) (*returns.Return, error)
+	FindReturn(req *returns.ReturnFilter) ([]*returns.Return, error)
+	InsertReturn(req *returns.Return, window time.Duration) (string, error)
+	UpdateReturn(req *returns.UpdateReturnReq) error
+	InsertRefund(req *returns.Refund) error
+}
+
+type returnsRepository struct {
+	db *sqlx.DB
+}
+
+func ReturnsRepository(db *sqlx.DB) IReturnsRepository {
+	return &returnsRepository{db: db}
+}
+
+const selectReturnQuery = `
+		SELECT
+			"r"."id",
+			"r"."order_id",
+			"r"."user_id",
+			"r"."reason",
+			"r"."status",
+			"r"."note",
+			(
+				SELECT
+					COALESCE(array_to_json(array_agg("it")), '[]'::json)
+				FROM (
+					SELECT
+						"ri"."id",
+						"ri"."products_order_id",
+						"ri"."qty",
+						"po"."product"
+					FROM "returns_items" "ri"
+						LEFT JOIN "products_orders" "po" ON "po"."id" = "ri"."products_order_id"
+					WHERE "ri"."return_id" = "r"."id"
+				) AS "it"
+			) AS "items",
+			(
+				SELECT
+					COALESCE(array_to_json(array_agg("rt")), '[]'::json)
+				FROM (
+					SELECT
+						"rf"."id",
+						"rf"."order_id",
+						"rf"."return_id",
+						"rf"."amount",
+						"rf"."method",
+						"rf"."reference",
+						"rf"."created_by",
+						"rf"."created_at"
+					FROM "refunds" "rf"
+					WHERE "rf"."return_id" = "r"."id"
+				) AS "rt"
+			) AS "refunds",
+			"r"."created_at",
+			"r"."updated_at"
+		FROM "returns" "r"`
+
+func (r *returnsRepository) FindOneReturn(returnId string) (*returns.Return, error) {
+	query := `
+	SELECT
+		to_jsonb("t")
+	FROM (` + selectReturnQuery + `
+		WHERE "r"."id" = $1
+	) AS "t";`
+
+	raw := make([]byte, 0)
+	if err := r.db.Get(&raw, query, returnId); err != nil {
+		return nil, fmt.Errorf("get return failed: %v", err)
+	}
+
+	returnData := new(returns.Return)
+	if err := json.Unmarshal(raw, &returnData); err != nil {
+		return nil, fmt.Errorf("unmarshal return failed: %v", err)
+	}
+	return returnData, nil
+}
+
+func (r *returnsRepository) FindReturn(req *returns.ReturnFilter) ([]*returns.Return, error) {
+	query := `
+	SELECT
+		COALESCE(array_to_json(array_agg("t")), '[]'::json)
+	FROM (` + selectReturnQuery + `
+		WHERE 1 = 1`
+
+	values := make([]any, 0)
+	if req.UserId != "" {
+		values = append(values, req.UserId)
+		query += fmt.Sprintf(`
+		AND "r"."user_id" = $%d`, len(values))
+	}
+	if req.OrderId != "" {
+		values = append(values, req.OrderId)
+		query += fmt.Sprintf(`
+		AND "r"."order_id" = $%d`, len(values))
+	}
+	if req.Status != "" {
+		values = append(values, strings.ToLower(req.Status))
+		query += fmt.Sprintf(`
+		AND "r"."status" = $%d`, len(values))
+	}
+	query += `
+		ORDER BY "r"."created_at" DESC
+	) AS "t";`
+
+	raw := make([]byte, 0)
+	if err := r.db.Get(&raw, query, values...); err != nil {
+		return nil, fmt.Errorf("get returns failed: %v", err)
+	}
+
+	returnsData := make([]*returns.Return, 0)
+	if err := json.Unmarshal(raw, &returnsData); err != nil {
+		return nil, fmt.Errorf("unmarshal returns failed: %v", err)
+	}
+	return returnsData, nil
+}
+
+func (r *returnsRepository) InsertReturn(req *returns.Return, window time.Duration) (string, error) {
+	builder := returnsPatterns.InsertReturnBuilder(r.db, req, window)
+	returnId, err := returnsPatterns.InsertReturnEngineer(builder).InsertReturn()
+	if err != nil {
+		return "", err
+	}
+	return returnId, nil
+}
+
+func (r *returnsRepository) UpdateReturn(req *returns.UpdateReturnReq) error {
+	ctx := context.Background()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	var orderId, status string
+	if err := tx.QueryRowxContext(ctx, `SELECT "order_id", "status" FROM "returns" WHERE "id" = $1 FOR UPDATE;`, req.Id).Scan(&orderId, &status); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("return not found")
+	}
+	if !returns.IsTransitionValid(status, req.Status) || req.Status == "refunded" {
+		tx.Rollback()
+		return fmt.Errorf("cannot change return status from %s to %s", status, req.Status)
+	}
+
+	query := `
+	UPDATE "returns" SET
+		"status" = $1,
+		"note" = $2
+	WHERE "id" = $3;`
+
+	if _, err := tx.ExecContext(ctx, query, req.Status, req.Note, req.Id); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("update return failed: %v", err)
+	}
+
+	// Received items go back to stock
+	if req.Status == "received" {
+		queryRestock := `
+		UPDATE "products" "p" SET
+			"stock" = "p"."stock" + "r"."qty"
+		FROM (
+			SELECT
+				"po"."product"->>'id' AS "product_id",
+				SUM("ri"."qty") AS "qty"
+			FROM "returns_items" "ri"
+				LEFT JOIN "products_orders" "po" ON "po"."id" = "ri"."products_order_id"
+			WHERE "ri"."return_id" = $1
+			GROUP BY "po"."product"->>'id'
+		) AS "r"
+		WHERE "p"."id" = "r"."product_id"
+		AND "p"."stock" IS NOT NULL;`
+
+		if _, err := tx.ExecContext(ctx, queryRestock, req.Id); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("restock failed: %v", err)
+		}
+	}
+
+	note := fmt.Sprintf("return %s %s", req.Id, req.Status)
+	if req.Note != "" {
+		note += ": " + req.Note
+	}
+	if err := ordersPatterns.InsertOrderHistory(ctx, tx, orderId, note); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *returnsRepository) InsertRefund(req *returns.Refund) error {
+	ctx := context.Background()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	var status string
+	if err := tx.QueryRowxContext(ctx, `SELECT "order_id", "status" FROM "returns" WHERE "id" = $1 FOR UPDATE;`, req.ReturnId).Scan(&req.OrderId, &status); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("return not found")
+	}
+	if !returns.IsTransitionValid(status, "refunded") {
+		tx.Rollback()
+		return fmt.Errorf("return has not been received")
+	}
+
+	// Refundable = value of returned items from the order snapshot
+	queryRefundable := `
+	SELECT
+		COALESCE(SUM(("po"."product"->>'price')::FLOAT * "ri"."qty"), 0)
+	FROM "returns_items" "ri"
+		LEFT JOIN "products_orders" "po" ON "po"."id" = "ri"."products_order_id"
+	WHERE "ri"."return_id" = $1;`
+
+	var refundable float64
+	if err := tx.GetContext(ctx, &refundable, queryRefundable, req.ReturnId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("get refundable amount failed: %v", err)
+	}
+	if req.Amount > refundable {
+		tx.Rollback()
+		return fmt.Errorf("amount must not exceed %.2f", refundable)
+	}
+
+	queryInsert := `
+	INSERT INTO "refunds" (
+		"order_id",
+		"return_id",
+		"amount",
+		"method",
+		"reference",
+		"created_by"
+	)
+	VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING "id";`
+
+	if err := tx.QueryRowxContext(
+		ctx,
+		queryInsert,
+		req.OrderId,
+		req.ReturnId,
+		req.Amount,
+		req.Method,
+		req.Reference,
+		req.CreatedBy,
+	).Scan(&req.Id); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("insert refund failed: %v", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE "returns" SET "status" = 'refunded' WHERE "id" = $1;`, req.ReturnId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("update return failed: %v", err)
+	}
+
+	if err := ordersPatterns.InsertOrderHistory(ctx, tx, req.OrderId, fmt.Sprintf("refunded %.2f by %s (%s)", req.Amount, req.Method, req.Reference)); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
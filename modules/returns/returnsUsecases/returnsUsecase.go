@@ -0,0 +1,93 @@
+package returnsUsecases
+
+import (
+	"github.com/LGROW101/lgrow-shop/config"
+	"github.com/LGROW101/lgrow-shop/modules/returns"
+	"github.com/LGROW101/lgrow-shop/modules/returns/returnsRepositories"
+)
+
+type IReturnsUsecase interface {
+	FindOneReturn(returnId string) (*returns.Return, error)
+	FindReturn(req *returns.ReturnFilter) ([]*returns.Return, error)
+	InsertReturn(req *returns.Return) (*returns.Return, error)
+	UpdateReturn(req *returns.UpdateReturnReq) (*returns.Return, error)
+	InsertRefund(req *returns.Refund) (*returns.Return, error)
+}
+
+type returnsUsecase struct {
+	cfg               config.IConfig
+	returnsRepository returnsRepositories.IReturnsRepository
+}
+
+func ReturnsUsecase(cfg config.IConfig, returnsRepository returnsRepositories.IReturnsRepository) IReturnsUsecase {
+	return &returnsUsecase{
+		cfg:               cfg,
+		returnsRepository: returnsRepository,
+	}
+}
+
+func (u *returnsUsecase) FindOneReturn(returnId string) (*returns.Return, error) {
+	returnData, err := u.returnsRepository.FindOneReturn(returnId)
+	if err != nil {
+		return nil, err
+	}
+	return returnData, nil
+}
+
+func (u *returnsUsecase) FindReturn(req *returns.ReturnFilter) ([]*returns.Return, error) {
+	returnsData, err := u.returnsRepository.FindReturn(req)
+	if err != nil {
+		return nil, err
+	}
+	return returnsData, nil
+}
+
+func (u *returnsUsecase) InsertReturn(req *returns.Return) (*returns.Return, error) {
+	// Merge duplicate items so the returnable qty is checked once per item
+	items := make([]*returns.ReturnItem, 0)
+	itemsMap := make(map[string]*returns.ReturnItem)
+	for _, item := range req.Items {
+		if itemsMap[item.ProductsOrderId] != nil {
+			itemsMap[item.ProductsOrderId].Qty += item.Qty
+			continue
+		}
+		itemsMap[item.ProductsOrderId] = item
+		items = append(items, item)
+	}
+	req.Items = items
+
+	returnId, err := u.returnsRepository.InsertReturn(req, u.cfg.Order().ReturnWindow())
+	if err != nil {
+		return nil, err
+	}
+
+	returnData, err := u.returnsRepository.FindOneReturn(returnId)
+	if err != nil {
+		return nil, err
+	}
+	return returnData, nil
+}
+
+func (u *returnsUsecase) UpdateReturn(req *returns.UpdateReturnReq) (*returns.Return, error) {
+	if err := u.returnsRepository.UpdateReturn(req); err != nil {
+		return nil, err
+	}
+
+	returnData, err := u.returnsRepository.FindOneReturn(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return returnData, nil
+}
+
+func (u *returnsUsecase) InsertRefund(req *returns.Refund) (*returns.Return, error) {
+	if err := u.returnsRepository.InsertRefund(req); err != nil {
+		return nil, err
+	}
+
+	returnData, err := u.returnsRepository.FindOneReturn(req.ReturnId)
+	if err != nil {
+		return nil, err
+	}
+	return returnData, nil
+}
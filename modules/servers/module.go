@@ -1,23 +1,46 @@
 package servers
 
 import (
+	"context"
+	"log"
+
 	"github.com/LGROW101/lgrow-shop/modules/appinfo/appinfoHandlers"
 	"github.com/LGROW101/lgrow-shop/modules/appinfo/appinfoRepositories"
 	"github.com/LGROW101/lgrow-shop/modules/appinfo/appinfoUsecases"
+	"github.com/LGROW101/lgrow-shop/modules/credits/creditsHandlers"
+	"github.com/LGROW101/lgrow-shop/modules/credits/creditsRepositories"
+	"github.com/LGROW101/lgrow-shop/modules/credits/creditsUsecases"
 	"github.com/LGROW101/lgrow-shop/modules/orders/ordersHandlers"
 	"github.com/LGROW101/lgrow-shop/modules/orders/ordersRepositories"
 	"github.com/LGROW101/lgrow-shop/modules/orders/ordersUsecases"
 
 	"github.com/LGROW101/lgrow-shop/modules/files/filesUsecases"
 
+	"github.com/LGROW101/lgrow-shop/modules/jwks/jwksHandlers"
+	"github.com/LGROW101/lgrow-shop/modules/jwks/jwksRepositories"
+	"github.com/LGROW101/lgrow-shop/modules/jwks/jwksUsecases"
+
 	"github.com/LGROW101/lgrow-shop/modules/middlewares/middlewaresHandlers"
 	"github.com/LGROW101/lgrow-shop/modules/middlewares/middlewaresRepositories"
 	"github.com/LGROW101/lgrow-shop/modules/middlewares/middlewaresUsecases"
 
 	"github.com/LGROW101/lgrow-shop/modules/monitor/monitorHandlers"
 
+	"github.com/LGROW101/lgrow-shop/modules/payments/paymentsHandlers"
+	"github.com/LGROW101/lgrow-shop/modules/payments/paymentsProviders"
+	"github.com/LGROW101/lgrow-shop/modules/payments/paymentsRepositories"
+	"github.com/LGROW101/lgrow-shop/modules/payments/paymentsUsecases"
+
 	"github.com/LGROW101/lgrow-shop/modules/products/productsRepositories"
 
+	"github.com/LGROW101/lgrow-shop/modules/returns/returnsHandlers"
+	"github.com/LGROW101/lgrow-shop/modules/returns/returnsRepositories"
+	"github.com/LGROW101/lgrow-shop/modules/returns/returnsUsecases"
+
+	"github.com/LGROW101/lgrow-shop/modules/shipping/shippingHandlers"
+	"github.com/LGROW101/lgrow-shop/modules/shipping/shippingRepositories"
+	"github.com/LGROW101/lgrow-shop/modules/shipping/shippingUsecases"
+
 	"github.com/LGROW101/lgrow-shop/modules/users/usersHandlers"
 	"github.com/LGROW101/lgrow-shop/modules/users/usersRepositories"
 	"github.com/LGROW101/lgrow-shop/modules/users/usersUsecases"
@@ -31,6 +54,11 @@ type IModuleFactory interface {
 	FilesModule() IFilesModule
 	ProductsModule() IProductsModule
 	OrdersModule()
+	ShippingModule()
+	ReturnsModule()
+	PaymentsModule()
+	CreditsModule()
+	JwksModule()
 }
 
 type moduleFactory struct {
@@ -49,18 +77,18 @@ func InitModule(r fiber.Router, s *server, mid middlewaresHandlers.IMiddlewaresH
 func InitMiddlewares(s *server) middlewaresHandlers.IMiddlewaresHandler {
 	repository := middlewaresRepositories.MiddlewaresRepository(s.db)
 	usecase := middlewaresUsecases.IMiddlewaresUsecase(repository)
-	return middlewaresHandlers.MiddlewaresHandler(s.cfg, usecase)
+	return middlewaresHandlers.MiddlewaresHandler(s.cfg, s.keys, usecase)
 }
 
 func (m *moduleFactory) MonitorModule() {
-	handler := monitorHandlers.MonitorHandler(m.s.cfg)
+	handler := monitorHandlers.MonitorHandler(m.s.cfg, m.s.scheduler)
 
 	m.r.Get("/", handler.HealthCheck)
 }
 
 func (m *moduleFactory) UsersModule() {
 	repository := usersRepositories.UsersRepository(m.s.db)
-	usecase := usersUsecases.UsersUsecase(m.s.cfg, repository)
+	usecase := usersUsecases.UsersUsecase(m.s.cfg, repository, m.s.mailer, m.s.keys)
 	handler := usersHandlers.UsersHandler(m.s.cfg, usecase)
 
 	router := m.r.Group("/users")
@@ -69,11 +97,36 @@ func (m *moduleFactory) UsersModule() {
 	router.Post("/signin", m.mid.ApiKeyAuth(), handler.SignIn)
 	router.Post("/refresh", m.mid.ApiKeyAuth(), handler.RefreshPassport)
 	router.Post("/signout", m.mid.ApiKeyAuth(), handler.SignOut)
-	router.Post("/signup/admin", m.mid.JwtAuth(), m.mid.Authorize(2), handler.SignOut)
+	router.Post("/verify-email", m.mid.ApiKeyAuth(), handler.VerifyEmail)
+	router.Post("/password/forgot", m.mid.ApiKeyAuth(), handler.ForgotPassword)
+	router.Post("/password/reset", m.mid.ApiKeyAuth(), handler.ResetPassword)
+	router.Post("/mfa/verify", m.mid.ApiKeyAuth(), handler.VerifyMfa)
+	router.Post("/mfa/enroll", m.mid.ApiKeyAuth(), handler.EnrollMfa)
+	router.Post("/mfa/enroll/confirm", m.mid.ApiKeyAuth(), handler.ConfirmEnrollMfa)
+	router.Post("/signup/admin", m.mid.JwtAuth(), m.mid.Authorize(2), handler.SignUpAdmin)
 
-	router.Get("/:user_id", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.GetUserProfile)
+	// Before /:user_id, otherwise "admin" is taken as a user id
 	router.Get("/admin/secret", m.mid.JwtAuth(), m.mid.Authorize(2), handler.GenerateAdminToken)
 
+	router.Get("/:user_id", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.GetUserProfile)
+	router.Patch("/:user_id", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.UpdateUser)
+	router.Post("/:user_id/password", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.ChangePassword)
+
+	router.Get("/:user_id/sessions", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.FindSession)
+	router.Delete("/:user_id/sessions", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.RemoveOtherSessions)
+	router.Delete("/:user_id/sessions/:session_id", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.RemoveSession)
+
+	router.Post("/:user_id/mfa", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.SetupMfa)
+	router.Post("/:user_id/mfa/confirm", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.ConfirmMfa)
+	router.Delete("/:user_id/mfa", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.DisableMfa)
+	router.Post("/:user_id/unlock", m.mid.JwtAuth(), m.mid.Authorize(2), handler.UnlockUser)
+
+	router.Post("/:user_id/addresses", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.AddAddress)
+	router.Get("/:user_id/addresses", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.FindAddress)
+	router.Get("/:user_id/addresses/:address_id", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.FindOneAddress)
+	router.Put("/:user_id/addresses/:address_id", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.UpdateAddress)
+	router.Delete("/:user_id/addresses/:address_id", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.RemoveAddress)
+
 	// Insotail admin ขึ้นมา 1 คน ใน Db (Insert ใน SQL)
 	// Generate Admin key
 	// ทุกครั้งที่ทำการสมัคร admin เพิ่ม ให้สิทธิ admin token มาด้วยทุกครั้ง ผ่าน Middleware
@@ -97,15 +150,154 @@ func (m *moduleFactory) OrdersModule() {
 	filesUsecase := filesUsecases.FilesUsecase(m.s.cfg)
 	productsRepository := productsRepositories.ProductsRepository(m.s.db, m.s.cfg, filesUsecase)
 
+	shippingRepository := shippingRepositories.ShippingRepository(m.s.db)
+
+	usersRepository := usersRepositories.UsersRepository(m.s.db)
+
 	ordersRepository := ordersRepositories.OrdersRepository(m.s.db)
-	ordersUsecase := ordersUsecases.OrdersUsecase(ordersRepository, productsRepository)
+	ordersUsecase := ordersUsecases.OrdersUsecase(m.s.cfg, ordersRepository, productsRepository, shippingRepository, usersRepository, filesUsecase)
 	ordersHandler := ordersHandlers.OrdersHandler(m.s.cfg, ordersUsecase)
 
+	// Unpaid orders release their stock after the configured timeout
+	if m.s.cfg.Order().UnpaidTimeout() > 0 {
+		m.s.scheduler.Add("cancel-unpaid-orders", m.s.cfg.Order().UnpaidCheckInterval(), ordersUsecase.CancelUnpaidOrders)
+	}
+
 	router := m.r.Group("/orders")
+
+	// Before the /:user_id/:order_id routes
+	router.Get("/transfer-slips", m.mid.JwtAuth(), m.mid.Authorize(2), ordersHandler.FindTransferSlip)
+	router.Get("/transfer-slips/:slip_id/file", m.mid.JwtAuth(), m.mid.Authorize(2), ordersHandler.TransferSlipFile)
+	router.Patch("/transfer-slips/:slip_id", m.mid.JwtAuth(), m.mid.Authorize(2), ordersHandler.ReviewTransferSlip)
+
+	router.Post("/guest", m.mid.ApiKeyAuth(), ordersHandler.InsertGuestOrder)
+	router.Get("/guest/:order_id", m.mid.ApiKeyAuth(), ordersHandler.FindGuestOrder)
+	router.Get("/guest/:order_id/payment-qr", m.mid.ApiKeyAuth(), ordersHandler.GuestPaymentQR)
+	router.Post("/guest/:order_id/transfer-slips", m.mid.ApiKeyAuth(), ordersHandler.GuestUploadTransferSlip)
+
 	router.Post("/", m.mid.JwtAuth(), ordersHandler.InsertOrder)
+	router.Post("/shipping-quote", m.mid.ApiKeyAuth(), ordersHandler.ShippingQuote)
 
 	router.Get("/", m.mid.JwtAuth(), m.mid.Authorize(2), ordersHandler.FindOrder)
 	router.Get("/:user_id/:order_id", m.mid.JwtAuth(), m.mid.ParamsCheck(), ordersHandler.FindOneOrder)
+	router.Get("/:user_id/:order_id/payment-qr", m.mid.JwtAuth(), m.mid.ParamsCheck(), ordersHandler.PaymentQR)
+	router.Get("/:user_id/:order_id/invoice.pdf", m.mid.JwtAuth(), m.mid.ParamsCheck(), ordersHandler.Invoice)
 	router.Patch("/:user_id/:order_id", m.mid.JwtAuth(), m.mid.ParamsCheck(), ordersHandler.UpdateOrder)
 
+	router.Post("/:user_id/:order_id/reorder", m.mid.JwtAuth(), m.mid.ParamsCheck(), ordersHandler.Reorder)
+	router.Post("/:user_id/:order_id/notes", m.mid.JwtAuth(), m.mid.ParamsCheck(), ordersHandler.InsertNote)
+	router.Post("/:user_id/:order_id/transfer-slips", m.mid.JwtAuth(), m.mid.ParamsCheck(), ordersHandler.UploadTransferSlip)
+	router.Post("/:user_id/:order_id/shipments", m.mid.JwtAuth(), m.mid.Authorize(2), ordersHandler.InsertShipment)
+	router.Patch("/:user_id/:order_id/shipments/:shipment_id/delivered", m.mid.JwtAuth(), m.mid.Authorize(2), ordersHandler.DeliverShipment)
+
+}
+
+func (m *moduleFactory) ShippingModule() {
+	repository := shippingRepositories.ShippingRepository(m.s.db)
+	usecase := shippingUsecases.ShippingUsecase(repository)
+	handler := shippingHandlers.ShippingHandler(m.s.cfg, usecase)
+
+	router := m.r.Group("/shipping")
+
+	router.Post("/zones", m.mid.JwtAuth(), m.mid.Authorize(2), handler.AddZone)
+	router.Post("/methods", m.mid.JwtAuth(), m.mid.Authorize(2), handler.AddMethod)
+
+	router.Get("/zones", m.mid.ApiKeyAuth(), handler.FindZone)
+	router.Get("/methods", m.mid.ApiKeyAuth(), handler.FindMethod)
+
+	router.Patch("/methods/:method_id", m.mid.JwtAuth(), m.mid.Authorize(2), handler.UpdateMethod)
+
+	router.Delete("/zones/:zone_id", m.mid.JwtAuth(), m.mid.Authorize(2), handler.RemoveZone)
+	router.Delete("/methods/:method_id", m.mid.JwtAuth(), m.mid.Authorize(2), handler.RemoveMethod)
+}
+
+func (m *moduleFactory) ReturnsModule() {
+	repository := returnsRepositories.ReturnsRepository(m.s.db)
+	usecase := returnsUsecases.ReturnsUsecase(m.s.cfg, repository)
+	handler := returnsHandlers.ReturnsHandler(m.s.cfg, usecase)
+
+	router := m.r.Group("/returns")
+
+	router.Post("/:user_id", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.InsertReturn)
+	router.Post("/:return_id/refunds", m.mid.JwtAuth(), m.mid.Authorize(2), handler.InsertRefund)
+
+	router.Get("/", m.mid.JwtAuth(), m.mid.Authorize(2), handler.FindReturn)
+	router.Get("/:user_id", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.FindUserReturn)
+	router.Get("/:user_id/:return_id", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.FindOneReturn)
+
+	router.Patch("/:return_id", m.mid.JwtAuth(), m.mid.Authorize(2), handler.UpdateReturn)
+}
+
+func (m *moduleFactory) PaymentsModule() {
+	// The mock provider marks any payment paid, it is only for development and tests
+	registered := make([]paymentsProviders.IPaymentProvider, 0)
+	if m.s.cfg.Payment().MockEnabled() {
+		if m.s.cfg.App().IsProduction() {
+			log.Fatalf("payment mock provider cannot be enabled in production")
+		}
+		registered = append(registered, paymentsProviders.MockProvider(m.s.cfg.Payment().MockSecret()))
+	}
+	providers := paymentsProviders.PaymentProviders(registered...)
+
+	ordersRepository := ordersRepositories.OrdersRepository(m.s.db)
+
+	repository := paymentsRepositories.PaymentsRepository(m.s.db)
+	usecase := paymentsUsecases.PaymentsUsecase(providers, repository, ordersRepository)
+	handler := paymentsHandlers.PaymentsHandler(m.s.cfg, usecase)
+
+	router := m.r.Group("/payments")
+
+	router.Post("/webhooks/:provider", handler.Webhook)
+	router.Post("/admin/:payment_id/refund", m.mid.JwtAuth(), m.mid.Authorize(2), handler.RefundPayment)
+
+	// Guest orders are paid with their access token, before the /:user_id routes
+	router.Post("/guest/orders/:order_id", m.mid.ApiKeyAuth(), handler.CreateGuestPayment)
+	router.Post("/guest/:payment_id/confirm", m.mid.ApiKeyAuth(), handler.ConfirmGuestPayment)
+
+	router.Post("/:user_id/orders/:order_id", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.CreatePayment)
+	router.Post("/:user_id/:payment_id/confirm", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.ConfirmPayment)
+
+	router.Get("/:user_id/orders/:order_id", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.FindPayment)
+}
+
+func (m *moduleFactory) CreditsModule() {
+	repository := creditsRepositories.CreditsRepository(m.s.db)
+	usecase := creditsUsecases.CreditsUsecase(repository)
+	handler := creditsHandlers.CreditsHandler(m.s.cfg, usecase)
+
+	giftCardsRouter := m.r.Group("/giftcards")
+
+	giftCardsRouter.Post("/", m.mid.JwtAuth(), m.mid.Authorize(2), handler.InsertGiftCard)
+	giftCardsRouter.Post("/check", m.mid.ApiKeyAuth(), handler.CheckGiftCard)
+	giftCardsRouter.Post("/:user_id/purchase", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.PurchaseGiftCard)
+
+	giftCardsRouter.Get("/", m.mid.JwtAuth(), m.mid.Authorize(2), handler.FindGiftCard)
+	giftCardsRouter.Get("/:user_id", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.FindGiftCard)
+
+	creditsRouter := m.r.Group("/credits")
+
+	creditsRouter.Post("/:user_id", m.mid.JwtAuth(), m.mid.Authorize(2), handler.AdjustStoreCredit)
+
+	creditsRouter.Get("/:user_id", m.mid.JwtAuth(), m.mid.ParamsCheck(), handler.FindStoreCredit)
+}
+
+func (m *moduleFactory) JwksModule() {
+	repository := jwksRepositories.JwksRepository(m.s.db)
+	usecase := jwksUsecases.JwksUsecase(m.s.cfg, m.s.keys, repository)
+	handler := jwksHandlers.JwksHandler(m.s.cfg, usecase)
+
+	// The keys are loaded before the first token is signed
+	result, err := usecase.RotateKeys(context.Background())
+	if err != nil {
+		if m.s.cfg.Jwt().SigningAlg() != "HS256" {
+			log.Fatalf("load jwt keys failed: %v", err)
+		}
+		log.Printf("load jwt keys failed: %v", err)
+	} else {
+		log.Printf("jwt keys: %s", result)
+	}
+	m.s.scheduler.Add("rotate-jwt-keys", m.s.cfg.Jwt().KeyCheckInterval(), usecase.RotateKeys)
+
+	// At the root, not under /v1
+	m.s.app.Get("/.well-known/jwks.json", handler.FindJwks)
 }
@@ -7,6 +7,9 @@ import (
 	"os/signal"
 
 	"github.com/LGROW101/lgrow-shop/config"
+	"github.com/LGROW101/lgrow-shop/pkg/auth"
+	"github.com/LGROW101/lgrow-shop/pkg/mailer"
+	"github.com/LGROW101/lgrow-shop/pkg/scheduler"
 	"github.com/gofiber/fiber/v2"
 	"github.com/jmoiron/sqlx"
 )
@@ -17,15 +20,21 @@ type IServer interface {
 }
 
 type server struct {
-	app *fiber.App
-	cfg config.IConfig
-	db  *sqlx.DB
+	app       *fiber.App
+	cfg       config.IConfig
+	db        *sqlx.DB
+	scheduler scheduler.IScheduler
+	mailer    mailer.IMailer
+	keys      *auth.KeySet // jwt signing keys, loaded by the jwks module
 }
 
 func NewServer(cfg config.IConfig, db *sqlx.DB) IServer {
 	return &server{
-		cfg: cfg,
-		db:  db,
+		cfg:       cfg,
+		db:        db,
+		scheduler: scheduler.NewScheduler(),
+		mailer:    mailer.NewMailer(cfg.Mail()),
+		keys:      auth.NewKeySet(),
 		app: fiber.New(fiber.Config{
 			AppName:      cfg.App().Name(),
 			BodyLimit:    cfg.App().BodyLimit(),
@@ -53,21 +62,30 @@ func (s *server) Start() {
 
 	modules := InitModule(v1, s, middlewares)
 
+	modules.JwksModule()
 	modules.MonitorModule()
 	modules.UsersModule()
 	modules.AppinfoModule()
 	modules.FilesModule().Init()
 	modules.ProductsModule().Init()
 	modules.OrdersModule()
+	modules.ShippingModule()
+	modules.ReturnsModule()
+	modules.PaymentsModule()
+	modules.CreditsModule()
 
 	s.app.Use(middlewares.RouterCheck())
 
+	// Background jobs
+	s.scheduler.Start()
+
 	// Graceful Shutdown
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt)
 	go func() {
 		_ = <-c
 		log.Println("server is shutting down...")
+		s.scheduler.Stop()
 		_ = s.app.Shutdown()
 	}()
 
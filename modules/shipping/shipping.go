@@ -0,0 +1,90 @@
+package shipping
+
+import (
+	"math"
+	"strings"
+)
+
+type Zone struct {
+	Id        int      `db:"id" json:"id"`
+	Title     string   `db:"title" json:"title"`
+	Provinces []string `db:"provinces" json:"provinces"`
+	Postcodes []string `db:"postcodes" json:"postcodes"` // postcode prefixes
+	CreatedAt string   `db:"created_at" json:"created_at"`
+	UpdatedAt string   `db:"updated_at" json:"updated_at"`
+}
+
+type Method struct {
+	Id        int     `db:"id" json:"id"`
+	ZoneId    int     `db:"zone_id" json:"zone_id"`
+	Title     string  `db:"title" json:"title"`
+	Type      string  `db:"type" json:"type"` // flat | weight | free_over
+	Rate      float64 `db:"rate" json:"rate"`
+	RatePerKg float64 `db:"rate_per_kg" json:"rate_per_kg"`
+	FreeOver  float64 `db:"free_over" json:"free_over"`
+	IsActive  *bool   `db:"is_active" json:"is_active"`
+	CreatedAt string  `db:"created_at" json:"created_at"`
+	UpdatedAt string  `db:"updated_at" json:"updated_at"`
+}
+
+type MethodFilter struct {
+	ZoneId int `query:"zone_id"`
+}
+
+type Destination struct {
+	Province string `json:"province" form:"province"`
+	Postcode string `json:"postcode" form:"postcode"`
+}
+
+type Quote struct {
+	MethodId int     `json:"method_id"`
+	ZoneId   int     `json:"zone_id"`
+	Title    string  `json:"title"`
+	Type     string  `json:"type"`
+	Fee      float64 `json:"fee"`
+}
+
+func (obj *Method) IsTypeValid() bool {
+	typeMap := map[string]string{
+		"flat":      "flat",
+		"weight":    "weight",
+		"free_over": "free_over",
+	}
+	return typeMap[obj.Type] != ""
+}
+
+// CalculateFee returns the shipping fee for an order with the given subtotal and weight (kg)
+//
+// flat      -> rate
+// weight    -> rate + rate_per_kg * ceil(weight)
+// free_over -> 0 when subtotal >= free_over, otherwise rate
+func (obj *Method) CalculateFee(subtotal, weight float64) float64 {
+	switch obj.Type {
+	case "weight":
+		return obj.Rate + obj.RatePerKg*math.Ceil(weight)
+	case "free_over":
+		if subtotal >= obj.FreeOver {
+			return 0
+		}
+		return obj.Rate
+	default:
+		return obj.Rate
+	}
+}
+
+func (obj *Zone) Match(dest *Destination) bool {
+	if len(obj.Provinces) == 0 && len(obj.Postcodes) == 0 {
+		return true
+	}
+	for _, p := range obj.Postcodes {
+		if p != "" && strings.HasPrefix(strings.TrimSpace(dest.Postcode), p) {
+			return true
+		}
+	}
+	for _, p := range obj.Provinces {
+		if strings.EqualFold(strings.TrimSpace(dest.Province), strings.TrimSpace(p)) {
+			return true
+		}
+	}
+	return false
+}
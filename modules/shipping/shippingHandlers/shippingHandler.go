@@ -0,0 +1,252 @@
+package shippingHandlers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/LGROW101/lgrow-shop/config"
+	"github.com/LGROW101/lgrow-shop/modules/entities"
+	"github.com/LGROW101/lgrow-shop/modules/shipping"
+	"github.com/LGROW101/lgrow-shop/modules/shipping/shippingUsecases"
+	"github.com/gofiber/fiber/v2"
+)
+
+type shippingHandlersErrCode string
+
+const (
+	findZoneErr     shippingHandlersErrCode = "shipping-001"
+	addZoneErr      shippingHandlersErrCode = "shipping-002"
+	removeZoneErr   shippingHandlersErrCode = "shipping-003"
+	findMethodErr   shippingHandlersErrCode = "shipping-004"
+	addMethodErr    shippingHandlersErrCode = "shipping-005"
+	updateMethodErr shippingHandlersErrCode = "shipping-006"
+	removeMethodErr shippingHandlersErrCode = "shipping-007"
+)
+
+type IShippingHandler interface {
+	FindZone(c *fiber.Ctx) error
+	AddZone(c *fiber.Ctx) error
+	RemoveZone(c *fiber.Ctx) error
+	FindMethod(c *fiber.Ctx) error
+	AddMethod(c *fiber.Ctx) error
+	UpdateMethod(c *fiber.Ctx) error
+	RemoveMethod(c *fiber.Ctx) error
+}
+
+type shippingHandler struct {
+	cfg             config.IConfig
+	shippingUsecase shippingUsecases.IShippingUsecase
+}
+
+func ShippingHandler(cfg config.IConfig, shippingUsecase shippingUsecases.IShippingUsecase) IShippingHandler {
+	return &shippingHandler{
+		cfg:             cfg,
+		shippingUsecase: shippingUsecase,
+	}
+}
+
+func (h *shippingHandler) FindZone(c *fiber.Ctx) error {
+	zones, err := h.shippingUsecase.FindZone()
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findZoneErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, zones).Res()
+}
+
+func (h *shippingHandler) AddZone(c *fiber.Ctx) error {
+	req := new(shipping.Zone)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(addZoneErr),
+			err.Error(),
+		).Res()
+	}
+	if strings.TrimSpace(req.Title) == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(addZoneErr),
+			"title is required",
+		).Res()
+	}
+
+	zone, err := h.shippingUsecase.InsertZone(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(addZoneErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, zone).Res()
+}
+
+func (h *shippingHandler) RemoveZone(c *fiber.Ctx) error {
+	zoneId, err := strconv.Atoi(strings.Trim(c.Params("zone_id"), " "))
+	if err != nil || zoneId <= 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(removeZoneErr),
+			"id type is invalid",
+		).Res()
+	}
+
+	if err := h.shippingUsecase.DeleteZone(zoneId); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(removeZoneErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(
+		fiber.StatusOK,
+		&struct {
+			ZoneId int `json:"zone_id"`
+		}{
+			ZoneId: zoneId,
+		},
+	).Res()
+}
+
+func (h *shippingHandler) FindMethod(c *fiber.Ctx) error {
+	req := new(shipping.MethodFilter)
+	if err := c.QueryParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(findMethodErr),
+			err.Error(),
+		).Res()
+	}
+
+	methods, err := h.shippingUsecase.FindMethod(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findMethodErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, methods).Res()
+}
+
+func (h *shippingHandler) AddMethod(c *fiber.Ctx) error {
+	req := new(shipping.Method)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(addMethodErr),
+			err.Error(),
+		).Res()
+	}
+	if req.ZoneId <= 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(addMethodErr),
+			"zone id is invalid",
+		).Res()
+	}
+	if req.Rate < 0 || req.RatePerKg < 0 || req.FreeOver < 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(addMethodErr),
+			"rate must not be negative",
+		).Res()
+	}
+
+	method, err := h.shippingUsecase.InsertMethod(req)
+	if err != nil {
+		switch err.Error() {
+		case "method type is invalid":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(addMethodErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(addMethodErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, method).Res()
+}
+
+func (h *shippingHandler) UpdateMethod(c *fiber.Ctx) error {
+	methodId, err := strconv.Atoi(strings.Trim(c.Params("method_id"), " "))
+	if err != nil || methodId <= 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateMethodErr),
+			"id type is invalid",
+		).Res()
+	}
+
+	req := new(shipping.Method)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateMethodErr),
+			err.Error(),
+		).Res()
+	}
+	req.Id = methodId
+	if req.Rate < 0 || req.RatePerKg < 0 || req.FreeOver < 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateMethodErr),
+			"rate must not be negative",
+		).Res()
+	}
+
+	method, err := h.shippingUsecase.UpdateMethod(req)
+	if err != nil {
+		switch err.Error() {
+		case "method type is invalid", "nothing to update":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(updateMethodErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(updateMethodErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, method).Res()
+}
+
+func (h *shippingHandler) RemoveMethod(c *fiber.Ctx) error {
+	methodId, err := strconv.Atoi(strings.Trim(c.Params("method_id"), " "))
+	if err != nil || methodId <= 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(removeMethodErr),
+			"id type is invalid",
+		).Res()
+	}
+
+	if err := h.shippingUsecase.DeleteMethod(methodId); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(removeMethodErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(
+		fiber.StatusOK,
+		&struct {
+			MethodId int `json:"method_id"`
+		}{
+			MethodId: methodId,
+		},
+	).Res()
+}
@@ -0,0 +1,352 @@
+package shippingRepositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/LGROW101/lgrow-shop/modules/shipping"
+	"github.com/jmoiron/sqlx"
+)
+
+type IShippingRepository interface {
+	FindZone() ([]*shipping.Zone, error)
+	FindOneZone(zoneId int) (*shipping.Zone, error)
+	FindZoneByDestination(dest *shipping.Destination) (*shipping.Zone, error)
+	InsertZone(req *shipping.Zone) error
+	DeleteZone(zoneId int) error
+	FindMethod(req *shipping.MethodFilter) ([]*shipping.Method, error)
+	FindOneMethod(methodId int) (*shipping.Method, error)
+	InsertMethod(req *shipping.Method) error
+	UpdateMethod(req *shipping.Method) error
+	DeleteMethod(methodId int) error
+}
+
+type shippingRepository struct {
+	db *sqlx.DB
+}
+
+func ShippingRepository(db *sqlx.DB) IShippingRepository {
+	return &shippingRepository{db: db}
+}
+
+func (r *shippingRepository) FindZone() ([]*shipping.Zone, error) {
+	query := `
+	SELECT
+		COALESCE(array_to_json(array_agg("t")), '[]'::json)
+	FROM (
+		SELECT
+			"z"."id",
+			"z"."title",
+			"z"."provinces",
+			"z"."postcodes",
+			"z"."created_at",
+			"z"."updated_at"
+		FROM "shipping_zones" "z"
+		ORDER BY "z"."id" ASC
+	) AS "t";`
+
+	raw := make([]byte, 0)
+	if err := r.db.Get(&raw, query); err != nil {
+		return nil, fmt.Errorf("get zones failed: %v", err)
+	}
+
+	zones := make([]*shipping.Zone, 0)
+	if err := json.Unmarshal(raw, &zones); err != nil {
+		return nil, fmt.Errorf("unmarshal zones failed: %v", err)
+	}
+	return zones, nil
+}
+
+func (r *shippingRepository) FindOneZone(zoneId int) (*shipping.Zone, error) {
+	query := `
+	SELECT
+		to_jsonb("t")
+	FROM (
+		SELECT
+			"z"."id",
+			"z"."title",
+			"z"."provinces",
+			"z"."postcodes",
+			"z"."created_at",
+			"z"."updated_at"
+		FROM "shipping_zones" "z"
+		WHERE "z"."id" = $1
+	) AS "t";`
+
+	raw := make([]byte, 0)
+	if err := r.db.Get(&raw, query, zoneId); err != nil {
+		return nil, fmt.Errorf("get zone failed: %v", err)
+	}
+
+	zone := new(shipping.Zone)
+	if err := json.Unmarshal(raw, &zone); err != nil {
+		return nil, fmt.Errorf("unmarshal zone failed: %v", err)
+	}
+	return zone, nil
+}
+
+func (r *shippingRepository) FindZoneByDestination(dest *shipping.Destination) (*shipping.Zone, error) {
+	// Priority: postcode prefix -> province -> catch-all zone
+	query := `
+	SELECT
+		to_jsonb("t")
+	FROM (
+		SELECT
+			"z"."id",
+			"z"."title",
+			"z"."provinces",
+			"z"."postcodes",
+			"z"."created_at",
+			"z"."updated_at"
+		FROM "shipping_zones" "z"
+		ORDER BY
+			(CASE
+				WHEN EXISTS (
+					SELECT 1 FROM unnest("z"."postcodes") AS "pc"
+					WHERE "pc" <> '' AND left($2, length("pc")) = "pc"
+				) THEN 1
+				WHEN EXISTS (
+					SELECT 1 FROM unnest("z"."provinces") AS "pv"
+					WHERE LOWER("pv") = LOWER($1)
+				) THEN 2
+				WHEN cardinality("z"."provinces") = 0 AND cardinality("z"."postcodes") = 0 THEN 3
+				ELSE 4
+			END) ASC,
+			"z"."id" ASC
+		LIMIT 1
+	) AS "t";`
+
+	raw := make([]byte, 0)
+	if err := r.db.Get(&raw, query, strings.TrimSpace(dest.Province), strings.TrimSpace(dest.Postcode)); err != nil {
+		return nil, fmt.Errorf("shipping zone not found")
+	}
+
+	zone := new(shipping.Zone)
+	if err := json.Unmarshal(raw, &zone); err != nil {
+		return nil, fmt.Errorf("unmarshal zone failed: %v", err)
+	}
+	if !zone.Match(dest) {
+		return nil, fmt.Errorf("shipping zone not found")
+	}
+	return zone, nil
+}
+
+func (r *shippingRepository) InsertZone(req *shipping.Zone) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	query := `
+	INSERT INTO "shipping_zones" (
+		"title",
+		"provinces",
+		"postcodes"
+	)
+	VALUES ($1, $2, $3)
+		RETURNING "id";`
+
+	if err := r.db.QueryRowxContext(
+		ctx,
+		query,
+		req.Title,
+		req.Provinces,
+		req.Postcodes,
+	).Scan(&req.Id); err != nil {
+		return fmt.Errorf("insert zone failed: %v", err)
+	}
+	return nil
+}
+
+func (r *shippingRepository) DeleteZone(zoneId int) error {
+	query := `DELETE FROM "shipping_zones" WHERE "id" = $1;`
+
+	if _, err := r.db.ExecContext(context.Background(), query, zoneId); err != nil {
+		return fmt.Errorf("delete zone failed: %v", err)
+	}
+	return nil
+}
+
+func (r *shippingRepository) FindMethod(req *shipping.MethodFilter) ([]*shipping.Method, error) {
+	query := `
+	SELECT
+		"id",
+		"zone_id",
+		"title",
+		"type",
+		"rate",
+		"rate_per_kg",
+		"free_over",
+		"is_active",
+		"created_at",
+		"updated_at"
+	FROM "shipping_methods"`
+
+	filterValues := make([]any, 0)
+	if req.ZoneId != 0 {
+		query += `
+	WHERE "zone_id" = $1`
+
+		filterValues = append(filterValues, req.ZoneId)
+	}
+	query += `
+	ORDER BY "id" ASC;`
+
+	methods := make([]*shipping.Method, 0)
+	if err := r.db.Select(&methods, query, filterValues...); err != nil {
+		return nil, fmt.Errorf("select methods failed: %v", err)
+	}
+	return methods, nil
+}
+
+func (r *shippingRepository) FindOneMethod(methodId int) (*shipping.Method, error) {
+	query := `
+	SELECT
+		"id",
+		"zone_id",
+		"title",
+		"type",
+		"rate",
+		"rate_per_kg",
+		"free_over",
+		"is_active",
+		"created_at",
+		"updated_at"
+	FROM "shipping_methods"
+	WHERE "id" = $1;`
+
+	method := new(shipping.Method)
+	if err := r.db.Get(method, query, methodId); err != nil {
+		return nil, fmt.Errorf("get method failed: %v", err)
+	}
+	return method, nil
+}
+
+func (r *shippingRepository) InsertMethod(req *shipping.Method) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	query := `
+	INSERT INTO "shipping_methods" (
+		"zone_id",
+		"title",
+		"type",
+		"rate",
+		"rate_per_kg",
+		"free_over",
+		"is_active"
+	)
+	VALUES ($1, $2, $3, $4, $5, $6, COALESCE($7, TRUE))
+		RETURNING "id";`
+
+	if err := r.db.QueryRowxContext(
+		ctx,
+		query,
+		req.ZoneId,
+		req.Title,
+		req.Type,
+		req.Rate,
+		req.RatePerKg,
+		req.FreeOver,
+		req.IsActive,
+	).Scan(&req.Id); err != nil {
+		return fmt.Errorf("insert method failed: %v", err)
+	}
+	return nil
+}
+
+func (r *shippingRepository) UpdateMethod(req *shipping.Method) error {
+	query := `
+	UPDATE "shipping_methods" SET`
+
+	queryWhereStack := make([]string, 0)
+	values := make([]any, 0)
+	lastIndex := 1
+
+	if req.Title != "" {
+		values = append(values, req.Title)
+
+		queryWhereStack = append(queryWhereStack, fmt.Sprintf(`
+		"title" = $%d?`, lastIndex))
+
+		lastIndex++
+	}
+
+	if req.Type != "" {
+		values = append(values, req.Type)
+
+		queryWhereStack = append(queryWhereStack, fmt.Sprintf(`
+		"type" = $%d?`, lastIndex))
+
+		lastIndex++
+	}
+
+	if req.Rate != 0 {
+		values = append(values, req.Rate)
+
+		queryWhereStack = append(queryWhereStack, fmt.Sprintf(`
+		"rate" = $%d?`, lastIndex))
+
+		lastIndex++
+	}
+
+	if req.RatePerKg != 0 {
+		values = append(values, req.RatePerKg)
+
+		queryWhereStack = append(queryWhereStack, fmt.Sprintf(`
+		"rate_per_kg" = $%d?`, lastIndex))
+
+		lastIndex++
+	}
+
+	if req.FreeOver != 0 {
+		values = append(values, req.FreeOver)
+
+		queryWhereStack = append(queryWhereStack, fmt.Sprintf(`
+		"free_over" = $%d?`, lastIndex))
+
+		lastIndex++
+	}
+
+	if req.IsActive != nil {
+		values = append(values, *req.IsActive)
+
+		queryWhereStack = append(queryWhereStack, fmt.Sprintf(`
+		"is_active" = $%d?`, lastIndex))
+
+		lastIndex++
+	}
+
+	if len(queryWhereStack) == 0 {
+		return fmt.Errorf("nothing to update")
+	}
+
+	values = append(values, req.Id)
+
+	queryClose := fmt.Sprintf(`
+	WHERE "id" = $%d;`, lastIndex)
+
+	for i := range queryWhereStack {
+		if i != len(queryWhereStack)-1 {
+			query += strings.Replace(queryWhereStack[i], "?", ",", 1)
+		} else {
+			query += strings.Replace(queryWhereStack[i], "?", "", 1)
+		}
+	}
+	query += queryClose
+
+	if _, err := r.db.ExecContext(context.Background(), query, values...); err != nil {
+		return fmt.Errorf("update method failed: %v", err)
+	}
+	return nil
+}
+
+func (r *shippingRepository) DeleteMethod(methodId int) error {
+	query := `DELETE FROM "shipping_methods" WHERE "id" = $1;`
+
+	if _, err := r.db.ExecContext(context.Background(), query, methodId); err != nil {
+		return fmt.Errorf("delete method failed: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package shippingUsecases
+
+import (
+	"fmt"
+
+	"github.com/LGROW101/lgrow-shop/modules/shipping"
+	"github.com/LGROW101/lgrow-shop/modules/shipping/shippingRepositories"
+)
+
+type IShippingUsecase interface {
+	FindZone() ([]*shipping.Zone, error)
+	InsertZone(req *shipping.Zone) (*shipping.Zone, error)
+	DeleteZone(zoneId int) error
+	FindMethod(req *shipping.MethodFilter) ([]*shipping.Method, error)
+	InsertMethod(req *shipping.Method) (*shipping.Method, error)
+	UpdateMethod(req *shipping.Method) (*shipping.Method, error)
+	DeleteMethod(methodId int) error
+}
+
+type shippingUsecase struct {
+	shippingRepository shippingRepositories.IShippingRepository
+}
+
+func ShippingUsecase(shippingRepository shippingRepositories.IShippingRepository) IShippingUsecase {
+	return &shippingUsecase{
+		shippingRepository: shippingRepository,
+	}
+}
+
+func (u *shippingUsecase) FindZone() ([]*shipping.Zone, error) {
+	zones, err := u.shippingRepository.FindZone()
+	if err != nil {
+		return nil, err
+	}
+	return zones, nil
+}
+
+func (u *shippingUsecase) InsertZone(req *shipping.Zone) (*shipping.Zone, error) {
+	if req.Provinces == nil {
+		req.Provinces = make([]string, 0)
+	}
+	if req.Postcodes == nil {
+		req.Postcodes = make([]string, 0)
+	}
+
+	if err := u.shippingRepository.InsertZone(req); err != nil {
+		return nil, err
+	}
+
+	zone, err := u.shippingRepository.FindOneZone(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return zone, nil
+}
+
+func (u *shippingUsecase) DeleteZone(zoneId int) error {
+	if err := u.shippingRepository.DeleteZone(zoneId); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (u *shippingUsecase) FindMethod(req *shipping.MethodFilter) ([]*shipping.Method, error) {
+	methods, err := u.shippingRepository.FindMethod(req)
+	if err != nil {
+		return nil, err
+	}
+	return methods, nil
+}
+
+func (u *shippingUsecase) InsertMethod(req *shipping.Method) (*shipping.Method, error) {
+	if !req.IsTypeValid() {
+		return nil, fmt.Errorf("method type is invalid")
+	}
+	if _, err := u.shippingRepository.FindOneZone(req.ZoneId); err != nil {
+		return nil, err
+	}
+
+	if err := u.shippingRepository.InsertMethod(req); err != nil {
+		return nil, err
+	}
+
+	method, err := u.shippingRepository.FindOneMethod(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return method, nil
+}
+
+func (u *shippingUsecase) UpdateMethod(req *shipping.Method) (*shipping.Method, error) {
+	if req.Type != "" && !req.IsTypeValid() {
+		return nil, fmt.Errorf("method type is invalid")
+	}
+
+	if err := u.shippingRepository.UpdateMethod(req); err != nil {
+		return nil, err
+	}
+
+	method, err := u.shippingRepository.FindOneMethod(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return method, nil
+}
+
+func (u *shippingUsecase) DeleteMethod(methodId int) error {
+	if err := u.shippingRepository.DeleteMethod(methodId); err != nil {
+		return err
+	}
+	return nil
+}
@@ -1,36 +1,79 @@
 package users
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"regexp"
+	"strings"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 type User struct {
-	Id       string `db:"id" json:"id"`
-	Email    string `db:"email" json:"email"`
-	Username string `db:"username" json:"username"`
-	RoleId   int    `db:"role_id" json:"role_id"`
+	Id            string `db:"id" json:"id"`
+	Email         string `db:"email" json:"email"`
+	Username      string `db:"username" json:"username"`
+	RoleId        int    `db:"role_id" json:"role_id"`
+	EmailVerified bool   `db:"email_verified" json:"email_verified"`
 }
 
 type UserRegisterReq struct {
-	Email    string `db:"email" json:"emai" form:"email"`
-	Password string `db:"password" json:"password" form:"password"`
-	Username string `db:"username" json:"username" form:"username"`
+	Email            string `db:"email" json:"emai" form:"email"`
+	Password         string `db:"password" json:"password" form:"password"`
+	Username         string `db:"username" json:"username" form:"username"`
+	ClaimGuestOrders bool   `json:"claim_guest_orders" form:"claim_guest_orders"` // guest orders placed with the same email
+	CreatedBy        string `json:"-"`                                            // the admin who created an admin
 }
 
 type UserCredential struct {
-	Email    string `db:"email" json:"email" form:"email"`
-	Password string `db:"password" json:"password" form:"password"`
+	Email     string `db:"email" json:"email" form:"email"`
+	Password  string `db:"password" json:"password" form:"password"`
+	Ip        string `json:"-"`
+	UserAgent string `json:"-"`
+}
+
+// Throttle keys, the email is lowered so the case cannot be used to get more attempts
+func LoginAccountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func LoginIpKey(ip string) string {
+	return "ip:" + ip
+}
+
+// LoginMfaKey counts wrong totp codes after the password was right
+func LoginMfaKey(userId string) string {
+	return "mfa:" + userId
+}
+
+// LoginBackoff is how long sign in is blocked after failures in a row,
+// free failures cost nothing then it doubles from 1s until max locks for lockout
+func LoginBackoff(failures, free, max int, lockout time.Duration) time.Duration {
+	if failures >= max {
+		return lockout
+	}
+	if failures <= free {
+		return 0
+	}
+	backoff := time.Second
+	for i := free + 1; i < failures; i++ {
+		backoff *= 2
+		if backoff >= lockout {
+			return lockout
+		}
+	}
+	return backoff
 }
 
 type UserCredentialCheck struct {
-	Id       string `db:"id"`
-	Email    string `db:"email"`
-	Password string `db:"password"`
-	Username string `db:"username"`
-	RoleId   int    `db:"role_id"`
+	Id            string `db:"id"`
+	Email         string `db:"email"`
+	Password      string `db:"password"`
+	Username      string `db:"username"`
+	RoleId        int    `db:"role_id"`
+	EmailVerified bool   `db:"email_verified"`
 }
 
 func (obj *UserRegisterReq) BcryptHashing() error {
@@ -50,9 +93,15 @@ func (obj *UserRegisterReq) IsEmail() bool {
 	return match
 }
 
+// UserPassport has no token while mfa is required, mfa_token is exchanged with a code first
 type UserPassport struct {
-	User  *User      `json:"user"`
-	Token *UserToken `json:"token"`
+	User              *User      `json:"user"`
+	Token             *UserToken `json:"token"`
+	ClaimedOrders     []string   `json:"claimed_orders,omitempty"`
+	MfaRequired       bool       `json:"mfa_required,omitempty"`
+	MfaEnrollRequired bool       `json:"mfa_enroll_required,omitempty"` // enroll with mfa_token before signing in
+	MfaToken          string     `json:"mfa_token,omitempty"`
+	RecoveryCodes     []string   `json:"recovery_codes,omitempty"` // shown once when mfa is enabled
 }
 
 type UserToken struct {
@@ -66,8 +115,82 @@ type UserClaims struct {
 	RoleId int    `db:"role" json:"role"`
 }
 
+type UserVerifyEmailReq struct {
+	Token string `json:"token" form:"token"`
+}
+
+// UserUpdateReq changes the fields that are set, a new email has to be verified again
+type UserUpdateReq struct {
+	Id       string `json:"-"`
+	Username string `json:"username" form:"username"`
+	Email    string `json:"email" form:"email"`
+}
+
+type UserChangePasswordReq struct {
+	Id              string `json:"-"`
+	CurrentPassword string `json:"current_password" form:"current_password"`
+	NewPassword     string `json:"new_password" form:"new_password"`
+	AccessToken     string `json:"-"` // the session that asked stays signed in
+}
+
+type Mfa struct {
+	UserId       string `db:"user_id"`
+	Secret       string `db:"secret"`
+	Confirmed    bool   `db:"confirmed"`
+	LastUsedStep int64  `db:"last_used_step"`
+}
+
+type MfaSetup struct {
+	Secret          string `json:"secret"`
+	ProvisioningUri string `json:"provisioning_uri"`
+	QrCode          []byte `json:"qr_code"` // PNG, base64 in json
+}
+
+// MfaReq takes a totp code or a recovery code, MfaToken is set when signing in
+type MfaReq struct {
+	UserId    string `json:"-"`
+	MfaToken  string `json:"mfa_token" form:"mfa_token"`
+	Code      string `json:"code" form:"code"`
+	Ip        string `json:"-"`
+	UserAgent string `json:"-"`
+}
+
+// NewRecoveryCodes returns n codes formatted as xxxxx-xxxxx
+func NewRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("generate recovery codes failed: %v", err)
+		}
+		code := hex.EncodeToString(b)
+		codes = append(codes, code[:5]+"-"+code[5:])
+	}
+	return codes, nil
+}
+
+type UserForgotPasswordReq struct {
+	Email string `json:"email" form:"email"`
+}
+
+type UserResetPasswordReq struct {
+	Token    string `json:"token" form:"token"`
+	Password string `json:"password" form:"password"`
+}
+
+// NewResetToken returns a random password reset token, only auth.HashToken of it is kept
+func NewResetToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate reset token failed: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
 type UserRefreshCredential struct {
 	RefreshToken string `json:"refresh_token" form:"refresh_token"`
+	Ip           string `json:"-"`
+	UserAgent    string `json:"-"`
 }
 
 type Oauth struct {
@@ -75,6 +198,58 @@ type Oauth struct {
 	UserId string `db:"user_id" json:"user_id"`
 }
 
+// Session is an oauth row as its user sees it
+type Session struct {
+	Id          string `db:"id" json:"id"`
+	Ip          string `db:"ip" json:"ip"`
+	UserAgent   string `db:"user_agent" json:"user_agent"`
+	Current     bool   `db:"current" json:"current"`
+	CreatedAt   string `db:"created_at" json:"created_at"`
+	RefreshedAt string `db:"refreshed_at" json:"refreshed_at"`
+}
+
 type UserRemoveCredential struct {
 	OauthId string `json:"oauth_id" form:"oauth_id"`
 }
+
+type Address struct {
+	Id          string `db:"id" json:"id"`
+	UserId      string `db:"user_id" json:"user_id"`
+	Recipient   string `db:"recipient" json:"recipient" form:"recipient"`
+	Phone       string `db:"phone" json:"phone" form:"phone"`
+	Line1       string `db:"line1" json:"line1" form:"line1"`
+	Line2       string `db:"line2" json:"line2" form:"line2"`
+	Subdistrict string `db:"subdistrict" json:"subdistrict" form:"subdistrict"`
+	District    string `db:"district" json:"district" form:"district"`
+	Province    string `db:"province" json:"province" form:"province"`
+	Postcode    string `db:"postcode" json:"postcode" form:"postcode"`
+	IsDefault   bool   `db:"is_default" json:"is_default" form:"is_default"`
+	CreatedAt   string `db:"created_at" json:"created_at"`
+	UpdatedAt   string `db:"updated_at" json:"updated_at"`
+}
+
+func (obj *Address) IsComplete() bool {
+	for _, v := range []string{
+		obj.Recipient,
+		obj.Phone,
+		obj.Line1,
+		obj.Subdistrict,
+		obj.District,
+		obj.Province,
+		obj.Postcode,
+	} {
+		if strings.TrimSpace(v) == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Thai postcode has 5 digits
+func (obj *Address) IsPostcode() bool {
+	match, err := regexp.MatchString(`^[0-9]{5}$`, obj.Postcode)
+	if err != nil {
+		return false
+	}
+	return match
+}
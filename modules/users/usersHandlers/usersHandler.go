@@ -1,7 +1,6 @@
 package usersHandlers
 
 import (
-	"fmt"
 	"strings"
 
 	"github.com/LGROW101/lgrow-shop/config"
@@ -22,6 +21,23 @@ const (
 	signUpAdminErr        userHandlersErrCode = "users-005"
 	generateAdminTokenErr userHandlersErrCode = "users-006"
 	getUserProfileErr     userHandlersErrCode = "users-007"
+	findAddressErr        userHandlersErrCode = "users-008"
+	findOneAddressErr     userHandlersErrCode = "users-009"
+	insertAddressErr      userHandlersErrCode = "users-010"
+	updateAddressErr      userHandlersErrCode = "users-011"
+	deleteAddressErr      userHandlersErrCode = "users-012"
+	verifyEmailErr        userHandlersErrCode = "users-013"
+	forgotPasswordErr     userHandlersErrCode = "users-014"
+	resetPasswordErr      userHandlersErrCode = "users-015"
+	updateUserErr         userHandlersErrCode = "users-016"
+	changePasswordErr     userHandlersErrCode = "users-017"
+	findSessionErr        userHandlersErrCode = "users-018"
+	deleteSessionErr      userHandlersErrCode = "users-019"
+	verifyMfaErr          userHandlersErrCode = "users-020"
+	setupMfaErr           userHandlersErrCode = "users-021"
+	confirmMfaErr         userHandlersErrCode = "users-022"
+	disableMfaErr         userHandlersErrCode = "users-023"
+	unlockUserErr         userHandlersErrCode = "users-024"
 )
 
 type IUsersHandler interface {
@@ -32,6 +48,26 @@ type IUsersHandler interface {
 	SignUpAdmin(c *fiber.Ctx) error
 	GenerateAdminToken(c *fiber.Ctx) error
 	GetUserProfile(c *fiber.Ctx) error
+	FindAddress(c *fiber.Ctx) error
+	FindOneAddress(c *fiber.Ctx) error
+	AddAddress(c *fiber.Ctx) error
+	UpdateAddress(c *fiber.Ctx) error
+	RemoveAddress(c *fiber.Ctx) error
+	VerifyEmail(c *fiber.Ctx) error
+	ForgotPassword(c *fiber.Ctx) error
+	ResetPassword(c *fiber.Ctx) error
+	UpdateUser(c *fiber.Ctx) error
+	ChangePassword(c *fiber.Ctx) error
+	FindSession(c *fiber.Ctx) error
+	RemoveSession(c *fiber.Ctx) error
+	RemoveOtherSessions(c *fiber.Ctx) error
+	VerifyMfa(c *fiber.Ctx) error
+	EnrollMfa(c *fiber.Ctx) error
+	ConfirmEnrollMfa(c *fiber.Ctx) error
+	SetupMfa(c *fiber.Ctx) error
+	ConfirmMfa(c *fiber.Ctx) error
+	DisableMfa(c *fiber.Ctx) error
+	UnlockUser(c *fiber.Ctx) error
 }
 
 type usersHandler struct {
@@ -95,47 +131,56 @@ func (h *usersHandler) SignUpCustomer(c *fiber.Ctx) error {
 }
 
 func (h *usersHandler) SignUpAdmin(c *fiber.Ctx) error {
+	// Admin token from GET /users/admin/secret
+	if _, err := auth.ParseAdminToken(h.cfg.Jwt(), strings.TrimSpace(c.Get("X-Admin-Token"))); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrUnauthorized.Code,
+			string(signUpAdminErr),
+			err.Error(),
+		).Res()
+	}
 
 	// Request body parser
-	fmt.Println("Hello")
 	req := new(users.UserRegisterReq)
 	if err := c.BodyParser(req); err != nil {
 		return entities.NewResponse(c).Error(
 			fiber.ErrBadRequest.Code,
-			string(signUpCustomerErr),
+			string(signUpAdminErr),
 			err.Error(),
 		).Res()
 	}
+	req.CreatedBy = c.Locals("userId").(string)
+	req.ClaimGuestOrders = false
 
 	// Email validation
 	if !req.IsEmail() {
 		return entities.NewResponse(c).Error(
 			fiber.ErrBadRequest.Code,
-			string(signUpCustomerErr),
+			string(signUpAdminErr),
 			"email pattern is invalid",
 		).Res()
 	}
 
 	// Insert
-	result, err := h.usersUsecase.InsertCustomer(req)
+	result, err := h.usersUsecase.InsertAdmin(req)
 	if err != nil {
 		switch err.Error() {
 		case "username has been used":
 			return entities.NewResponse(c).Error(
 				fiber.ErrBadRequest.Code,
-				string(signUpCustomerErr),
+				string(signUpAdminErr),
 				err.Error(),
 			).Res()
 		case "email has been used":
 			return entities.NewResponse(c).Error(
 				fiber.ErrBadRequest.Code,
-				string(signUpCustomerErr),
+				string(signUpAdminErr),
 				err.Error(),
 			).Res()
 		default:
 			return entities.NewResponse(c).Error(
 				fiber.ErrInternalServerError.Code,
-				string(signUpCustomerErr),
+				string(signUpAdminErr),
 				err.Error(),
 			).Res()
 		}
@@ -143,11 +188,13 @@ func (h *usersHandler) SignUpAdmin(c *fiber.Ctx) error {
 
 	return entities.NewResponse(c).Success(fiber.StatusCreated, result).Res()
 }
+
 func (h *usersHandler) GenerateAdminToken(c *fiber.Ctx) error {
 	adminToken, err := auth.NewLgrowAuth(
 		auth.Admin,
 		h.cfg.Jwt(),
 		nil,
+		nil,
 	)
 	if err != nil {
 		return entities.NewResponse(c).Error(
@@ -176,13 +223,31 @@ func (h *usersHandler) SignIn(c *fiber.Ctx) error {
 			err.Error(),
 		).Res()
 	}
+	req.Ip = c.IP()
+	req.UserAgent = c.Get("User-Agent")
+
 	passport, err := h.usersUsecase.GetPassport(req)
 	if err != nil {
-		return entities.NewResponse(c).Error(
-			fiber.ErrBadRequest.Code,
-			string(signInErr),
-			err.Error(),
-		).Res()
+		switch err.Error() {
+		case "too many failed attempts, try again later":
+			return entities.NewResponse(c).Error(
+				fiber.ErrTooManyRequests.Code,
+				string(signInErr),
+				err.Error(),
+			).Res()
+		case "email or password is invalid", "email has not been verified":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(signInErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(signInErr),
+				err.Error(),
+			).Res()
+		}
 	}
 	return entities.NewResponse(c).Success(fiber.StatusOK, passport).Res()
 }
@@ -197,6 +262,9 @@ func (h *usersHandler) RefreshPassport(c *fiber.Ctx) error {
 		).Res()
 	}
 
+	req.Ip = c.IP()
+	req.UserAgent = c.Get("User-Agent")
+
 	passport, err := h.usersUsecase.RefreshPassport(req)
 	if err != nil {
 		return entities.NewResponse(c).Error(
@@ -255,3 +323,634 @@ func (h *usersHandler) GetUserProfile(c *fiber.Ctx) error {
 
 	return entities.NewResponse(c).Success(fiber.StatusOK, result).Res()
 }
+
+func (h *usersHandler) FindAddress(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+
+	addresses, err := h.usersUsecase.FindAddress(userId)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findAddressErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, addresses).Res()
+}
+
+func (h *usersHandler) FindOneAddress(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+	addressId := strings.Trim(c.Params("address_id"), " ")
+
+	address, err := h.usersUsecase.FindOneAddress(userId, addressId)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(findOneAddressErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, address).Res()
+}
+
+func (h *usersHandler) AddAddress(c *fiber.Ctx) error {
+	req := new(users.Address)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertAddressErr),
+			err.Error(),
+		).Res()
+	}
+	req.UserId = strings.Trim(c.Params("user_id"), " ")
+
+	if !req.IsComplete() {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertAddressErr),
+			"address is incomplete",
+		).Res()
+	}
+	if !req.IsPostcode() {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertAddressErr),
+			"postcode is invalid",
+		).Res()
+	}
+
+	address, err := h.usersUsecase.InsertAddress(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(insertAddressErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, address).Res()
+}
+
+func (h *usersHandler) UpdateAddress(c *fiber.Ctx) error {
+	req := new(users.Address)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateAddressErr),
+			err.Error(),
+		).Res()
+	}
+	req.Id = strings.Trim(c.Params("address_id"), " ")
+	req.UserId = strings.Trim(c.Params("user_id"), " ")
+
+	if !req.IsComplete() {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateAddressErr),
+			"address is incomplete",
+		).Res()
+	}
+	if !req.IsPostcode() {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateAddressErr),
+			"postcode is invalid",
+		).Res()
+	}
+
+	address, err := h.usersUsecase.UpdateAddress(req)
+	if err != nil {
+		switch err.Error() {
+		case "address not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(updateAddressErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(updateAddressErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, address).Res()
+}
+
+func (h *usersHandler) RemoveAddress(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+	addressId := strings.Trim(c.Params("address_id"), " ")
+
+	if err := h.usersUsecase.DeleteAddress(userId, addressId); err != nil {
+		switch err.Error() {
+		case "address not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(deleteAddressErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(deleteAddressErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, nil).Res()
+}
+
+func (h *usersHandler) VerifyEmail(c *fiber.Ctx) error {
+	req := new(users.UserVerifyEmailReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(verifyEmailErr),
+			err.Error(),
+		).Res()
+	}
+	if req.Token == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(verifyEmailErr),
+			"token is required",
+		).Res()
+	}
+
+	result, err := h.usersUsecase.VerifyEmail(req.Token)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(verifyEmailErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, result).Res()
+}
+
+func (h *usersHandler) ForgotPassword(c *fiber.Ctx) error {
+	req := new(users.UserForgotPasswordReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(forgotPasswordErr),
+			err.Error(),
+		).Res()
+	}
+	req.Email = strings.TrimSpace(req.Email)
+	if !(&users.UserRegisterReq{Email: req.Email}).IsEmail() {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(forgotPasswordErr),
+			"email pattern is invalid",
+		).Res()
+	}
+
+	if err := h.usersUsecase.ForgotPassword(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(forgotPasswordErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, nil).Res()
+}
+
+func (h *usersHandler) ResetPassword(c *fiber.Ctx) error {
+	req := new(users.UserResetPasswordReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(resetPasswordErr),
+			err.Error(),
+		).Res()
+	}
+	if req.Token == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(resetPasswordErr),
+			"token is required",
+		).Res()
+	}
+	if req.Password == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(resetPasswordErr),
+			"password is required",
+		).Res()
+	}
+
+	if err := h.usersUsecase.ResetPassword(req); err != nil {
+		switch err.Error() {
+		case "token is invalid", "token has been used", "token had expired":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(resetPasswordErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(resetPasswordErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, nil).Res()
+}
+
+func (h *usersHandler) UpdateUser(c *fiber.Ctx) error {
+	req := new(users.UserUpdateReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateUserErr),
+			err.Error(),
+		).Res()
+	}
+	req.Id = strings.Trim(c.Params("user_id"), " ")
+	req.Username = strings.TrimSpace(req.Username)
+	req.Email = strings.TrimSpace(req.Email)
+
+	if req.Username == "" && req.Email == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateUserErr),
+			"nothing to update",
+		).Res()
+	}
+	// Email validation
+	if req.Email != "" && !(&users.UserRegisterReq{Email: req.Email}).IsEmail() {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateUserErr),
+			"email pattern is invalid",
+		).Res()
+	}
+
+	result, err := h.usersUsecase.UpdateUser(req)
+	if err != nil {
+		switch err.Error() {
+		case "user not found", "username has been used", "email has been used":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(updateUserErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(updateUserErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, result).Res()
+}
+
+func (h *usersHandler) ChangePassword(c *fiber.Ctx) error {
+	req := new(users.UserChangePasswordReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(changePasswordErr),
+			err.Error(),
+		).Res()
+	}
+	req.Id = strings.Trim(c.Params("user_id"), " ")
+	req.AccessToken = strings.TrimPrefix(c.Get("Authorization"), "Bearer ")
+
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(changePasswordErr),
+			"current password and new password are required",
+		).Res()
+	}
+
+	if err := h.usersUsecase.ChangePassword(req); err != nil {
+		switch err.Error() {
+		case "user not found", "password is invalid":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(changePasswordErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(changePasswordErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, nil).Res()
+}
+
+func (h *usersHandler) FindSession(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+	accessToken := strings.TrimPrefix(c.Get("Authorization"), "Bearer ")
+
+	sessions, err := h.usersUsecase.FindSession(userId, accessToken)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findSessionErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, sessions).Res()
+}
+
+func (h *usersHandler) RemoveSession(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+	sessionId := strings.Trim(c.Params("session_id"), " ")
+
+	if err := h.usersUsecase.DeleteSession(userId, sessionId); err != nil {
+		switch err.Error() {
+		case "session not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(deleteSessionErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(deleteSessionErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, nil).Res()
+}
+
+// RemoveOtherSessions signs out everywhere else, the session making the request stays
+func (h *usersHandler) RemoveOtherSessions(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+	accessToken := strings.TrimPrefix(c.Get("Authorization"), "Bearer ")
+
+	result, err := h.usersUsecase.DeleteOtherSessions(userId, accessToken)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(deleteSessionErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, result).Res()
+}
+
+// isMfaClientErr tells the mfa errors caused by the request
+func isMfaClientErr(err error) bool {
+	switch err.Error() {
+	case "token is invalid",
+		"token had expired",
+		"token format is invalid",
+		"code is invalid",
+		"mfa not found",
+		"mfa is not enabled",
+		"mfa has been enabled",
+		"mfa is required for admins":
+		return true
+	}
+	return strings.HasPrefix(err.Error(), "parse token failed")
+}
+
+func (h *usersHandler) VerifyMfa(c *fiber.Ctx) error {
+	req := new(users.MfaReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(verifyMfaErr),
+			err.Error(),
+		).Res()
+	}
+	if req.MfaToken == "" || req.Code == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(verifyMfaErr),
+			"mfa token and code are required",
+		).Res()
+	}
+	req.Ip = c.IP()
+	req.UserAgent = c.Get("User-Agent")
+
+	passport, err := h.usersUsecase.VerifyMfa(req)
+	if err != nil {
+		if err.Error() == "too many failed attempts, try again later" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrTooManyRequests.Code,
+				string(verifyMfaErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(verifyMfaErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, passport).Res()
+}
+
+func (h *usersHandler) EnrollMfa(c *fiber.Ctx) error {
+	req := new(users.MfaReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(setupMfaErr),
+			err.Error(),
+		).Res()
+	}
+	if req.MfaToken == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(setupMfaErr),
+			"mfa token is required",
+		).Res()
+	}
+
+	setup, err := h.usersUsecase.EnrollMfa(req.MfaToken)
+	if err != nil {
+		if isMfaClientErr(err) {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(setupMfaErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(setupMfaErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, setup).Res()
+}
+
+func (h *usersHandler) ConfirmEnrollMfa(c *fiber.Ctx) error {
+	req := new(users.MfaReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(confirmMfaErr),
+			err.Error(),
+		).Res()
+	}
+	if req.MfaToken == "" || req.Code == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(confirmMfaErr),
+			"mfa token and code are required",
+		).Res()
+	}
+	req.Ip = c.IP()
+	req.UserAgent = c.Get("User-Agent")
+
+	passport, err := h.usersUsecase.ConfirmEnrollMfa(req)
+	if err != nil {
+		if isMfaClientErr(err) {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(confirmMfaErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(confirmMfaErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, passport).Res()
+}
+
+func (h *usersHandler) SetupMfa(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+
+	setup, err := h.usersUsecase.SetupMfa(userId)
+	if err != nil {
+		if isMfaClientErr(err) {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(setupMfaErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(setupMfaErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, setup).Res()
+}
+
+func (h *usersHandler) ConfirmMfa(c *fiber.Ctx) error {
+	req := new(users.MfaReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(confirmMfaErr),
+			err.Error(),
+		).Res()
+	}
+	req.UserId = strings.Trim(c.Params("user_id"), " ")
+	if req.Code == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(confirmMfaErr),
+			"code is required",
+		).Res()
+	}
+
+	codes, err := h.usersUsecase.ConfirmMfa(req)
+	if err != nil {
+		if isMfaClientErr(err) {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(confirmMfaErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(confirmMfaErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(
+		fiber.StatusOK,
+		&struct {
+			RecoveryCodes []string `json:"recovery_codes"`
+		}{
+			RecoveryCodes: codes,
+		},
+	).Res()
+}
+
+func (h *usersHandler) DisableMfa(c *fiber.Ctx) error {
+	req := new(users.MfaReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(disableMfaErr),
+			err.Error(),
+		).Res()
+	}
+	req.UserId = strings.Trim(c.Params("user_id"), " ")
+	req.Ip = c.IP()
+	if req.Code == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(disableMfaErr),
+			"code is required",
+		).Res()
+	}
+
+	if err := h.usersUsecase.DisableMfa(req); err != nil {
+		if err.Error() == "too many failed attempts, try again later" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrTooManyRequests.Code,
+				string(disableMfaErr),
+				err.Error(),
+			).Res()
+		}
+		if isMfaClientErr(err) {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(disableMfaErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(disableMfaErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, nil).Res()
+}
+
+func (h *usersHandler) UnlockUser(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+
+	if err := h.usersUsecase.UnlockUser(userId, c.Locals("userId").(string)); err != nil {
+		switch err.Error() {
+		case "user not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(unlockUserErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(unlockUserErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, nil).Res()
+}
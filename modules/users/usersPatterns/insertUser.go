@@ -94,16 +94,31 @@ func (f *userReq) Admin() (IInsertUser, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
 
+	// The creating admin goes to the audit log in the same statement
 	query := `
-	INSERT INTO "users" (
-		"email",
-		"password",
-		"username",
-		"role_id"
+	WITH "u" AS (
+		INSERT INTO "users" (
+			"email",
+			"password",
+			"username",
+			"role_id",
+			"email_verified_at"
+		)
+		VALUES
+			($1, $2, $3, 2, now())
+		RETURNING "id"
 	)
-	VALUES
-		($1, $2, $3, 2)
-	RETURNING "id";`
+	INSERT INTO "users_audit_logs" (
+		"user_id",
+		"actor_id",
+		"action"
+	)
+	SELECT
+		"id",
+		NULLIF($4, ''),
+		'admin created'
+	FROM "u"
+	RETURNING "user_id";`
 
 	if err := f.db.QueryRowContext(
 		ctx,
@@ -111,6 +126,7 @@ func (f *userReq) Admin() (IInsertUser, error) {
 		f.req.Email,
 		f.req.Password,
 		f.req.Username,
+		f.req.CreatedBy,
 	).Scan(&f.id); err != nil {
 		switch err.Error() {
 		case "ERROR: duplicate key value violates unique constraint \"users_username_key\" (SQLSTATE 23505)":
@@ -137,7 +153,8 @@ func (f *userReq) Result() (*users.UserPassport, error) {
 			"u"."id",
 			"u"."email",
 			"u"."username",
-			"u"."role_id"
+			"u"."role_id",
+			"u"."email_verified_at" IS NOT NULL AS "email_verified"
 		FROM "users" "u"
 		WHERE "u"."id" = $1
 	) AS "t"`
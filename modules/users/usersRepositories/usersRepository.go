@@ -2,22 +2,54 @@ package usersRepositories
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/LGROW101/lgrow-shop/modules/users"
 	"github.com/LGROW101/lgrow-shop/modules/users/usersPatterns"
+	"github.com/LGROW101/lgrow-shop/pkg/auth"
 	"github.com/jmoiron/sqlx"
 )
 
 type IUsersRepository interface {
 	InsertUser(req *users.UserRegisterReq, isAdmin bool) (*users.UserPassport, error)
 	FindOneUserByEmail(email string) (*users.UserCredentialCheck, error)
-	InsertOauth(req *users.UserPassport) error
+	InsertOauth(req *users.UserPassport, ip, userAgent string) error
 	FindOneOauth(refreshToken string) (*users.Oauth, error)
-	UpdateOauth(req *users.UserToken) error
+	UpdateOauth(req *users.UserToken, oldRefreshToken string) error
+	FindRotatedOauth(refreshToken string) (*users.Oauth, error)
+	RevokeOauthFamily(oauth *users.Oauth, detail string) error
 	GetProfile(userId string) (*users.User, error)
 	DeleteOauth(oauthId string) error
+	FindAddress(userId string) ([]*users.Address, error)
+	FindOneAddress(addressId string) (*users.Address, error)
+	InsertAddress(req *users.Address) error
+	UpdateAddress(req *users.Address) error
+	DeleteAddress(userId, addressId string) error
+	ClaimGuestOrders(userId, email string) ([]string, error)
+	InsertEmailVerification(userId, email string, claimGuestOrders bool, expiresAt time.Time) (string, error)
+	VerifyEmail(verificationId, userId string) (bool, error)
+	InsertPasswordReset(userId, tokenHash string, expiresAt time.Time) error
+	ResetPassword(tokenHash, password string) error
+	UpdateUser(req *users.UserUpdateReq) (bool, error)
+	FindOneUserPassword(userId string) (string, error)
+	UpdatePassword(userId, password, keepAccessToken string) error
+	FindSession(userId, accessToken string) ([]*users.Session, error)
+	DeleteSession(userId, sessionId string) error
+	DeleteOtherSessions(userId, keepAccessToken string) (int64, error)
+	FindOneMfa(userId string) (*users.Mfa, error)
+	UpsertMfaSecret(userId, secret string) error
+	ConfirmMfa(userId string, step int64, recoveryCodeHashes []string) error
+	UseMfaStep(userId string, step int64) error
+	UseRecoveryCode(userId, codeHash string) error
+	DeleteMfa(userId string) error
+	IsLoginBlocked(keys ...string) (bool, error)
+	InsertLoginFailure(key string, window time.Duration) (int, error)
+	BlockLogin(key string, duration time.Duration) error
+	DeleteLoginFailures(key string) error
+	UnlockUser(userId, actorId string) error
 }
 
 type usersRepository struct {
@@ -61,7 +93,8 @@ func (r *usersRepository) FindOneUserByEmail(email string) (*users.UserCredentia
 		"email",
 		"password",
 		"username",
-		"role_id"
+		"role_id",
+		"email_verified_at" IS NOT NULL AS "email_verified"
 	FROM "users"
 	WHERE "email" = $1;`
 
@@ -72,25 +105,29 @@ func (r *usersRepository) FindOneUserByEmail(email string) (*users.UserCredentia
 	return user, nil
 }
 
-func (r *usersRepository) InsertOauth(req *users.UserPassport) error {
+func (r *usersRepository) InsertOauth(req *users.UserPassport, ip, userAgent string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	query := `
 	INSERT INTO "oauth" (
 		"user_id",
-		"refresh_token",
-		"access_token"
+		"refresh_token_hash",
+		"access_token_hash",
+		"ip",
+		"user_agent"
 	)
-	VALUES ($1, $2, $3)
+	VALUES ($1, $2, $3, $4, $5)
 		RETURNING "id";`
 
 	if err := r.db.QueryRowContext(
 		ctx,
 		query,
 		req.User.Id,
-		req.Token.RefreshToken,
-		req.Token.AccessToken,
+		auth.HashToken(req.Token.RefreshToken),
+		auth.HashToken(req.Token.AccessToken),
+		ip,
+		userAgent,
 	).Scan(&req.Token.Id); err != nil {
 		return fmt.Errorf("insert oauth failed: %v", err)
 	}
@@ -103,25 +140,109 @@ func (r *usersRepository) FindOneOauth(refreshToken string) (*users.Oauth, error
 		"id",
 		"user_id"
 	FROM "oauth"
-	WHERE "refresh_token" = $1;`
+	WHERE "refresh_token_hash" = $1;`
 
 	oauth := new(users.Oauth)
-	if err := r.db.Get(oauth, query, refreshToken); err != nil {
+	if err := r.db.Get(oauth, query, auth.HashToken(refreshToken)); err != nil {
 		return nil, fmt.Errorf("oauth not found")
 	}
 	return oauth, nil
 }
 
-func (r *usersRepository) UpdateOauth(req *users.UserToken) error {
+// UpdateOauth rotates the tokens of the family, oldRefreshToken must still be the current one
+func (r *usersRepository) UpdateOauth(req *users.UserToken, oldRefreshToken string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	var oauthId string
+	if err := tx.QueryRowxContext(ctx, `SELECT "id" FROM "oauth" WHERE "id" = $1 AND "refresh_token_hash" = $2 FOR UPDATE;`, req.Id, auth.HashToken(oldRefreshToken)).Scan(&oauthId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("oauth not found")
+	}
+
+	queryRotated := `
+	INSERT INTO "oauth_rotated_tokens" (
+		"oauth_id",
+		"refresh_token_hash"
+	)
+	VALUES ($1, $2);`
+
+	if _, err := tx.ExecContext(ctx, queryRotated, req.Id, auth.HashToken(oldRefreshToken)); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("insert oauth_rotated_tokens failed: %v", err)
+	}
+
 	query := `
 	UPDATE "oauth" SET
-		"access_token" = :access_token,
-		"refresh_token" = :refresh_token
-	WHERE "id" = :id;`
+		"access_token_hash" = $2,
+		"refresh_token_hash" = $3
+	WHERE "id" = $1;`
 
-	if _, err := r.db.NamedExecContext(context.Background(), query, req); err != nil {
+	if _, err := tx.ExecContext(ctx, query, req.Id, auth.HashToken(req.AccessToken), auth.HashToken(req.RefreshToken)); err != nil {
+		tx.Rollback()
 		return fmt.Errorf("update oauth failed: %v", err)
 	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// FindRotatedOauth finds the family a rotated refresh token belonged to
+func (r *usersRepository) FindRotatedOauth(refreshToken string) (*users.Oauth, error) {
+	query := `
+	SELECT
+		"o"."id",
+		"o"."user_id"
+	FROM "oauth_rotated_tokens" "t"
+	JOIN "oauth" "o" ON "o"."id" = "t"."oauth_id"
+	WHERE "t"."refresh_token_hash" = $1
+	LIMIT 1;`
+
+	oauth := new(users.Oauth)
+	if err := r.db.Get(oauth, query, auth.HashToken(refreshToken)); err != nil {
+		return nil, fmt.Errorf("oauth not found")
+	}
+	return oauth, nil
+}
+
+// RevokeOauthFamily signs out the family and keeps a security event in the audit log
+func (r *usersRepository) RevokeOauthFamily(oauth *users.Oauth, detail string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM "oauth" WHERE "id" = $1;`, oauth.Id); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("delete oauth failed: %v", err)
+	}
+
+	query := `
+	INSERT INTO "users_audit_logs" (
+		"user_id",
+		"action",
+		"detail"
+	)
+	VALUES ($1, 'refresh token reused', $2);`
+
+	if _, err := tx.ExecContext(ctx, query, oauth.UserId, detail); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("insert users_audit_logs failed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -131,7 +252,8 @@ func (r *usersRepository) GetProfile(userId string) (*users.User, error) {
 		"id",
 		"email",
 		"username",
-		"role_id"
+		"role_id",
+		"email_verified_at" IS NOT NULL AS "email_verified"
 	FROM "users"
 	WHERE "id" = $1;`
 
@@ -150,3 +272,757 @@ func (r *usersRepository) DeleteOauth(oauthId string) error {
 	}
 	return nil
 }
+
+func (r *usersRepository) FindAddress(userId string) ([]*users.Address, error) {
+	query := `
+	SELECT
+		"id",
+		"user_id",
+		"recipient",
+		"phone",
+		"line1",
+		"line2",
+		"subdistrict",
+		"district",
+		"province",
+		"postcode",
+		"is_default",
+		"created_at",
+		"updated_at"
+	FROM "addresses"
+	WHERE "user_id" = $1
+	ORDER BY "is_default" DESC, "created_at" ASC;`
+
+	addresses := make([]*users.Address, 0)
+	if err := r.db.Select(&addresses, query, userId); err != nil {
+		return nil, fmt.Errorf("get addresses failed: %v", err)
+	}
+	return addresses, nil
+}
+
+func (r *usersRepository) FindOneAddress(addressId string) (*users.Address, error) {
+	query := `
+	SELECT
+		"id",
+		"user_id",
+		"recipient",
+		"phone",
+		"line1",
+		"line2",
+		"subdistrict",
+		"district",
+		"province",
+		"postcode",
+		"is_default",
+		"created_at",
+		"updated_at"
+	FROM "addresses"
+	WHERE "id" = $1;`
+
+	address := new(users.Address)
+	if err := r.db.Get(address, query, addressId); err != nil {
+		return nil, fmt.Errorf("address not found")
+	}
+	return address, nil
+}
+
+// clearDefaultAddress unsets the current default address of the user,
+// the first address of a user always becomes the default one
+func clearDefaultAddress(ctx context.Context, tx *sqlx.Tx, req *users.Address) error {
+	if !req.IsDefault {
+		var count int
+		if err := tx.GetContext(ctx, &count, `SELECT COUNT(*) FROM "addresses" WHERE "user_id" = $1 AND "is_default";`, req.UserId); err != nil {
+			return fmt.Errorf("get default address failed: %v", err)
+		}
+		if count > 0 {
+			return nil
+		}
+		req.IsDefault = true
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE "addresses" SET "is_default" = FALSE WHERE "user_id" = $1 AND "is_default";`, req.UserId); err != nil {
+		return fmt.Errorf("update default address failed: %v", err)
+	}
+	return nil
+}
+
+func (r *usersRepository) InsertAddress(req *users.Address) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := clearDefaultAddress(ctx, tx, req); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	query := `
+	INSERT INTO "addresses" (
+		"user_id",
+		"recipient",
+		"phone",
+		"line1",
+		"line2",
+		"subdistrict",
+		"district",
+		"province",
+		"postcode",
+		"is_default"
+	)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING "id";`
+
+	if err := tx.QueryRowxContext(
+		ctx,
+		query,
+		req.UserId,
+		req.Recipient,
+		req.Phone,
+		req.Line1,
+		req.Line2,
+		req.Subdistrict,
+		req.District,
+		req.Province,
+		req.Postcode,
+		req.IsDefault,
+	).Scan(&req.Id); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("insert address failed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *usersRepository) UpdateAddress(req *users.Address) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	var isDefault bool
+	if err := tx.GetContext(ctx, &isDefault, `SELECT "is_default" FROM "addresses" WHERE "id" = $1 AND "user_id" = $2 FOR UPDATE;`, req.Id, req.UserId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("address not found")
+	}
+
+	// The default address can only be replaced by setting another one
+	if isDefault {
+		req.IsDefault = true
+	} else if req.IsDefault {
+		if err := clearDefaultAddress(ctx, tx, req); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	query := `
+	UPDATE "addresses" SET
+		"recipient" = :recipient,
+		"phone" = :phone,
+		"line1" = :line1,
+		"line2" = :line2,
+		"subdistrict" = :subdistrict,
+		"district" = :district,
+		"province" = :province,
+		"postcode" = :postcode,
+		"is_default" = :is_default
+	WHERE "id" = :id;`
+
+	if _, err := tx.NamedExecContext(ctx, query, req); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("update address failed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *usersRepository) DeleteAddress(userId, addressId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	var isDefault bool
+	if err := tx.QueryRowxContext(ctx, `DELETE FROM "addresses" WHERE "id" = $1 AND "user_id" = $2 RETURNING "is_default";`, addressId, userId).Scan(&isDefault); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("address not found")
+	}
+
+	// Promote the oldest address when the default one is removed
+	if isDefault {
+		query := `
+		UPDATE "addresses" SET
+			"is_default" = TRUE
+		WHERE "id" = (
+			SELECT
+				"id"
+			FROM "addresses"
+			WHERE "user_id" = $1
+			ORDER BY "created_at" ASC
+			LIMIT 1
+		);`
+
+		if _, err := tx.ExecContext(ctx, query, userId); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("update default address failed: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ClaimGuestOrders moves the unclaimed guest orders of the email to the user,
+// the order access tokens stop working afterwards
+func (r *usersRepository) ClaimGuestOrders(userId, email string) ([]string, error) {
+	query := `
+	WITH "claimed" AS (
+		UPDATE "orders" SET
+			"user_id" = $1,
+			"guest_token" = NULL
+		WHERE "user_id" IS NULL
+		AND LOWER("guest_email") = LOWER($2)
+		RETURNING "id", "status"
+	)
+	INSERT INTO "orders_histories" (
+		"order_id",
+		"status",
+		"note"
+	)
+	SELECT
+		"id",
+		"status",
+		'claimed by the account ' || $1
+	FROM "claimed"
+	RETURNING "order_id";`
+
+	orderIds := make([]string, 0)
+	if err := r.db.Select(&orderIds, query, userId, email); err != nil {
+		return nil, fmt.Errorf("claim guest orders failed: %v", err)
+	}
+	return orderIds, nil
+}
+
+func (r *usersRepository) InsertEmailVerification(userId, email string, claimGuestOrders bool, expiresAt time.Time) (string, error) {
+	query := `
+	INSERT INTO "users_email_verifications" (
+		"user_id",
+		"email",
+		"claim_guest_orders",
+		"expires_at"
+	)
+	VALUES ($1, $2, $3, $4)
+		RETURNING "id";`
+
+	var verificationId string
+	if err := r.db.QueryRowx(query, userId, email, claimGuestOrders, expiresAt).Scan(&verificationId); err != nil {
+		return "", fmt.Errorf("insert email verification failed: %v", err)
+	}
+	return verificationId, nil
+}
+
+// VerifyEmail uses up the verification, it fails when the user has changed the email since it was sent
+func (r *usersRepository) VerifyEmail(verificationId, userId string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	query := `
+	SELECT
+		"v"."email" = "u"."email" AS "same_email",
+		"v"."used_at" IS NOT NULL AS "used",
+		"v"."expires_at" < now() AS "expired",
+		"v"."claim_guest_orders"
+	FROM "users_email_verifications" "v"
+	JOIN "users" "u" ON "u"."id" = "v"."user_id"
+	WHERE "v"."id" = $1
+	AND "v"."user_id" = $2
+	FOR UPDATE OF "v";`
+
+	var sameEmail, used, expired, claimGuestOrders bool
+	if err := tx.QueryRowxContext(ctx, query, verificationId, userId).Scan(&sameEmail, &used, &expired, &claimGuestOrders); err != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("token is invalid")
+	}
+	switch {
+	case used:
+		tx.Rollback()
+		return false, fmt.Errorf("token has been used")
+	case expired:
+		tx.Rollback()
+		return false, fmt.Errorf("token had expired")
+	case !sameEmail:
+		tx.Rollback()
+		return false, fmt.Errorf("token is invalid")
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE "users_email_verifications" SET "used_at" = now() WHERE "id" = $1;`, verificationId); err != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("update email verification failed: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE "users" SET "email_verified_at" = now() WHERE "id" = $1;`, userId); err != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("update user failed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return claimGuestOrders, nil
+}
+
+func (r *usersRepository) InsertPasswordReset(userId, tokenHash string, expiresAt time.Time) error {
+	query := `
+	INSERT INTO "users_password_resets" (
+		"user_id",
+		"token_hash",
+		"expires_at"
+	)
+	VALUES ($1, $2, $3);`
+
+	if _, err := r.db.Exec(query, userId, tokenHash, expiresAt); err != nil {
+		return fmt.Errorf("insert password reset failed: %v", err)
+	}
+	return nil
+}
+
+// ResetPassword sets the new password, uses up every reset of the user and signs out all sessions
+func (r *usersRepository) ResetPassword(tokenHash, password string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	SELECT
+		"user_id",
+		"used_at" IS NOT NULL AS "used",
+		"expires_at" < now() AS "expired"
+	FROM "users_password_resets"
+	WHERE "token_hash" = $1
+	FOR UPDATE;`
+
+	var (
+		userId        string
+		used, expired bool
+	)
+	if err := tx.QueryRowxContext(ctx, query, tokenHash).Scan(&userId, &used, &expired); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("token is invalid")
+	}
+	switch {
+	case used:
+		tx.Rollback()
+		return fmt.Errorf("token has been used")
+	case expired:
+		tx.Rollback()
+		return fmt.Errorf("token had expired")
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE "users" SET "password" = $1 WHERE "id" = $2;`, password, userId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("update password failed: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE "users_password_resets" SET "used_at" = now() WHERE "user_id" = $1 AND "used_at" IS NULL;`, userId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("update password resets failed: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM "oauth" WHERE "user_id" = $1;`, userId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("delete oauth failed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateUser returns true when the email has changed, the email is unverified until then
+func (r *usersRepository) UpdateUser(req *users.UserUpdateReq) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	var email string
+	if err := tx.QueryRowxContext(ctx, `SELECT "email" FROM "users" WHERE "id" = $1 FOR UPDATE;`, req.Id).Scan(&email); err != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("user not found")
+	}
+	emailChanged := req.Email != "" && req.Email != email
+
+	query := `
+	UPDATE "users" SET
+		"username" = COALESCE(NULLIF($2, ''), "username"),
+		"email" = COALESCE(NULLIF($3, ''), "email"),
+		"email_verified_at" = CASE WHEN $4::BOOLEAN THEN NULL ELSE "email_verified_at" END
+	WHERE "id" = $1;`
+
+	if _, err := tx.ExecContext(ctx, query, req.Id, req.Username, req.Email, emailChanged); err != nil {
+		tx.Rollback()
+		switch err.Error() {
+		case "ERROR: duplicate key value violates unique constraint \"users_username_key\" (SQLSTATE 23505)":
+			return false, fmt.Errorf("username has been used")
+		case "ERROR: duplicate key value violates unique constraint \"users_email_key\" (SQLSTATE 23505)":
+			return false, fmt.Errorf("email has been used")
+		default:
+			return false, fmt.Errorf("update user failed: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return emailChanged, nil
+}
+
+func (r *usersRepository) FindOneUserPassword(userId string) (string, error) {
+	var password string
+	if err := r.db.Get(&password, `SELECT "password" FROM "users" WHERE "id" = $1;`, userId); err != nil {
+		return "", fmt.Errorf("user not found")
+	}
+	return password, nil
+}
+
+// UpdatePassword signs out every other session of the user
+func (r *usersRepository) UpdatePassword(userId, password, keepAccessToken string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE "users" SET "password" = $1 WHERE "id" = $2;`, password, userId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("update password failed: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM "oauth" WHERE "user_id" = $1 AND "access_token_hash" <> $2;`, userId, auth.HashToken(keepAccessToken)); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("delete oauth failed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// FindSession marks the session of accessToken as the current one
+func (r *usersRepository) FindSession(userId, accessToken string) ([]*users.Session, error) {
+	query := `
+	SELECT
+		"id",
+		"ip",
+		"user_agent",
+		"access_token_hash" = $2 AS "current",
+		"created_at",
+		"updated_at" AS "refreshed_at"
+	FROM "oauth"
+	WHERE "user_id" = $1
+	ORDER BY "updated_at" DESC;`
+
+	sessions := make([]*users.Session, 0)
+	if err := r.db.Select(&sessions, query, userId, auth.HashToken(accessToken)); err != nil {
+		return nil, fmt.Errorf("get sessions failed: %v", err)
+	}
+	return sessions, nil
+}
+
+func (r *usersRepository) DeleteSession(userId, sessionId string) error {
+	result, err := r.db.Exec(`DELETE FROM "oauth" WHERE "id"::TEXT = $1 AND "user_id" = $2;`, sessionId, userId)
+	if err != nil {
+		return fmt.Errorf("delete session failed: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("session not found")
+	}
+	return nil
+}
+
+// DeleteOtherSessions signs out everywhere except the session of keepAccessToken
+func (r *usersRepository) DeleteOtherSessions(userId, keepAccessToken string) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM "oauth" WHERE "user_id" = $1 AND "access_token_hash" <> $2;`, userId, auth.HashToken(keepAccessToken))
+	if err != nil {
+		return 0, fmt.Errorf("delete sessions failed: %v", err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows, nil
+}
+
+func (r *usersRepository) FindOneMfa(userId string) (*users.Mfa, error) {
+	query := `
+	SELECT
+		"user_id",
+		"secret",
+		"confirmed_at" IS NOT NULL AS "confirmed",
+		"last_used_step"
+	FROM "users_mfa"
+	WHERE "user_id" = $1;`
+
+	mfa := new(users.Mfa)
+	if err := r.db.Get(mfa, query, userId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("mfa not found")
+		}
+		return nil, fmt.Errorf("get mfa failed: %v", err)
+	}
+	return mfa, nil
+}
+
+// UpsertMfaSecret starts over an unconfirmed enrollment, an enabled one is left alone
+func (r *usersRepository) UpsertMfaSecret(userId, secret string) error {
+	query := `
+	INSERT INTO "users_mfa" (
+		"user_id",
+		"secret"
+	)
+	VALUES ($1, $2)
+	ON CONFLICT ("user_id") DO UPDATE SET
+		"secret" = EXCLUDED."secret",
+		"last_used_step" = 0
+	WHERE "users_mfa"."confirmed_at" IS NULL;`
+
+	result, err := r.db.Exec(query, userId, secret)
+	if err != nil {
+		return fmt.Errorf("insert users_mfa failed: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("mfa has been enabled")
+	}
+	return nil
+}
+
+func (r *usersRepository) ConfirmMfa(userId string, step int64, recoveryCodeHashes []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	UPDATE "users_mfa" SET
+		"confirmed_at" = now(),
+		"last_used_step" = $2
+	WHERE "user_id" = $1
+	AND "confirmed_at" IS NULL;`
+
+	result, err := tx.ExecContext(ctx, query, userId, step)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("update users_mfa failed: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		tx.Rollback()
+		return fmt.Errorf("mfa has been enabled")
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM "users_mfa_recovery_codes" WHERE "user_id" = $1;`, userId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("delete users_mfa_recovery_codes failed: %v", err)
+	}
+	for _, codeHash := range recoveryCodeHashes {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO "users_mfa_recovery_codes" ("user_id", "code_hash") VALUES ($1, $2);`, userId, codeHash); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("insert users_mfa_recovery_codes failed: %v", err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO "users_audit_logs" ("user_id", "actor_id", "action") VALUES ($1, $1, 'mfa enabled');`, userId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("insert users_audit_logs failed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UseMfaStep refuses a step that is not newer than the last one used
+func (r *usersRepository) UseMfaStep(userId string, step int64) error {
+	query := `
+	UPDATE "users_mfa" SET
+		"last_used_step" = $2
+	WHERE "user_id" = $1
+	AND "confirmed_at" IS NOT NULL
+	AND "last_used_step" < $2;`
+
+	result, err := r.db.Exec(query, userId, step)
+	if err != nil {
+		return fmt.Errorf("update users_mfa failed: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("code is invalid")
+	}
+	return nil
+}
+
+func (r *usersRepository) UseRecoveryCode(userId, codeHash string) error {
+	query := `
+	UPDATE "users_mfa_recovery_codes" SET
+		"used_at" = now()
+	WHERE "user_id" = $1
+	AND "code_hash" = $2
+	AND "used_at" IS NULL;`
+
+	result, err := r.db.Exec(query, userId, codeHash)
+	if err != nil {
+		return fmt.Errorf("update users_mfa_recovery_codes failed: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("code is invalid")
+	}
+	return nil
+}
+
+func (r *usersRepository) DeleteMfa(userId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM "users_mfa_recovery_codes" WHERE "user_id" = $1;`, userId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("delete users_mfa_recovery_codes failed: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM "users_mfa" WHERE "user_id" = $1;`, userId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("delete users_mfa failed: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO "users_audit_logs" ("user_id", "actor_id", "action") VALUES ($1, $1, 'mfa disabled');`, userId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("insert users_audit_logs failed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *usersRepository) IsLoginBlocked(keys ...string) (bool, error) {
+	query := `
+	SELECT EXISTS (
+		SELECT 1
+		FROM "users_login_throttles"
+		WHERE "key" = ANY($1)
+		AND "blocked_until" > now()
+	);`
+
+	var blocked bool
+	if err := r.db.Get(&blocked, query, keys); err != nil {
+		return false, fmt.Errorf("get users_login_throttles failed: %v", err)
+	}
+	return blocked, nil
+}
+
+// InsertLoginFailure counts a failure on key, the count starts over when the
+// last failure is older than window
+func (r *usersRepository) InsertLoginFailure(key string, window time.Duration) (int, error) {
+	query := `
+	INSERT INTO "users_login_throttles" (
+		"key",
+		"failures"
+	)
+	VALUES ($1, 1)
+	ON CONFLICT ("key") DO UPDATE SET
+		"failures" = CASE
+			WHEN "users_login_throttles"."last_failed_at" < now() - make_interval(secs => $2) THEN 1
+			ELSE "users_login_throttles"."failures" + 1
+		END,
+		"last_failed_at" = now()
+	RETURNING "failures";`
+
+	var failures int
+	if err := r.db.Get(&failures, query, key, window.Seconds()); err != nil {
+		return 0, fmt.Errorf("insert users_login_throttles failed: %v", err)
+	}
+	return failures, nil
+}
+
+func (r *usersRepository) BlockLogin(key string, duration time.Duration) error {
+	query := `
+	UPDATE "users_login_throttles" SET
+		"blocked_until" = now() + make_interval(secs => $2)
+	WHERE "key" = $1;`
+
+	if _, err := r.db.Exec(query, key, duration.Seconds()); err != nil {
+		return fmt.Errorf("update users_login_throttles failed: %v", err)
+	}
+	return nil
+}
+
+func (r *usersRepository) DeleteLoginFailures(key string) error {
+	if _, err := r.db.Exec(`DELETE FROM "users_login_throttles" WHERE "key" = $1;`, key); err != nil {
+		return fmt.Errorf("delete users_login_throttles failed: %v", err)
+	}
+	return nil
+}
+
+// UnlockUser clears the sign in and mfa failures of the user, the ip is left blocked
+func (r *usersRepository) UnlockUser(userId, actorId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	var email string
+	if err := tx.GetContext(ctx, &email, `SELECT "email" FROM "users" WHERE "id" = $1;`, userId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("user not found")
+	}
+
+	keys := []string{users.LoginAccountKey(email), users.LoginMfaKey(userId)}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM "users_login_throttles" WHERE "key" = ANY($1);`, keys); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("delete users_login_throttles failed: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO "users_audit_logs" ("user_id", "actor_id", "action") VALUES ($1, $2, 'account unlocked');`, userId, actorId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("insert users_audit_logs failed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
@@ -2,11 +2,17 @@ package usersUsecases
 
 import (
 	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/LGROW101/lgrow-shop/config"
 	"github.com/LGROW101/lgrow-shop/modules/users"
 	"github.com/LGROW101/lgrow-shop/modules/users/usersRepositories"
 	"github.com/LGROW101/lgrow-shop/pkg/auth"
+	"github.com/LGROW101/lgrow-shop/pkg/mailer"
+	"github.com/LGROW101/lgrow-shop/pkg/totp"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -17,17 +23,41 @@ type IUsersUsecase interface {
 	DeleteOauth(oauthId string) error
 	InsertAdmin(req *users.UserRegisterReq) (*users.UserPassport, error)
 	GetUserProfile(userId string) (*users.User, error)
+	FindAddress(userId string) ([]*users.Address, error)
+	FindOneAddress(userId, addressId string) (*users.Address, error)
+	InsertAddress(req *users.Address) (*users.Address, error)
+	UpdateAddress(req *users.Address) (*users.Address, error)
+	DeleteAddress(userId, addressId string) error
+	VerifyEmail(token string) (*users.UserPassport, error)
+	ForgotPassword(req *users.UserForgotPasswordReq) error
+	ResetPassword(req *users.UserResetPasswordReq) error
+	UpdateUser(req *users.UserUpdateReq) (*users.User, error)
+	ChangePassword(req *users.UserChangePasswordReq) error
+	FindSession(userId, accessToken string) ([]*users.Session, error)
+	DeleteSession(userId, sessionId string) error
+	DeleteOtherSessions(userId, accessToken string) (string, error)
+	VerifyMfa(req *users.MfaReq) (*users.UserPassport, error)
+	SetupMfa(userId string) (*users.MfaSetup, error)
+	ConfirmMfa(req *users.MfaReq) ([]string, error)
+	EnrollMfa(mfaToken string) (*users.MfaSetup, error)
+	ConfirmEnrollMfa(req *users.MfaReq) (*users.UserPassport, error)
+	DisableMfa(req *users.MfaReq) error
+	UnlockUser(userId, actorId string) error
 }
 
 type usersUsecase struct {
 	cfg             config.IConfig
 	usersRepository usersRepositories.IUsersRepository
+	mailer          mailer.IMailer
+	keys            *auth.KeySet
 }
 
-func UsersUsecase(cfg config.IConfig, usersRepository usersRepositories.IUsersRepository) IUsersUsecase {
+func UsersUsecase(cfg config.IConfig, usersRepository usersRepositories.IUsersRepository, mailer mailer.IMailer, keys *auth.KeySet) IUsersUsecase {
 	return &usersUsecase{
 		cfg:             cfg,
 		usersRepository: usersRepository,
+		mailer:          mailer,
+		keys:            keys,
 	}
 }
 
@@ -42,6 +72,71 @@ func (u *usersUsecase) InsertCustomer(req *users.UserRegisterReq) (*users.UserPa
 	if err != nil {
 		return nil, err
 	}
+
+	// The account is already created, guest orders are claimed once the email is verified
+	if err := u.sendVerifyEmail(result.User, req.ClaimGuestOrders); err != nil {
+		log.Printf("send verify email to %s failed: %v", result.User.Id, err)
+	}
+	return result, nil
+}
+
+func (u *usersUsecase) sendVerifyEmail(user *users.User, claimGuestOrders bool) error {
+	expiresAt := time.Now().Add(u.cfg.User().VerifyEmailExpires())
+
+	verificationId, err := u.usersRepository.InsertEmailVerification(user.Id, user.Email, claimGuestOrders, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	token := auth.NewVerifyEmailToken(u.cfg.Jwt(), u.keys, &users.UserClaims{
+		Id:     user.Id,
+		RoleId: user.RoleId,
+	}, verificationId, expiresAt).SignToken()
+
+	link := token
+	if u.cfg.User().VerifyEmailUrl() != "" {
+		link = fmt.Sprintf("%s?token=%s", u.cfg.User().VerifyEmailUrl(), url.QueryEscape(token))
+	}
+
+	return u.mailer.Send(&mailer.Message{
+		To:      user.Email,
+		Subject: fmt.Sprintf("Verify your email for %s", u.cfg.App().Name()),
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease verify your email address:\n%s\n\nThe link expires at %s.\n",
+			user.Username,
+			link,
+			expiresAt.Format("2006-01-02 15:04"),
+		),
+	})
+}
+
+func (u *usersUsecase) VerifyEmail(token string) (*users.UserPassport, error) {
+	claims, err := auth.ParseVerifyEmailToken(u.cfg.Jwt(), u.keys, token)
+	if err != nil {
+		return nil, err
+	}
+
+	claimGuestOrders, err := u.usersRepository.VerifyEmail(claims.ID, claims.Claims.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := u.usersRepository.GetProfile(claims.Claims.Id)
+	if err != nil {
+		return nil, err
+	}
+	result := &users.UserPassport{
+		User: profile,
+	}
+
+	// The email is verified already, a failed claim can be retried by support
+	if claimGuestOrders {
+		orderIds, err := u.usersRepository.ClaimGuestOrders(profile.Id, profile.Email)
+		if err != nil {
+			log.Printf("claim guest orders of %s failed: %v", profile.Id, err)
+		}
+		result.ClaimedOrders = orderIds
+	}
 	return result, nil
 }
 
@@ -59,43 +154,124 @@ func (u *usersUsecase) InsertAdmin(req *users.UserRegisterReq) (*users.UserPassp
 	return result, nil
 }
 
+// dummyPassword is compared when the email is unknown, so it takes as long as a wrong password
+var dummyPassword, _ = bcrypt.GenerateFromPassword([]byte("lgrow-shop-dummy-password"), 10)
+
+var (
+	errInvalidCredential = fmt.Errorf("email or password is invalid")
+	errLoginBlocked      = fmt.Errorf("too many failed attempts, try again later")
+)
+
+// loginFailed counts the failure on every key and blocks the ones over their limit
+func (u *usersUsecase) loginFailed(accountKey, ipKey string) {
+	cfg := u.cfg.User()
+	limits := map[string]int{
+		accountKey: cfg.LoginMaxFailures(),
+		ipKey:      cfg.LoginMaxFailuresPerIp(),
+	}
+	for key, max := range limits {
+		failures, err := u.usersRepository.InsertLoginFailure(key, cfg.LoginLockout())
+		if err != nil {
+			log.Printf("count failed sign in of %s failed: %v", key, err)
+			continue
+		}
+		if failures >= max {
+			log.Printf("security: %s locked after %d failed sign ins", key, failures)
+		}
+		if backoff := users.LoginBackoff(failures, cfg.LoginFreeFailures(), max, cfg.LoginLockout()); backoff > 0 {
+			if err := u.usersRepository.BlockLogin(key, backoff); err != nil {
+				log.Printf("block sign in of %s failed: %v", key, err)
+			}
+		}
+	}
+}
+
 func (u *usersUsecase) GetPassport(req *users.UserCredential) (*users.UserPassport, error) {
-	// Find user
-	user, err := u.usersRepository.FindOneUserByEmail(req.Email)
+	accountKey, ipKey := users.LoginAccountKey(req.Email), users.LoginIpKey(req.Ip)
+	blocked, err := u.usersRepository.IsLoginBlocked(accountKey, ipKey)
 	if err != nil {
 		return nil, err
 	}
+	if blocked {
+		return nil, errLoginBlocked
+	}
+
+	// Find user, an unknown email and a wrong password look the same from outside
+	user, err := u.usersRepository.FindOneUserByEmail(req.Email)
+	if err != nil {
+		bcrypt.CompareHashAndPassword(dummyPassword, []byte(req.Password))
+		u.loginFailed(accountKey, ipKey)
+		return nil, errInvalidCredential
+	}
 
 	// Compare password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		return nil, fmt.Errorf("password is invalid")
+		u.loginFailed(accountKey, ipKey)
+		return nil, errInvalidCredential
+	}
+	if err := u.usersRepository.DeleteLoginFailures(accountKey); err != nil {
+		return nil, err
+	}
+
+	if u.cfg.User().RequireVerifiedEmail() && !user.EmailVerified {
+		return nil, fmt.Errorf("email has not been verified")
+	}
+
+	profile := &users.User{
+		Id:            user.Id,
+		Email:         user.Email,
+		Username:      user.Username,
+		RoleId:        user.RoleId,
+		EmailVerified: user.EmailVerified,
 	}
 
+	// The password is right, a totp code is asked before the tokens
+	mfa, err := u.usersRepository.FindOneMfa(user.Id)
+	if err != nil && err.Error() != "mfa not found" {
+		return nil, err
+	}
+	mfaEnabled := mfa != nil && mfa.Confirmed
+	if mfaEnabled || (user.RoleId == 2 && u.cfg.User().MfaRequiredForAdmin()) {
+		return &users.UserPassport{
+			User:              profile,
+			MfaRequired:       true,
+			MfaEnrollRequired: !mfaEnabled,
+			MfaToken: auth.NewMfaChallengeToken(u.cfg.Jwt(), u.keys, &users.UserClaims{
+				Id:     user.Id,
+				RoleId: user.RoleId,
+			}, u.cfg.User().MfaChallengeExpires()).SignToken(),
+		}, nil
+	}
+	return u.issuePassport(profile, req.Ip, req.UserAgent)
+}
+
+func (u *usersUsecase) issuePassport(user *users.User, ip, userAgent string) (*users.UserPassport, error) {
 	// Sign token
-	accessToken, err := auth.NewLgrowAuth(auth.Access, u.cfg.Jwt(), &users.UserClaims{
+	accessToken, err := auth.NewLgrowAuth(auth.Access, u.cfg.Jwt(), u.keys, &users.UserClaims{
 		Id:     user.Id,
 		RoleId: user.RoleId,
 	})
-	refreshToken, err := auth.NewLgrowAuth(auth.Refresh, u.cfg.Jwt(), &users.UserClaims{
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := auth.NewLgrowAuth(auth.Refresh, u.cfg.Jwt(), u.keys, &users.UserClaims{
 		Id:     user.Id,
 		RoleId: user.RoleId,
 	})
+	if err != nil {
+		return nil, err
+	}
 
 	// Set passport
 	passport := &users.UserPassport{
-		User: &users.User{
-			Id:       user.Id,
-			Email:    user.Email,
-			Username: user.Username,
-			RoleId:   user.RoleId,
-		},
+		User: user,
 		Token: &users.UserToken{
 			AccessToken:  accessToken.SignToken(),
 			RefreshToken: refreshToken.SignToken(),
 		},
 	}
 
-	if err := u.usersRepository.InsertOauth(passport); err != nil {
+	if err := u.usersRepository.InsertOauth(passport, ip, userAgent); err != nil {
 		return nil, err
 	}
 	return passport, nil
@@ -103,7 +279,7 @@ func (u *usersUsecase) GetPassport(req *users.UserCredential) (*users.UserPasspo
 
 func (u *usersUsecase) RefreshPassport(req *users.UserRefreshCredential) (*users.UserPassport, error) {
 	// Parse token
-	claims, err := auth.ParseToken(u.cfg.Jwt(), req.RefreshToken)
+	claims, err := auth.ParseToken(u.cfg.Jwt(), u.keys, req.RefreshToken)
 	if err != nil {
 		return nil, err
 	}
@@ -111,6 +287,9 @@ func (u *usersUsecase) RefreshPassport(req *users.UserRefreshCredential) (*users
 	// Check oauth
 	oauth, err := u.usersRepository.FindOneOauth(req.RefreshToken)
 	if err != nil {
+		if err := u.revokeReusedToken(req); err != nil {
+			return nil, err
+		}
 		return nil, err
 	}
 
@@ -128,6 +307,7 @@ func (u *usersUsecase) RefreshPassport(req *users.UserRefreshCredential) (*users
 	accessToken, err := auth.NewLgrowAuth(
 		auth.Access,
 		u.cfg.Jwt(),
+		u.keys,
 		newClaims,
 	)
 	if err != nil {
@@ -135,6 +315,7 @@ func (u *usersUsecase) RefreshPassport(req *users.UserRefreshCredential) (*users
 	}
 	refreshToken := auth.RepeatToken(
 		u.cfg.Jwt(),
+		u.keys,
 		newClaims,
 		claims.ExpiresAt.Unix(),
 	)
@@ -147,12 +328,34 @@ func (u *usersUsecase) RefreshPassport(req *users.UserRefreshCredential) (*users
 			RefreshToken: refreshToken,
 		},
 	}
-	if err := u.usersRepository.UpdateOauth(passport.Token); err != nil {
+	if err := u.usersRepository.UpdateOauth(passport.Token, req.RefreshToken); err != nil {
+		// Another request rotated the same token first
+		if err.Error() == "oauth not found" {
+			if err := u.revokeReusedToken(req); err != nil {
+				return nil, err
+			}
+		}
 		return nil, err
 	}
 	return passport, nil
 }
 
+// revokeReusedToken signs out the whole family when the refresh token was rotated already,
+// a rotated token coming back has leaked
+func (u *usersUsecase) revokeReusedToken(req *users.UserRefreshCredential) error {
+	family, err := u.usersRepository.FindRotatedOauth(req.RefreshToken)
+	if err != nil {
+		return nil
+	}
+
+	detail := fmt.Sprintf("session %s revoked, token presented from %s (%s)", family.Id, req.Ip, req.UserAgent)
+	if err := u.usersRepository.RevokeOauthFamily(family, detail); err != nil {
+		return err
+	}
+	log.Printf("security: refresh token reused by %s, %s", family.UserId, detail)
+	return fmt.Errorf("refresh token has been reused")
+}
+
 func (u *usersUsecase) DeleteOauth(oauthId string) error {
 	if err := u.usersRepository.DeleteOauth(oauthId); err != nil {
 		return err
@@ -167,3 +370,345 @@ func (u *usersUsecase) GetUserProfile(userId string) (*users.User, error) {
 	}
 	return profile, nil
 }
+
+func (u *usersUsecase) FindAddress(userId string) ([]*users.Address, error) {
+	addresses, err := u.usersRepository.FindAddress(userId)
+	if err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+func (u *usersUsecase) FindOneAddress(userId, addressId string) (*users.Address, error) {
+	address, err := u.usersRepository.FindOneAddress(addressId)
+	if err != nil {
+		return nil, err
+	}
+	if address.UserId != userId {
+		return nil, fmt.Errorf("address not found")
+	}
+	return address, nil
+}
+
+func (u *usersUsecase) InsertAddress(req *users.Address) (*users.Address, error) {
+	if err := u.usersRepository.InsertAddress(req); err != nil {
+		return nil, err
+	}
+
+	address, err := u.usersRepository.FindOneAddress(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return address, nil
+}
+
+func (u *usersUsecase) UpdateAddress(req *users.Address) (*users.Address, error) {
+	if err := u.usersRepository.UpdateAddress(req); err != nil {
+		return nil, err
+	}
+
+	address, err := u.usersRepository.FindOneAddress(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return address, nil
+}
+
+func (u *usersUsecase) DeleteAddress(userId, addressId string) error {
+	if err := u.usersRepository.DeleteAddress(userId, addressId); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ForgotPassword does not tell whether the email has an account, so failures after
+// the user is found are only logged
+func (u *usersUsecase) ForgotPassword(req *users.UserForgotPasswordReq) error {
+	user, err := u.usersRepository.FindOneUserByEmail(req.Email)
+	if err != nil {
+		return nil
+	}
+
+	token, err := users.NewResetToken()
+	if err != nil {
+		log.Printf("create password reset of %s failed: %v", user.Id, err)
+		return nil
+	}
+	expiresAt := time.Now().Add(u.cfg.User().PasswordResetExpires())
+	if err := u.usersRepository.InsertPasswordReset(user.Id, auth.HashToken(token), expiresAt); err != nil {
+		log.Printf("create password reset of %s failed: %v", user.Id, err)
+		return nil
+	}
+
+	link := token
+	if u.cfg.User().PasswordResetUrl() != "" {
+		link = fmt.Sprintf("%s?token=%s", u.cfg.User().PasswordResetUrl(), url.QueryEscape(token))
+	}
+
+	if err := u.mailer.Send(&mailer.Message{
+		To:      user.Email,
+		Subject: fmt.Sprintf("Reset your password for %s", u.cfg.App().Name()),
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nUse this link to set a new password:\n%s\n\nThe link expires at %s. If you did not ask for it, you can ignore this email.\n",
+			user.Username,
+			link,
+			expiresAt.Format("2006-01-02 15:04"),
+		),
+	}); err != nil {
+		log.Printf("send password reset email to %s failed: %v", user.Id, err)
+	}
+	return nil
+}
+
+func (u *usersUsecase) ResetPassword(req *users.UserResetPasswordReq) error {
+	// Hashing a password
+	hashed := &users.UserRegisterReq{Password: req.Password}
+	if err := hashed.BcryptHashing(); err != nil {
+		return err
+	}
+
+	if err := u.usersRepository.ResetPassword(auth.HashToken(req.Token), hashed.Password); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (u *usersUsecase) UpdateUser(req *users.UserUpdateReq) (*users.User, error) {
+	emailChanged, err := u.usersRepository.UpdateUser(req)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := u.usersRepository.GetProfile(req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	// The email is already changed, verification can be sent again with another update
+	if emailChanged {
+		if err := u.sendVerifyEmail(profile, false); err != nil {
+			log.Printf("send verify email to %s failed: %v", profile.Id, err)
+		}
+	}
+	return profile, nil
+}
+
+func (u *usersUsecase) ChangePassword(req *users.UserChangePasswordReq) error {
+	password, err := u.usersRepository.FindOneUserPassword(req.Id)
+	if err != nil {
+		return err
+	}
+
+	// Compare password
+	if err := bcrypt.CompareHashAndPassword([]byte(password), []byte(req.CurrentPassword)); err != nil {
+		return fmt.Errorf("password is invalid")
+	}
+
+	// Hashing a password
+	hashed := &users.UserRegisterReq{Password: req.NewPassword}
+	if err := hashed.BcryptHashing(); err != nil {
+		return err
+	}
+
+	if err := u.usersRepository.UpdatePassword(req.Id, hashed.Password, req.AccessToken); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (u *usersUsecase) FindSession(userId, accessToken string) ([]*users.Session, error) {
+	sessions, err := u.usersRepository.FindSession(userId, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (u *usersUsecase) DeleteSession(userId, sessionId string) error {
+	if err := u.usersRepository.DeleteSession(userId, sessionId); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (u *usersUsecase) DeleteOtherSessions(userId, accessToken string) (string, error) {
+	rows, err := u.usersRepository.DeleteOtherSessions(userId, accessToken)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d sessions signed out", rows), nil
+}
+
+// checkMfaCode takes a totp code once, or an unused recovery code, wrong codes are
+// throttled per user and ip like passwords
+func (u *usersUsecase) checkMfaCode(mfa *users.Mfa, code, ip string) error {
+	mfaKey, ipKey := users.LoginMfaKey(mfa.UserId), users.LoginIpKey(ip)
+	blocked, err := u.usersRepository.IsLoginBlocked(mfaKey, ipKey)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return errLoginBlocked
+	}
+
+	if step, ok := totp.Validate(mfa.Secret, code, time.Now()); ok {
+		err = u.usersRepository.UseMfaStep(mfa.UserId, step)
+	} else {
+		err = u.usersRepository.UseRecoveryCode(mfa.UserId, auth.HashToken(strings.ToLower(strings.TrimSpace(code))))
+	}
+	if err != nil {
+		if err.Error() == "code is invalid" {
+			u.loginFailed(mfaKey, ipKey)
+		}
+		return err
+	}
+	return u.usersRepository.DeleteLoginFailures(mfaKey)
+}
+
+func (u *usersUsecase) VerifyMfa(req *users.MfaReq) (*users.UserPassport, error) {
+	claims, err := auth.ParseMfaChallengeToken(u.cfg.Jwt(), u.keys, req.MfaToken)
+	if err != nil {
+		return nil, err
+	}
+
+	mfa, err := u.usersRepository.FindOneMfa(claims.Claims.Id)
+	if err != nil {
+		return nil, err
+	}
+	if !mfa.Confirmed {
+		return nil, fmt.Errorf("mfa is not enabled")
+	}
+	if err := u.checkMfaCode(mfa, req.Code, req.Ip); err != nil {
+		return nil, err
+	}
+
+	profile, err := u.usersRepository.GetProfile(claims.Claims.Id)
+	if err != nil {
+		return nil, err
+	}
+	return u.issuePassport(profile, req.Ip, req.UserAgent)
+}
+
+// SetupMfa gives a new secret, mfa is enabled once a code from it is confirmed
+func (u *usersUsecase) SetupMfa(userId string) (*users.MfaSetup, error) {
+	profile, err := u.usersRepository.GetProfile(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := u.usersRepository.UpsertMfaSecret(userId, secret); err != nil {
+		return nil, err
+	}
+
+	uri := totp.ProvisioningUri(u.cfg.App().Name(), profile.Email, secret)
+	qrCode, err := totp.QrCode(uri, 256)
+	if err != nil {
+		return nil, err
+	}
+	return &users.MfaSetup{
+		Secret:          secret,
+		ProvisioningUri: uri,
+		QrCode:          qrCode,
+	}, nil
+}
+
+// ConfirmMfa enables mfa and returns the recovery codes, they are not shown again
+func (u *usersUsecase) ConfirmMfa(req *users.MfaReq) ([]string, error) {
+	mfa, err := u.usersRepository.FindOneMfa(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if mfa.Confirmed {
+		return nil, fmt.Errorf("mfa has been enabled")
+	}
+
+	step, ok := totp.Validate(mfa.Secret, req.Code, time.Now())
+	if !ok {
+		return nil, fmt.Errorf("code is invalid")
+	}
+
+	codes, err := users.NewRecoveryCodes(10)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hashes = append(hashes, auth.HashToken(code))
+	}
+
+	if err := u.usersRepository.ConfirmMfa(req.UserId, step, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// EnrollMfa is SetupMfa for an admin who has to enable mfa before signing in
+func (u *usersUsecase) EnrollMfa(mfaToken string) (*users.MfaSetup, error) {
+	claims, err := auth.ParseMfaChallengeToken(u.cfg.Jwt(), u.keys, mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	return u.SetupMfa(claims.Claims.Id)
+}
+
+func (u *usersUsecase) ConfirmEnrollMfa(req *users.MfaReq) (*users.UserPassport, error) {
+	claims, err := auth.ParseMfaChallengeToken(u.cfg.Jwt(), u.keys, req.MfaToken)
+	if err != nil {
+		return nil, err
+	}
+	req.UserId = claims.Claims.Id
+
+	codes, err := u.ConfirmMfa(req)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := u.usersRepository.GetProfile(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	passport, err := u.issuePassport(profile, req.Ip, req.UserAgent)
+	if err != nil {
+		return nil, err
+	}
+	passport.RecoveryCodes = codes
+	return passport, nil
+}
+
+func (u *usersUsecase) DisableMfa(req *users.MfaReq) error {
+	profile, err := u.usersRepository.GetProfile(req.UserId)
+	if err != nil {
+		return err
+	}
+	if profile.RoleId == 2 && u.cfg.User().MfaRequiredForAdmin() {
+		return fmt.Errorf("mfa is required for admins")
+	}
+
+	mfa, err := u.usersRepository.FindOneMfa(req.UserId)
+	if err != nil {
+		return err
+	}
+	if !mfa.Confirmed {
+		return fmt.Errorf("mfa is not enabled")
+	}
+	if err := u.checkMfaCode(mfa, req.Code, req.Ip); err != nil {
+		return err
+	}
+
+	if err := u.usersRepository.DeleteMfa(req.UserId); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (u *usersUsecase) UnlockUser(userId, actorId string) error {
+	if err := u.usersRepository.UnlockUser(userId, actorId); err != nil {
+		return err
+	}
+	log.Printf("security: %s unlocked by %s", userId, actorId)
+	return nil
+}
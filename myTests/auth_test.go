@@ -0,0 +1,126 @@
+package myTests
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LGROW101/lgrow-shop/modules/users"
+	"github.com/LGROW101/lgrow-shop/pkg/auth"
+)
+
+type testJwtConfig struct {
+	signingAlg string
+}
+
+func (c *testJwtConfig) SecretKey() []byte                { return []byte("test-secret-key") }
+func (c *testJwtConfig) AdminKey() []byte                 { return []byte("test-admin-key") }
+func (c *testJwtConfig) ApiKey() []byte                   { return []byte("test-api-key") }
+func (c *testJwtConfig) AccessExpiresAt() int             { return 60 }
+func (c *testJwtConfig) RefreshExpiresAt() int            { return 120 }
+func (c *testJwtConfig) SetJwtAccessExpires(t int)        {}
+func (c *testJwtConfig) SetJwtRefreshExpires(t int)       {}
+func (c *testJwtConfig) SigningAlg() string               { return c.signingAlg }
+func (c *testJwtConfig) KeyRotateInterval() time.Duration { return 24 * time.Hour }
+func (c *testJwtConfig) KeyCheckInterval() time.Duration  { return time.Hour }
+
+type testSignToken struct {
+	alg    string
+	header string // "alg" in the token header
+}
+
+func TestSignToken(t *testing.T) {
+	tests := []testSignToken{
+		{alg: "HS256", header: `"alg":"HS256"`},
+		{alg: "RS256", header: `"alg":"RS256"`},
+		{alg: "EdDSA", header: `"alg":"EdDSA"`},
+	}
+
+	for _, test := range tests {
+		cfg := &testJwtConfig{signingAlg: test.alg}
+		keys := auth.NewKeySet()
+		if test.alg != "HS256" {
+			key, err := auth.NewKey(test.alg, time.Now().Add(-time.Minute))
+			if err != nil {
+				t.Fatalf("expect: %v, got: %v", nil, err)
+			}
+			keys.Set([]*auth.Key{key})
+		}
+
+		token, err := auth.NewLgrowAuth(auth.Access, cfg, keys, &users.UserClaims{Id: "U000001", RoleId: 1})
+		if err != nil {
+			t.Fatalf("expect: %v, got: %v", nil, err)
+		}
+		signed := token.SignToken()
+		if header := decodeSegment(t, signed, 0); !strings.Contains(header, test.header) {
+			t.Errorf("expect: %v, got: %v", test.header, header)
+		}
+
+		claims, err := auth.ParseToken(cfg, keys, signed)
+		if err != nil {
+			t.Errorf("expect: %v, got: %v", nil, err)
+			continue
+		}
+		if claims.Claims.Id != "U000001" {
+			t.Errorf("expect: %v, got: %v", "U000001", claims.Claims.Id)
+		}
+	}
+}
+
+func TestSignTokenRotation(t *testing.T) {
+	cfg := &testJwtConfig{signingAlg: "RS256"}
+	keys := auth.NewKeySet()
+
+	oldKey, _ := auth.NewKey("RS256", time.Now().Add(-time.Hour))
+	keys.Set([]*auth.Key{oldKey})
+	oldToken := auth.NewMfaChallengeToken(cfg, keys, &users.UserClaims{Id: "U000001"}, time.Minute).SignToken()
+
+	// The next key is published but does not sign yet
+	expiresAt := time.Now().Add(time.Hour)
+	oldKey.ExpiresAt = &expiresAt
+	nextKey, _ := auth.NewKey("EdDSA", time.Now().Add(time.Hour))
+	keys.Set([]*auth.Key{oldKey, nextKey})
+	if header := decodeSegment(t, auth.NewMfaChallengeToken(cfg, keys, &users.UserClaims{Id: "U000001"}, time.Minute).SignToken(), 0); !strings.Contains(header, oldKey.Kid) {
+		t.Errorf("expect: %v, got: %v", oldKey.Kid, header)
+	}
+	if jwks := keys.Jwks(); len(jwks.Keys) != 2 {
+		t.Errorf("expect: %v, got: %v", 2, len(jwks.Keys))
+	}
+
+	// Tokens of the old key stay valid until the key expires
+	if _, err := auth.ParseMfaChallengeToken(cfg, keys, oldToken); err != nil {
+		t.Errorf("expect: %v, got: %v", nil, err)
+	}
+	keys.Set([]*auth.Key{nextKey})
+	if _, err := auth.ParseMfaChallengeToken(cfg, keys, oldToken); err == nil {
+		t.Errorf("expect: %v, got: %v", "signing key is unknown", err)
+	}
+}
+
+func TestEncodePrivateKey(t *testing.T) {
+	key, _ := auth.NewKey("EdDSA", time.Now())
+
+	encoded, err := auth.EncodePrivateKey([]byte("test-secret-key"), key.Private)
+	if err != nil {
+		t.Fatalf("expect: %v, got: %v", nil, err)
+	}
+	if _, err := auth.DecodePrivateKey([]byte("test-secret-key"), encoded); err != nil {
+		t.Errorf("expect: %v, got: %v", nil, err)
+	}
+	if _, err := auth.DecodePrivateKey([]byte("another-secret-key"), encoded); err == nil {
+		t.Errorf("expect: %v, got: %v", "decrypt private key failed", err)
+	}
+}
+
+func decodeSegment(t *testing.T, token string, i int) string {
+	segments := strings.Split(token, ".")
+	if len(segments) != 3 {
+		t.Fatalf("expect: %v, got: %v", 3, len(segments))
+	}
+	b, err := base64.RawURLEncoding.DecodeString(segments[i])
+	if err != nil {
+		t.Fatalf("expect: %v, got: %v", nil, err)
+	}
+	return string(b)
+}
@@ -0,0 +1,38 @@
+package myTests
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/LGROW101/lgrow-shop/modules/credits"
+)
+
+type testNormalizeCode struct {
+	code   string
+	expect string
+}
+
+func TestNormalizeCode(t *testing.T) {
+	tests := []testNormalizeCode{
+		{code: "ABCD-EFGH-JKLM-NPQR", expect: "ABCD-EFGH-JKLM-NPQR"},
+		{code: "abcdefghjklmnpqr", expect: "ABCD-EFGH-JKLM-NPQR"},
+		{code: " abcd efgh-jklm npqr ", expect: "ABCD-EFGH-JKLM-NPQR"},
+		{code: "abc", expect: "ABC"},
+	}
+
+	for _, test := range tests {
+		if got := credits.NormalizeCode(test.code); got != test.expect {
+			t.Errorf("expect: %v, got: %v", test.expect, got)
+		}
+	}
+}
+
+func TestNewCode(t *testing.T) {
+	code, err := credits.NewCode()
+	if err != nil {
+		t.Fatalf("new code failed: %v", err)
+	}
+	if !regexp.MustCompile(`^[A-HJ-NP-Z2-9]{4}(-[A-HJ-NP-Z2-9]{4}){3}$`).MatchString(code) {
+		t.Errorf("expect: %v, got: %v", "XXXX-XXXX-XXXX-XXXX", code)
+	}
+}
@@ -0,0 +1,59 @@
+package myTests
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/LGROW101/lgrow-shop/modules/orders"
+	"github.com/LGROW101/lgrow-shop/pkg/invoicepdf"
+)
+
+type testFiscalYear struct {
+	date   string
+	start  time.Month
+	expect int
+}
+
+func TestFiscalYear(t *testing.T) {
+	tests := []testFiscalYear{
+		{date: "2026-01-01", start: time.January, expect: 2026},
+		{date: "2026-12-31", start: time.January, expect: 2026},
+		{date: "2026-09-30", start: time.October, expect: 2026},
+		{date: "2026-10-01", start: time.October, expect: 2027},
+	}
+
+	for _, test := range tests {
+		date, _ := time.Parse("2006-01-02", test.date)
+		if got := orders.FiscalYear(date, test.start); got != test.expect {
+			t.Errorf("expect: %v, got: %v", test.expect, got)
+		}
+	}
+
+	if got := orders.InvoiceNo(2026, 12); got != "INV2026-000012" {
+		t.Errorf("expect: %v, got: %v", "INV2026-000012", got)
+	}
+}
+
+func TestInvoicePDF(t *testing.T) {
+	file, err := invoicepdf.Render(&invoicepdf.Invoice{
+		No:       "INV2026-000001",
+		IssuedAt: "2026-01-01",
+		OrderId:  "O000001",
+		Customer: "John Doe",
+		Address:  "1 Road Bangkok 10110",
+		Lines: []*invoicepdf.Line{
+			{Title: "Coffee", Qty: 2, Price: 100},
+		},
+		ShippingFee: 50,
+		VatRate:     7,
+		Store:       &invoicepdf.Store{Name: "Lgrow Shop"},
+	}, "")
+	if err != nil {
+		t.Errorf("expect: %v, got: %v", nil, err)
+		return
+	}
+	if !bytes.HasPrefix(file, []byte("%PDF")) {
+		t.Errorf("expect: %v, got: %v", "%PDF", string(file[:4]))
+	}
+}
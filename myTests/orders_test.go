@@ -0,0 +1,37 @@
+package myTests
+
+import (
+	"testing"
+
+	"github.com/LGROW101/lgrow-shop/modules/orders"
+)
+
+type testOrderTransition struct {
+	current string
+	next    string
+	isAdmin bool
+	expect  bool
+}
+
+func TestOrderTransition(t *testing.T) {
+	tests := []testOrderTransition{
+		{current: "waiting", next: "canceled", isAdmin: false, expect: true},
+		{current: "waiting", next: "paid", isAdmin: false, expect: false},
+		{current: "paid", next: "canceled", isAdmin: false, expect: false},
+		{current: "paid", next: "shipping", isAdmin: false, expect: false},
+		{current: "paid", next: "completed", isAdmin: false, expect: false},
+		{current: "shipping", next: "canceled", isAdmin: false, expect: false},
+		{current: "waiting", next: "shipping", isAdmin: true, expect: false},
+		{current: "waiting", next: "completed", isAdmin: true, expect: false},
+		{current: "paid", next: "shipping", isAdmin: true, expect: true},
+		{current: "shipping", next: "completed", isAdmin: true, expect: true},
+		{current: "completed", next: "shipping", isAdmin: true, expect: false},
+		{current: "canceled", next: "waiting", isAdmin: true, expect: false},
+	}
+
+	for _, test := range tests {
+		if got := orders.IsTransitionValid(test.current, test.next, test.isAdmin); got != test.expect {
+			t.Errorf("%s -> %s (admin %v) expect: %v, got: %v", test.current, test.next, test.isAdmin, test.expect, got)
+		}
+	}
+}
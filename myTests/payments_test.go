@@ -0,0 +1,74 @@
+package myTests
+
+import (
+	"testing"
+
+	"github.com/LGROW101/lgrow-shop/modules/payments"
+	"github.com/LGROW101/lgrow-shop/modules/payments/paymentsProviders"
+)
+
+type testMockConfirm struct {
+	token  string
+	expect string
+}
+
+func TestMockProviderConfirm(t *testing.T) {
+	tests := []testMockConfirm{
+		{
+			token:  "tok_visa",
+			expect: "succeeded",
+		},
+		{
+			token:  paymentsProviders.MockDeclineToken,
+			expect: "failed",
+		},
+	}
+
+	provider := paymentsProviders.MockProvider("secret")
+	for _, test := range tests {
+		intent, err := provider.CreateIntent(&payments.Payment{Amount: 100})
+		if err != nil {
+			t.Errorf("expect: %v, got: %v", nil, err)
+			continue
+		}
+
+		result, err := provider.Confirm(intent.Payment.ProviderRef, &payments.ConfirmReq{Token: test.token})
+		if err != nil {
+			t.Errorf("expect: %v, got: %v", nil, err)
+			continue
+		}
+		if result.Status != test.expect {
+			t.Errorf("expect: %v, got: %v", test.expect, result.Status)
+		}
+	}
+}
+
+type testMockWebhook struct {
+	signature string
+	isErr     bool
+}
+
+func TestMockProviderVerifyWebhook(t *testing.T) {
+	body := []byte(`{"provider_ref":"mock_1","status":"succeeded"}`)
+
+	tests := []testMockWebhook{
+		{
+			signature: paymentsProviders.MockSignature("secret", body),
+			isErr:     false,
+		},
+		{
+			signature: paymentsProviders.MockSignature("wrong", body),
+			isErr:     true,
+		},
+	}
+
+	provider := paymentsProviders.MockProvider("secret")
+	for _, test := range tests {
+		_, err := provider.VerifyWebhook(map[string]string{
+			paymentsProviders.MockSignatureHeader: test.signature,
+		}, body)
+		if (err != nil) != test.isErr {
+			t.Errorf("expect: %v, got: %v", test.isErr, err)
+		}
+	}
+}
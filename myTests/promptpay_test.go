@@ -0,0 +1,54 @@
+package myTests
+
+import (
+	"testing"
+
+	"github.com/LGROW101/lgrow-shop/pkg/promptpay"
+)
+
+type testPromptPayPayload struct {
+	id     string
+	amount float64
+	ref    string
+	expect string
+}
+
+func TestPromptPayPayload(t *testing.T) {
+	tests := []testPromptPayPayload{
+		{
+			id:     "000-000-0000",
+			expect: "00020101021129370016A000000677010111011300660000000005802TH530376463048956",
+		},
+		{
+			id:     "000-000-0000",
+			amount: 4.22,
+			expect: "00020101021229370016A000000677010111011300660000000005802TH530376454044.226304E469",
+		},
+		{
+			id:     "081-234-5678",
+			amount: 150,
+			ref:    "ORD0001",
+			expect: "00020101021229370016A000000677010111011300668123456785802TH53037645406150.0062110507ORD000163040867",
+		},
+		{
+			id:     "1111111111111",
+			expect: "00020101021129370016A000000677010111021311111111111115802TH530376463047B5A",
+		},
+		{
+			id:     "0-1055-61234-56-7",
+			amount: 1234.5,
+			expect: "00020101021229370016A000000677010111021301055612345675802TH530376454071234.506304B207",
+		},
+	}
+
+	for _, test := range tests {
+		payload, err := promptpay.Payload(test.id, test.amount, test.ref)
+		if err != nil {
+			t.Errorf("expect: %v, got: %v", nil, err)
+			continue
+		}
+		if payload != test.expect {
+			t.Errorf("expect: %v, got: %v", test.expect, payload)
+		}
+	}
+}
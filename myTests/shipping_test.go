@@ -0,0 +1,49 @@
+package myTests
+
+import (
+	"testing"
+
+	"github.com/LGROW101/lgrow-shop/modules/shipping"
+)
+
+type testCalculateFee struct {
+	method   *shipping.Method
+	subtotal float64
+	weight   float64
+	expect   float64
+}
+
+func TestCalculateFee(t *testing.T) {
+	tests := []testCalculateFee{
+		{
+			method:   &shipping.Method{Type: "flat", Rate: 50},
+			subtotal: 1000,
+			weight:   3,
+			expect:   50,
+		},
+		{
+			method:   &shipping.Method{Type: "weight", Rate: 30, RatePerKg: 20},
+			subtotal: 1000,
+			weight:   2.2,
+			expect:   90,
+		},
+		{
+			method:   &shipping.Method{Type: "free_over", Rate: 60, FreeOver: 1000},
+			subtotal: 999,
+			weight:   1,
+			expect:   60,
+		},
+		{
+			method:   &shipping.Method{Type: "free_over", Rate: 60, FreeOver: 1000},
+			subtotal: 1000,
+			weight:   1,
+			expect:   0,
+		},
+	}
+
+	for _, test := range tests {
+		if fee := test.method.CalculateFee(test.subtotal, test.weight); fee != test.expect {
+			t.Errorf("expect: %v, got: %v", test.expect, fee)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package myTests
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/LGROW101/lgrow-shop/pkg/promptpay"
+	"github.com/LGROW101/lgrow-shop/pkg/slipqr"
+)
+
+type testSlipQR struct {
+	payload string
+	expect  *slipqr.Slip
+}
+
+func TestSlipQRParse(t *testing.T) {
+	tests := []testSlipQR{
+		{
+			payload: "00410006000001010300402200142420",
+			expect:  nil,
+		},
+		{
+			payload: "00-1abcd",
+			expect:  nil,
+		},
+		{
+			payload: "00+1abcd",
+			expect:  nil,
+		},
+		{
+			payload: "0041000600000101030040220014242082547BPM049885102TH91049C30",
+			expect: &slipqr.Slip{
+				BankCode: "004",
+				Bank:     "KBANK",
+				TransRef: "014242082547BPM04988",
+				Country:  "TH",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		slip, err := slipqr.Parse(test.payload)
+		if test.expect == nil {
+			if err == nil {
+				t.Errorf("expect: %v, got: %v", "error", slip)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("expect: %v, got: %v", nil, err)
+			continue
+		}
+		if *slip != *test.expect {
+			t.Errorf("expect: %v, got: %v", test.expect, slip)
+		}
+	}
+}
+
+func TestSlipQRDecode(t *testing.T) {
+	payload := "0041000600000101030140220014242082547BPM049885102TH91049C30"
+
+	png, err := promptpay.PNG(payload, 256)
+	if err != nil {
+		t.Fatalf("expect: %v, got: %v", nil, err)
+	}
+
+	text, err := slipqr.Decode(bytes.NewReader(png))
+	if err != nil {
+		t.Fatalf("expect: %v, got: %v", nil, err)
+	}
+	if text != payload {
+		t.Errorf("expect: %v, got: %v", payload, text)
+	}
+}
@@ -0,0 +1,56 @@
+package myTests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/LGROW101/lgrow-shop/pkg/totp"
+)
+
+type testTotpCode struct {
+	unix   int64
+	expect string
+}
+
+// RFC 6238 appendix B (SHA1), the last 6 digits
+func TestTotpCode(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" // "12345678901234567890"
+
+	tests := []testTotpCode{
+		{unix: 59, expect: "287082"},
+		{unix: 1111111109, expect: "081804"},
+		{unix: 1111111111, expect: "050471"},
+		{unix: 1234567890, expect: "005924"},
+		{unix: 2000000000, expect: "279037"},
+		{unix: 20000000000, expect: "353130"},
+	}
+
+	for _, test := range tests {
+		code, err := totp.CodeAt(secret, time.Unix(test.unix, 0))
+		if err != nil {
+			t.Errorf("expect: %v, got: %v", nil, err)
+			continue
+		}
+		if code != test.expect {
+			t.Errorf("expect: %v, got: %v", test.expect, code)
+		}
+	}
+}
+
+func TestTotpValidate(t *testing.T) {
+	secret, err := totp.NewSecret()
+	if err != nil {
+		t.Fatalf("new secret failed: %v", err)
+	}
+	now := time.Now()
+
+	previous, _ := totp.CodeAt(secret, now.Add(-30*time.Second))
+	if step, ok := totp.Validate(secret, previous, now); !ok || step != totp.Step(now)-1 {
+		t.Errorf("expect: %v, got: %v", totp.Step(now)-1, step)
+	}
+
+	old, _ := totp.CodeAt(secret, now.Add(-5*time.Minute))
+	if _, ok := totp.Validate(secret, old, now); ok {
+		t.Errorf("expect: %v, got: %v", false, ok)
+	}
+}
@@ -0,0 +1,57 @@
+package myTests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/LGROW101/lgrow-shop/modules/users"
+)
+
+type testLoginBackoff struct {
+	failures int
+	expect   time.Duration
+}
+
+// 3 free failures, locked for 15 minutes at 10
+func TestLoginBackoff(t *testing.T) {
+	tests := []testLoginBackoff{
+		{failures: 0, expect: 0},
+		{failures: 3, expect: 0},
+		{failures: 4, expect: time.Second},
+		{failures: 5, expect: 2 * time.Second},
+		{failures: 9, expect: 32 * time.Second},
+		{failures: 10, expect: 15 * time.Minute},
+		{failures: 100, expect: 15 * time.Minute},
+	}
+
+	for _, test := range tests {
+		if got := users.LoginBackoff(test.failures, 3, 10, 15*time.Minute); got != test.expect {
+			t.Errorf("expect: %v, got: %v", test.expect, got)
+		}
+	}
+}
+
+func TestLoginBackoffCapped(t *testing.T) {
+	// The backoff never goes over the lockout before the max is reached
+	if got := users.LoginBackoff(40, 0, 50, time.Minute); got != time.Minute {
+		t.Errorf("expect: %v, got: %v", time.Minute, got)
+	}
+}
+
+type testLoginAccountKey struct {
+	email  string
+	expect string
+}
+
+func TestLoginAccountKey(t *testing.T) {
+	tests := []testLoginAccountKey{
+		{email: "user@lgrow.com", expect: "account:user@lgrow.com"},
+		{email: " User@LGROW.com ", expect: "account:user@lgrow.com"},
+	}
+
+	for _, test := range tests {
+		if got := users.LoginAccountKey(test.email); got != test.expect {
+			t.Errorf("expect: %v, got: %v", test.expect, got)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"math"
@@ -23,6 +26,7 @@ const (
 type lgrowAuth struct {
 	mapClaims *lgrowMapClaims
 	cfg       config.IJwtConfig
+	keys      *KeySet
 }
 
 type lgrowAdmin struct {
@@ -54,11 +58,32 @@ func jwtTimeDurationCal(t int) *jwt.NumericDate {
 	return jwt.NewNumericDate(time.Now().Add(time.Duration(int64(t) * int64(math.Pow10(9)))))
 }
 
+// newJti keeps rotated refresh tokens unique even when signed in the same second
+func newJti() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// HashToken is what the oauth table keeps instead of the token
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 func jwtTimeRepeatAdapter(t int64) *jwt.NumericDate {
 	return jwt.NewNumericDate(time.Unix(t, 0))
 }
 
 func (a *lgrowAuth) SignToken() string {
+	if a.cfg.SigningAlg() != "HS256" && a.keys != nil {
+		if key := a.keys.current(time.Now()); key != nil {
+			token := jwt.NewWithClaims(key.method(), a.mapClaims)
+			token.Header["kid"] = key.Kid
+			ss, _ := token.SignedString(key.Private)
+			return ss
+		}
+	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, a.mapClaims)
 	ss, _ := token.SignedString(a.cfg.SecretKey())
 	return ss
@@ -76,13 +101,32 @@ func (a *lgrowApiKey) SignToken() string {
 	return ss
 }
 
-func ParseToken(cfg config.IJwtConfig, tokenString string) (*lgrowMapClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &lgrowMapClaims{}, func(t *jwt.Token) (interface{}, error) {
+// tokenKey verifies tokens with a kid by the public key, tokens without one by the secret key
+// so the ones signed before switching to RS256 or EdDSA keep working until they expire
+func tokenKey(cfg config.IJwtConfig, keys *KeySet) jwt.Keyfunc {
+	return func(t *jwt.Token) (interface{}, error) {
+		if kid, ok := t.Header["kid"].(string); ok {
+			var key *Key
+			if keys != nil {
+				key = keys.find(kid, time.Now())
+			}
+			if key == nil {
+				return nil, fmt.Errorf("signing key is unknown")
+			}
+			if t.Method.Alg() != key.Alg {
+				return nil, fmt.Errorf("signing method is invalid")
+			}
+			return key.Public, nil
+		}
 		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("signing method is invalid")
 		}
 		return cfg.SecretKey(), nil
-	})
+	}
+}
+
+func ParseToken(cfg config.IJwtConfig, keys *KeySet, tokenString string) (*lgrowMapClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &lgrowMapClaims{}, tokenKey(cfg, keys))
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenMalformed) {
 			return nil, fmt.Errorf("token format is invalid")
@@ -148,12 +192,38 @@ func ParseApiKey(cfg config.IJwtConfig, tokenString string) (*lgrowMapClaims, er
 	}
 }
 
-func RepeatToken(cfg config.IJwtConfig, claims *users.UserClaims, exp int64) string {
+// ParseVerifyEmailToken only accepts tokens made by NewVerifyEmailToken
+func ParseVerifyEmailToken(cfg config.IJwtConfig, keys *KeySet, tokenString string) (*lgrowMapClaims, error) {
+	claims, err := ParseToken(cfg, keys, tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject != "verify-email-token" || claims.ID == "" || claims.Claims == nil {
+		return nil, fmt.Errorf("token is invalid")
+	}
+	return claims, nil
+}
+
+// ParseMfaChallengeToken only accepts tokens made by NewMfaChallengeToken
+func ParseMfaChallengeToken(cfg config.IJwtConfig, keys *KeySet, tokenString string) (*lgrowMapClaims, error) {
+	claims, err := ParseToken(cfg, keys, tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject != "mfa-challenge-token" || claims.Claims == nil {
+		return nil, fmt.Errorf("token is invalid")
+	}
+	return claims, nil
+}
+
+func RepeatToken(cfg config.IJwtConfig, keys *KeySet, claims *users.UserClaims, exp int64) string {
 	obj := &lgrowAuth{
-		cfg: cfg,
+		cfg:  cfg,
+		keys: keys,
 		mapClaims: &lgrowMapClaims{
 			Claims: claims,
 			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        newJti(),
 				Issuer:    "lgrowshop-api",
 				Subject:   "refresh-token",
 				Audience:  []string{"customer", "admin"},
@@ -166,12 +236,13 @@ func RepeatToken(cfg config.IJwtConfig, claims *users.UserClaims, exp int64) str
 	return obj.SignToken()
 }
 
-func NewLgrowAuth(tokenType TokenType, cfg config.IJwtConfig, claims *users.UserClaims) (ILgrowAuth, error) {
+// NewLgrowAuth signs access and refresh tokens with the newest key of keys, admin tokens and api keys with their secret
+func NewLgrowAuth(tokenType TokenType, cfg config.IJwtConfig, keys *KeySet, claims *users.UserClaims) (ILgrowAuth, error) {
 	switch tokenType {
 	case Access:
-		return newAccessToken(cfg, claims), nil
+		return newAccessToken(cfg, keys, claims), nil
 	case Refresh:
-		return newRefreshToken(cfg, claims), nil
+		return newRefreshToken(cfg, keys, claims), nil
 	case Admin:
 		return newAdminToken(cfg), nil
 	case ApiKey:
@@ -181,9 +252,50 @@ func NewLgrowAuth(tokenType TokenType, cfg config.IJwtConfig, claims *users.User
 	}
 }
 
-func newAccessToken(cfg config.IJwtConfig, claims *users.UserClaims) ILgrowAuth {
+// NewVerifyEmailToken signs a token for one email verification, jti is the id of the verification
+func NewVerifyEmailToken(cfg config.IJwtConfig, keys *KeySet, claims *users.UserClaims, jti string, exp time.Time) ILgrowAuth {
+	return &lgrowAuth{
+		cfg:  cfg,
+		keys: keys,
+		mapClaims: &lgrowMapClaims{
+			Claims: claims,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        jti,
+				Issuer:    "lgrowshop-api",
+				Subject:   "verify-email-token",
+				Audience:  []string{"customer", "admin"},
+				ExpiresAt: jwt.NewNumericDate(exp),
+				NotBefore: jwt.NewNumericDate(time.Now()),
+				IssuedAt:  jwt.NewNumericDate(time.Now()),
+			},
+		},
+	}
+}
+
+// NewMfaChallengeToken proves the password was right, it is exchanged with a totp code for a passport
+func NewMfaChallengeToken(cfg config.IJwtConfig, keys *KeySet, claims *users.UserClaims, exp time.Duration) ILgrowAuth {
+	return &lgrowAuth{
+		cfg:  cfg,
+		keys: keys,
+		mapClaims: &lgrowMapClaims{
+			Claims: claims,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        newJti(),
+				Issuer:    "lgrowshop-api",
+				Subject:   "mfa-challenge-token",
+				Audience:  []string{"customer", "admin"},
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(exp)),
+				NotBefore: jwt.NewNumericDate(time.Now()),
+				IssuedAt:  jwt.NewNumericDate(time.Now()),
+			},
+		},
+	}
+}
+
+func newAccessToken(cfg config.IJwtConfig, keys *KeySet, claims *users.UserClaims) ILgrowAuth {
 	return &lgrowAuth{
-		cfg: cfg,
+		cfg:  cfg,
+		keys: keys,
 		mapClaims: &lgrowMapClaims{
 			Claims: claims,
 			RegisteredClaims: jwt.RegisteredClaims{
@@ -198,12 +310,14 @@ func newAccessToken(cfg config.IJwtConfig, claims *users.UserClaims) ILgrowAuth
 	}
 }
 
-func newRefreshToken(cfg config.IJwtConfig, claims *users.UserClaims) ILgrowAuth {
+func newRefreshToken(cfg config.IJwtConfig, keys *KeySet, claims *users.UserClaims) ILgrowAuth {
 	return &lgrowAuth{
-		cfg: cfg,
+		cfg:  cfg,
+		keys: keys,
 		mapClaims: &lgrowMapClaims{
 			Claims: claims,
 			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        newJti(),
 				Issuer:    "lgrowshop-api",
 				Subject:   "refresh-token",
 				Audience:  []string{"customer", "admin"},
@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key is one asymmetric signing key, Private is nil when the key can only verify
+type Key struct {
+	Kid        string
+	Alg        string // RS256 or EdDSA
+	Private    crypto.Signer
+	Public     crypto.PublicKey
+	ActiveFrom time.Time  // published before, so every instance knows it before it signs
+	ExpiresAt  *time.Time // set when a newer key takes over, after the last token it signed expired
+}
+
+type Jwk struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type Jwks struct {
+	Keys []*Jwk `json:"keys"`
+}
+
+func NewKey(alg string, activeFrom time.Time) (*Key, error) {
+	key := &Key{
+		Kid:        newJti(),
+		Alg:        alg,
+		ActiveFrom: activeFrom,
+	}
+	switch alg {
+	case "RS256":
+		private, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, fmt.Errorf("generate rsa key failed: %v", err)
+		}
+		key.Private, key.Public = private, &private.PublicKey
+	case "EdDSA":
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("generate ed25519 key failed: %v", err)
+		}
+		key.Private, key.Public = private, public
+	default:
+		return nil, fmt.Errorf("signing alg is invalid")
+	}
+	return key, nil
+}
+
+func (k *Key) method() jwt.SigningMethod {
+	if k.Alg == "EdDSA" {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+func (k *Key) Jwk() *Jwk {
+	jwk := &Jwk{
+		Use: "sig",
+		Alg: k.Alg,
+		Kid: k.Kid,
+	}
+	switch public := k.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	}
+	return jwk
+}
+
+// privateKeyCipher derives the key the private keys are kept encrypted with from the secret key
+func privateKeyCipher(secret []byte) (cipher.AEAD, error) {
+	sum := sha256.Sum256(secret)
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncodePrivateKey returns the PKCS8 key encrypted with secret, base64
+func EncodePrivateKey(secret []byte, private crypto.Signer) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return "", fmt.Errorf("marshal private key failed: %v", err)
+	}
+	aead, err := privateKeyCipher(secret)
+	if err != nil {
+		return "", fmt.Errorf("encrypt private key failed: %v", err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("encrypt private key failed: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, der, nil)), nil
+}
+
+func DecodePrivateKey(secret []byte, encoded string) (crypto.Signer, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("private key format is invalid")
+	}
+	aead, err := privateKeyCipher(secret)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt private key failed: %v", err)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("private key format is invalid")
+	}
+	der, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt private key failed: %v", err)
+	}
+	private, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("parse private key failed: %v", err)
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("private key type is invalid")
+	}
+	return signer, nil
+}
+
+// EncodePublicKey returns the PKIX key as PEM
+func EncodePublicKey(public crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return "", fmt.Errorf("marshal public key failed: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+func DecodePublicKey(encoded string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(encoded))
+	if block == nil {
+		return nil, fmt.Errorf("public key format is invalid")
+	}
+	public, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse public key failed: %v", err)
+	}
+	return public, nil
+}
+
+// KeySet holds the keys tokens are signed and verified with, one is shared by the server
+type KeySet struct {
+	mu   sync.RWMutex
+	keys []*Key
+}
+
+func NewKeySet() *KeySet {
+	return &KeySet{}
+}
+
+// Set replaces the keys tokens are signed and verified with
+func (s *KeySet) Set(k []*Key) {
+	sorted := make([]*Key, len(k))
+	copy(sorted, k)
+	// Newest first
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ActiveFrom.After(sorted[j].ActiveFrom) })
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = sorted
+}
+
+// current is the newest active key that can sign
+func (s *KeySet) current(now time.Time) *Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, k := range s.keys {
+		if k.Private != nil && !k.ActiveFrom.After(now) && (k.ExpiresAt == nil || k.ExpiresAt.After(now)) {
+			return k
+		}
+	}
+	return nil
+}
+
+func (s *KeySet) find(kid string, now time.Time) *Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, k := range s.keys {
+		if k.Kid == kid && (k.ExpiresAt == nil || k.ExpiresAt.After(now)) {
+			return k
+		}
+	}
+	return nil
+}
+
+// Jwks lists the public keys, the ones not signing yet included so verifiers can cache them early
+func (s *KeySet) Jwks() *Jwks {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	jwks := &Jwks{
+		Keys: make([]*Jwk, 0, len(s.keys)),
+	}
+	now := time.Now()
+	for _, k := range s.keys {
+		if k.ExpiresAt == nil || k.ExpiresAt.After(now) {
+			jwks.Keys = append(jwks.Keys, k.Jwk())
+		}
+	}
+	return jwks
+}
@@ -0,0 +1,16 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS set_updated_at_timestamp_shipping_zones_table ON "shipping_zones";
+DROP TRIGGER IF EXISTS set_updated_at_timestamp_shipping_methods_table ON "shipping_methods";
+
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "shipping_fee";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "shipping";
+
+DROP TABLE IF EXISTS "shipping_methods" CASCADE;
+DROP TABLE IF EXISTS "shipping_zones" CASCADE;
+
+ALTER TABLE "products" DROP COLUMN IF EXISTS "weight";
+
+DROP TYPE IF EXISTS "shipping_method_type";
+
+COMMIT;
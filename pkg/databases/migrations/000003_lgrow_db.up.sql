@@ -0,0 +1,44 @@
+BEGIN;
+
+--Create enum
+CREATE TYPE "shipping_method_type" AS ENUM (
+    'flat',
+    'weight',
+    'free_over'
+);
+
+--Product weight in kilograms, used by weight-based shipping methods
+ALTER TABLE "products" ADD COLUMN "weight" FLOAT NOT NULL DEFAULT 0;
+
+--A zone without provinces and postcodes matches every address
+CREATE TABLE "shipping_zones" (
+  "id" SERIAL PRIMARY KEY,
+  "title" VARCHAR UNIQUE NOT NULL,
+  "provinces" VARCHAR[] NOT NULL DEFAULT '{}',
+  "postcodes" VARCHAR[] NOT NULL DEFAULT '{}',
+  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE TABLE "shipping_methods" (
+  "id" SERIAL PRIMARY KEY,
+  "zone_id" INT NOT NULL,
+  "title" VARCHAR NOT NULL,
+  "type" shipping_method_type NOT NULL,
+  "rate" FLOAT NOT NULL DEFAULT 0,
+  "rate_per_kg" FLOAT NOT NULL DEFAULT 0,
+  "free_over" FLOAT NOT NULL DEFAULT 0,
+  "is_active" BOOLEAN NOT NULL DEFAULT TRUE,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+ALTER TABLE "orders" ADD COLUMN "shipping" jsonb;
+ALTER TABLE "orders" ADD COLUMN "shipping_fee" FLOAT NOT NULL DEFAULT 0;
+
+ALTER TABLE "shipping_methods" ADD FOREIGN KEY ("zone_id") REFERENCES "shipping_zones" ("id") ON DELETE CASCADE;
+
+CREATE TRIGGER set_updated_at_timestamp_shipping_zones_table BEFORE UPDATE ON "shipping_zones" FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+CREATE TRIGGER set_updated_at_timestamp_shipping_methods_table BEFORE UPDATE ON "shipping_methods" FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+
+COMMIT;
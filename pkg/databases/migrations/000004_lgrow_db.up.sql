@@ -0,0 +1,27 @@
+BEGIN;
+
+CREATE TABLE "shipments" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "order_id" VARCHAR NOT NULL,
+  "carrier" VARCHAR NOT NULL,
+  "tracking_number" VARCHAR NOT NULL,
+  "shipped_at" TIMESTAMP NOT NULL DEFAULT now(),
+  "delivered_at" TIMESTAMP,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE TABLE "shipments_items" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "shipment_id" uuid NOT NULL,
+  "products_order_id" uuid NOT NULL,
+  "qty" INT NOT NULL DEFAULT 1
+);
+
+ALTER TABLE "shipments" ADD FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE CASCADE;
+ALTER TABLE "shipments_items" ADD FOREIGN KEY ("shipment_id") REFERENCES "shipments" ("id") ON DELETE CASCADE;
+ALTER TABLE "shipments_items" ADD FOREIGN KEY ("products_order_id") REFERENCES "products_orders" ("id") ON DELETE CASCADE;
+
+CREATE TRIGGER set_updated_at_timestamp_shipments_table BEFORE UPDATE ON "shipments" FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+
+COMMIT;
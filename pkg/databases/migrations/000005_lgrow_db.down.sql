@@ -0,0 +1,14 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS set_updated_at_timestamp_returns_table ON "returns";
+
+DROP TABLE IF EXISTS "refunds" CASCADE;
+DROP TABLE IF EXISTS "returns_items" CASCADE;
+DROP TABLE IF EXISTS "returns" CASCADE;
+DROP TABLE IF EXISTS "orders_histories" CASCADE;
+
+ALTER TABLE "products" DROP COLUMN IF EXISTS "stock";
+
+DROP TYPE IF EXISTS "return_status";
+
+COMMIT;
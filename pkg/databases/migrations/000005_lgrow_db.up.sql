@@ -0,0 +1,62 @@
+BEGIN;
+
+--Create enum
+CREATE TYPE "return_status" AS ENUM (
+    'requested',
+    'approved',
+    'rejected',
+    'received',
+    'refunded'
+);
+
+--NULL stock means the product is not tracked
+ALTER TABLE "products" ADD COLUMN "stock" INT CHECK ("stock" >= 0);
+
+CREATE TABLE "orders_histories" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "order_id" VARCHAR NOT NULL,
+  "status" order_status NOT NULL,
+  "note" VARCHAR NOT NULL DEFAULT '',
+  "created_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE TABLE "returns" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "order_id" VARCHAR NOT NULL,
+  "user_id" VARCHAR NOT NULL,
+  "reason" VARCHAR NOT NULL DEFAULT '',
+  "status" return_status NOT NULL DEFAULT 'requested',
+  "note" VARCHAR NOT NULL DEFAULT '',
+  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE TABLE "returns_items" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "return_id" uuid NOT NULL,
+  "products_order_id" uuid NOT NULL,
+  "qty" INT NOT NULL DEFAULT 1
+);
+
+CREATE TABLE "refunds" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "order_id" VARCHAR NOT NULL,
+  "return_id" uuid,
+  "amount" FLOAT NOT NULL,
+  "method" VARCHAR NOT NULL,
+  "reference" VARCHAR NOT NULL DEFAULT '',
+  "created_by" VARCHAR NOT NULL,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+ALTER TABLE "orders_histories" ADD FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE CASCADE;
+ALTER TABLE "returns" ADD FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE CASCADE;
+ALTER TABLE "returns" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE;
+ALTER TABLE "returns_items" ADD FOREIGN KEY ("return_id") REFERENCES "returns" ("id") ON DELETE CASCADE;
+ALTER TABLE "returns_items" ADD FOREIGN KEY ("products_order_id") REFERENCES "products_orders" ("id") ON DELETE CASCADE;
+ALTER TABLE "refunds" ADD FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE CASCADE;
+ALTER TABLE "refunds" ADD FOREIGN KEY ("return_id") REFERENCES "returns" ("id") ON DELETE SET NULL;
+
+CREATE TRIGGER set_updated_at_timestamp_returns_table BEFORE UPDATE ON "returns" FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+
+COMMIT;
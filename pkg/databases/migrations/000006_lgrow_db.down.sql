@@ -0,0 +1,9 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS set_updated_at_timestamp_addresses_table ON "addresses";
+
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "shipping_address";
+
+DROP TABLE IF EXISTS "addresses" CASCADE;
+
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS set_updated_at_timestamp_payments_table ON "payments";
+
+DROP TABLE IF EXISTS "payments" CASCADE;
+
+DROP TYPE IF EXISTS "payment_status";
+
+--Enum values cannot be dropped, move paid orders back to waiting
+UPDATE "orders" SET "status" = 'waiting' WHERE "status" = 'paid';
+
+COMMIT;
@@ -0,0 +1,32 @@
+BEGIN;
+
+--Orders leave waiting only when a payment succeeds
+ALTER TYPE "order_status" ADD VALUE IF NOT EXISTS 'paid' AFTER 'waiting';
+
+--Create enum
+CREATE TYPE "payment_status" AS ENUM (
+    'pending',
+    'succeeded',
+    'failed',
+    'refunded'
+);
+
+CREATE TABLE "payments" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "order_id" VARCHAR NOT NULL,
+  "provider" VARCHAR NOT NULL,
+  "provider_ref" VARCHAR NOT NULL DEFAULT '',
+  "amount" FLOAT NOT NULL,
+  "status" payment_status NOT NULL DEFAULT 'pending',
+  "error" VARCHAR NOT NULL DEFAULT '',
+  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX "payments_provider_ref_idx" ON "payments" ("provider", "provider_ref");
+
+ALTER TABLE "payments" ADD FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE CASCADE;
+
+CREATE TRIGGER set_updated_at_timestamp_payments_table BEFORE UPDATE ON "payments" FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+
+COMMIT;
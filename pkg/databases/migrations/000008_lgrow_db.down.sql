@@ -0,0 +1,9 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS set_updated_at_timestamp_transfer_slips_table ON "transfer_slips";
+
+DROP TABLE IF EXISTS "transfer_slips" CASCADE;
+
+DROP TYPE IF EXISTS "transfer_slip_status";
+
+COMMIT;
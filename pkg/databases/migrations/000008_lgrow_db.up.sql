@@ -0,0 +1,33 @@
+BEGIN;
+
+--Create enum
+CREATE TYPE "transfer_slip_status" AS ENUM (
+    'pending',
+    'approved',
+    'rejected'
+);
+
+--Slip files are stored privately, "destination" is the path in the private storage
+CREATE TABLE "transfer_slips" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "order_id" VARCHAR NOT NULL,
+  "filename" VARCHAR NOT NULL,
+  "destination" VARCHAR NOT NULL,
+  "amount" FLOAT NOT NULL,
+  "transferred_at" TIMESTAMP NOT NULL,
+  "status" transfer_slip_status NOT NULL DEFAULT 'pending',
+  "reason" VARCHAR NOT NULL DEFAULT '',
+  "reviewed_by" VARCHAR,
+  "reviewed_at" TIMESTAMP,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+--Only one slip per order can wait for verification
+CREATE UNIQUE INDEX "transfer_slips_order_id_pending_idx" ON "transfer_slips" ("order_id") WHERE "status" = 'pending';
+
+ALTER TABLE "transfer_slips" ADD FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE CASCADE;
+
+CREATE TRIGGER set_updated_at_timestamp_transfer_slips_table BEFORE UPDATE ON "transfer_slips" FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP INDEX IF EXISTS "transfer_slips_trans_ref_idx";
+
+ALTER TABLE "transfer_slips" DROP COLUMN IF EXISTS "trans_ref";
+ALTER TABLE "transfer_slips" DROP COLUMN IF EXISTS "sending_bank";
+
+COMMIT;
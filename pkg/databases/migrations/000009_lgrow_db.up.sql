@@ -0,0 +1,10 @@
+BEGIN;
+
+--Parsed from the slip mini-QR
+ALTER TABLE "transfer_slips" ADD COLUMN "sending_bank" VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE "transfer_slips" ADD COLUMN "trans_ref" VARCHAR NOT NULL DEFAULT '';
+
+--A transaction reference can only pay for one order
+CREATE UNIQUE INDEX "transfer_slips_trans_ref_idx" ON "transfer_slips" ("trans_ref") WHERE "trans_ref" <> '' AND "status" <> 'rejected';
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS set_updated_at_timestamp_invoice_sequences_table ON "invoice_sequences";
+
+DROP TABLE IF EXISTS "invoices" CASCADE;
+DROP TABLE IF EXISTS "invoice_sequences" CASCADE;
+
+COMMIT;
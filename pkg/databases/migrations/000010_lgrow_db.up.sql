@@ -0,0 +1,28 @@
+BEGIN;
+
+--Last issued number per fiscal year, the row is locked while an invoice is issued so numbers have no gaps
+CREATE TABLE "invoice_sequences" (
+  "fiscal_year" INT PRIMARY KEY,
+  "last_number" INT NOT NULL DEFAULT 0,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+--One invoice per order, issued the first time it is requested
+CREATE TABLE "invoices" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "order_id" VARCHAR NOT NULL UNIQUE,
+  "fiscal_year" INT NOT NULL,
+  "number" INT NOT NULL,
+  "invoice_no" VARCHAR NOT NULL UNIQUE,
+  "issued_at" TIMESTAMP NOT NULL DEFAULT now(),
+  UNIQUE ("fiscal_year", "number")
+);
+
+--Issued invoices are tax records, the order cannot be deleted afterwards
+ALTER TABLE "invoices" ADD FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE RESTRICT;
+ALTER TABLE "invoices" ADD FOREIGN KEY ("fiscal_year") REFERENCES "invoice_sequences" ("fiscal_year");
+
+CREATE TRIGGER set_updated_at_timestamp_invoice_sequences_table BEFORE UPDATE ON "invoice_sequences" FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+
+COMMIT;
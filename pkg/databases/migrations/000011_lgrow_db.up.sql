@@ -0,0 +1,18 @@
+BEGIN;
+
+--"internal" notes are only visible to admins
+CREATE TABLE "orders_notes" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "order_id" VARCHAR NOT NULL,
+  "user_id" VARCHAR NOT NULL,
+  "internal" BOOLEAN NOT NULL DEFAULT FALSE,
+  "note" VARCHAR NOT NULL,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX "orders_notes_order_id_idx" ON "orders_notes" ("order_id");
+
+ALTER TABLE "orders_notes" ADD FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE CASCADE;
+ALTER TABLE "orders_notes" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE;
+
+COMMIT;
@@ -0,0 +1,13 @@
+BEGIN;
+
+--Unclaimed guest orders cannot exist without a user
+DELETE FROM "orders" WHERE "user_id" IS NULL;
+
+DROP INDEX IF EXISTS "orders_guest_email_idx";
+
+ALTER TABLE "orders" DROP CONSTRAINT IF EXISTS "orders_user_or_guest_check";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "guest_token";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "guest_email";
+ALTER TABLE "orders" ALTER COLUMN "user_id" SET NOT NULL;
+
+COMMIT;
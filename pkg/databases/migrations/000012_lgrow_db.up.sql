@@ -0,0 +1,11 @@
+BEGIN;
+
+--Guest orders have no user until they are claimed, "guest_token" is the sha256 of the order access token
+ALTER TABLE "orders" ALTER COLUMN "user_id" DROP NOT NULL;
+ALTER TABLE "orders" ADD COLUMN "guest_email" VARCHAR;
+ALTER TABLE "orders" ADD COLUMN "guest_token" VARCHAR;
+ALTER TABLE "orders" ADD CONSTRAINT "orders_user_or_guest_check" CHECK ("user_id" IS NOT NULL OR ("guest_email" IS NOT NULL AND "guest_token" IS NOT NULL));
+
+CREATE INDEX "orders_guest_email_idx" ON "orders" (LOWER("guest_email")) WHERE "user_id" IS NULL;
+
+COMMIT;
@@ -0,0 +1,13 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS reject_credits_ledger_change_trigger ON "credits_ledger";
+DROP TRIGGER IF EXISTS set_updated_at_timestamp_gift_cards_table ON "gift_cards";
+
+DROP FUNCTION IF EXISTS reject_credits_ledger_change();
+
+DROP TABLE IF EXISTS "credits_ledger" CASCADE;
+DROP TABLE IF EXISTS "gift_cards" CASCADE;
+
+DROP TYPE IF EXISTS "credit_entry_kind";
+
+COMMIT;
@@ -0,0 +1,62 @@
+BEGIN;
+
+--Create enum
+CREATE TYPE "credit_entry_kind" AS ENUM (
+    'issue',
+    'purchase',
+    'redeem',
+    'refund',
+    'adjust'
+);
+
+--Purchased cards have an order and are activated when the order is paid
+CREATE TABLE "gift_cards" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "code" VARCHAR NOT NULL UNIQUE,
+  "amount" FLOAT NOT NULL CHECK ("amount" > 0),
+  "expires_at" TIMESTAMP,
+  "issued_by" VARCHAR,
+  "purchased_by" VARCHAR,
+  "order_id" VARCHAR,
+  "note" VARCHAR NOT NULL DEFAULT '',
+  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+--Every credit (+) and debit (-) of a gift card or a user store credit, balances are the sum of the entries
+CREATE TABLE "credits_ledger" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "gift_card_id" uuid,
+  "user_id" VARCHAR,
+  "order_id" VARCHAR,
+  "kind" credit_entry_kind NOT NULL,
+  "amount" FLOAT NOT NULL CHECK ("amount" <> 0),
+  "note" VARCHAR NOT NULL DEFAULT '',
+  "created_by" VARCHAR,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+  CHECK (("gift_card_id" IS NULL) <> ("user_id" IS NULL))
+);
+
+CREATE INDEX "credits_ledger_gift_card_id_idx" ON "credits_ledger" ("gift_card_id");
+CREATE INDEX "credits_ledger_user_id_idx" ON "credits_ledger" ("user_id");
+CREATE INDEX "credits_ledger_order_id_idx" ON "credits_ledger" ("order_id");
+
+ALTER TABLE "gift_cards" ADD FOREIGN KEY ("purchased_by") REFERENCES "users" ("id") ON DELETE SET NULL;
+ALTER TABLE "gift_cards" ADD FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE SET NULL;
+ALTER TABLE "credits_ledger" ADD FOREIGN KEY ("gift_card_id") REFERENCES "gift_cards" ("id");
+ALTER TABLE "credits_ledger" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
+ALTER TABLE "credits_ledger" ADD FOREIGN KEY ("order_id") REFERENCES "orders" ("id");
+
+CREATE TRIGGER set_updated_at_timestamp_gift_cards_table BEFORE UPDATE ON "gift_cards" FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+
+--Ledger entries are never changed, a mistake is corrected with a new entry
+CREATE OR REPLACE FUNCTION reject_credits_ledger_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'credits ledger entries are immutable';
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER reject_credits_ledger_change_trigger BEFORE UPDATE OR DELETE ON "credits_ledger" FOR EACH ROW EXECUTE PROCEDURE reject_credits_ledger_change();
+
+COMMIT;
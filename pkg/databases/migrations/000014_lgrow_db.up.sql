@@ -0,0 +1,23 @@
+BEGIN;
+
+--Accounts created before verification existed are treated as verified
+ALTER TABLE "users" ADD COLUMN "email_verified_at" TIMESTAMP;
+UPDATE "users" SET "email_verified_at" = "created_at";
+
+--One row per verification email, the id is the jti of the signed token
+--Guest orders are claimed once the email is proven to be owned by the user
+CREATE TABLE "users_email_verifications" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "user_id" VARCHAR NOT NULL,
+  "email" VARCHAR NOT NULL,
+  "claim_guest_orders" BOOLEAN NOT NULL DEFAULT FALSE,
+  "expires_at" TIMESTAMP NOT NULL,
+  "used_at" TIMESTAMP,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX "users_email_verifications_user_id_idx" ON "users_email_verifications" ("user_id");
+
+ALTER TABLE "users_email_verifications" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE;
+
+COMMIT;
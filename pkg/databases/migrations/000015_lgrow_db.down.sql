@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE IF EXISTS "users_password_resets" CASCADE;
+
+COMMIT;
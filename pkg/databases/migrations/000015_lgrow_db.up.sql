@@ -0,0 +1,17 @@
+BEGIN;
+
+--Only the sha256 of the token is kept, the token itself is in the email
+CREATE TABLE "users_password_resets" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "user_id" VARCHAR NOT NULL,
+  "token_hash" VARCHAR NOT NULL UNIQUE,
+  "expires_at" TIMESTAMP NOT NULL,
+  "used_at" TIMESTAMP,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX "users_password_resets_user_id_idx" ON "users_password_resets" ("user_id");
+
+ALTER TABLE "users_password_resets" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE;
+
+COMMIT;
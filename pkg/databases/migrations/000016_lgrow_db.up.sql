@@ -0,0 +1,18 @@
+BEGIN;
+
+--Who did what to an account, actor_id is empty when the system did it
+CREATE TABLE "users_audit_logs" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "user_id" VARCHAR NOT NULL,
+  "actor_id" VARCHAR,
+  "action" VARCHAR NOT NULL,
+  "detail" VARCHAR NOT NULL DEFAULT '',
+  "created_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX "users_audit_logs_user_id_idx" ON "users_audit_logs" ("user_id");
+
+ALTER TABLE "users_audit_logs" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE;
+ALTER TABLE "users_audit_logs" ADD FOREIGN KEY ("actor_id") REFERENCES "users" ("id") ON DELETE SET NULL;
+
+COMMIT;
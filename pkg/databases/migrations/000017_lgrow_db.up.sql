@@ -0,0 +1,9 @@
+BEGIN;
+
+--Where a session signed in from, "updated_at" is the last refresh
+ALTER TABLE "oauth" ADD COLUMN "ip" VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE "oauth" ADD COLUMN "user_agent" VARCHAR NOT NULL DEFAULT '';
+
+CREATE INDEX "oauth_user_id_idx" ON "oauth" ("user_id");
+
+COMMIT;
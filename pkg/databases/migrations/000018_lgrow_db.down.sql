@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX IF EXISTS "oauth_refresh_token_idx";
+
+DROP TABLE IF EXISTS "oauth_rotated_tokens" CASCADE;
+
+COMMIT;
@@ -0,0 +1,17 @@
+BEGIN;
+
+--An oauth row is a token family, every refresh token it has given up is kept until the family ends
+--A rotated token coming back means it has leaked and the family is revoked
+CREATE TABLE "oauth_rotated_tokens" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "oauth_id" uuid NOT NULL,
+  "refresh_token" VARCHAR NOT NULL,
+  "rotated_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX "oauth_rotated_tokens_refresh_token_idx" ON "oauth_rotated_tokens" ("refresh_token");
+CREATE INDEX "oauth_refresh_token_idx" ON "oauth" ("refresh_token");
+
+ALTER TABLE "oauth_rotated_tokens" ADD FOREIGN KEY ("oauth_id") REFERENCES "oauth" ("id") ON DELETE CASCADE;
+
+COMMIT;
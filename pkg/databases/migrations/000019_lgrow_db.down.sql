@@ -0,0 +1,16 @@
+BEGIN;
+
+--Hashes cannot be turned back into tokens, every session is signed out
+DELETE FROM "oauth";
+
+DROP INDEX IF EXISTS "oauth_access_token_hash_idx";
+
+ALTER TABLE "oauth_rotated_tokens" DROP CONSTRAINT IF EXISTS "oauth_rotated_tokens_refresh_token_hash_check";
+ALTER TABLE "oauth" DROP CONSTRAINT IF EXISTS "oauth_refresh_token_hash_check";
+ALTER TABLE "oauth" DROP CONSTRAINT IF EXISTS "oauth_access_token_hash_check";
+
+ALTER TABLE "oauth_rotated_tokens" RENAME COLUMN "refresh_token_hash" TO "refresh_token";
+ALTER TABLE "oauth" RENAME COLUMN "refresh_token_hash" TO "refresh_token";
+ALTER TABLE "oauth" RENAME COLUMN "access_token_hash" TO "access_token";
+
+COMMIT;
@@ -0,0 +1,17 @@
+BEGIN;
+
+--Only sha256 hashes of the tokens are kept, the raw tokens stored so far cannot be
+--hashed in place safely so every session is signed out and users sign in again
+DELETE FROM "oauth";
+
+ALTER TABLE "oauth" RENAME COLUMN "access_token" TO "access_token_hash";
+ALTER TABLE "oauth" RENAME COLUMN "refresh_token" TO "refresh_token_hash";
+ALTER TABLE "oauth_rotated_tokens" RENAME COLUMN "refresh_token" TO "refresh_token_hash";
+
+ALTER TABLE "oauth" ADD CONSTRAINT "oauth_access_token_hash_check" CHECK (LENGTH("access_token_hash") = 64);
+ALTER TABLE "oauth" ADD CONSTRAINT "oauth_refresh_token_hash_check" CHECK (LENGTH("refresh_token_hash") = 64);
+ALTER TABLE "oauth_rotated_tokens" ADD CONSTRAINT "oauth_rotated_tokens_refresh_token_hash_check" CHECK (LENGTH("refresh_token_hash") = 64);
+
+CREATE INDEX "oauth_access_token_hash_idx" ON "oauth" ("access_token_hash");
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS set_updated_at_timestamp_users_mfa_table ON "users_mfa";
+
+DROP TABLE IF EXISTS "users_mfa_recovery_codes" CASCADE;
+DROP TABLE IF EXISTS "users_mfa" CASCADE;
+
+COMMIT;
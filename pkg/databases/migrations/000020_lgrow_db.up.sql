@@ -0,0 +1,28 @@
+BEGIN;
+
+--TOTP is enabled once confirmed, last_used_step stops a code from being used twice
+CREATE TABLE "users_mfa" (
+  "user_id" VARCHAR PRIMARY KEY,
+  "secret" VARCHAR NOT NULL,
+  "confirmed_at" TIMESTAMP,
+  "last_used_step" BIGINT NOT NULL DEFAULT 0,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+--Only the sha256 of the recovery codes is kept
+CREATE TABLE "users_mfa_recovery_codes" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "user_id" VARCHAR NOT NULL,
+  "code_hash" VARCHAR NOT NULL,
+  "used_at" TIMESTAMP,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+  UNIQUE ("user_id", "code_hash")
+);
+
+ALTER TABLE "users_mfa" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE;
+ALTER TABLE "users_mfa_recovery_codes" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE;
+
+CREATE TRIGGER set_updated_at_timestamp_users_mfa_table BEFORE UPDATE ON "users_mfa" FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+
+COMMIT;
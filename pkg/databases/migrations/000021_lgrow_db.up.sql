@@ -0,0 +1,11 @@
+BEGIN;
+
+--Failed sign ins by "account:<email>", "ip:<address>" and "mfa:<user_id>", unknown emails are counted the same way
+CREATE TABLE "users_login_throttles" (
+  "key" VARCHAR PRIMARY KEY,
+  "failures" INT NOT NULL DEFAULT 0,
+  "blocked_until" TIMESTAMP,
+  "last_failed_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE IF EXISTS "jwt_keys" CASCADE;
+
+COMMIT;
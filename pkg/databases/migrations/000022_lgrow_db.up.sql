@@ -0,0 +1,17 @@
+BEGIN;
+
+--RS256/EdDSA keys by kid, the private key is encrypted with JWT_SECRET_KEY
+--expires_at is set when a newer key takes over, after the last token signed by it expired
+CREATE TABLE "jwt_keys" (
+  "kid" VARCHAR PRIMARY KEY,
+  "alg" VARCHAR NOT NULL CHECK ("alg" IN ('RS256', 'EdDSA')),
+  "private_key" TEXT NOT NULL,
+  "public_key" TEXT NOT NULL,
+  "active_from" TIMESTAMPTZ NOT NULL,
+  "expires_at" TIMESTAMPTZ,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX ON "jwt_keys" ("active_from");
+
+COMMIT;
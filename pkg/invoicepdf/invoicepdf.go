@@ -0,0 +1,164 @@
+package invoicepdf
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+
+	"github.com/go-pdf/fpdf"
+)
+
+type Store struct {
+	Name    string
+	Address string
+	TaxId   string
+	Phone   string
+}
+
+type Line struct {
+	Title string
+	Qty   int
+	Price float64
+}
+
+type Invoice struct {
+	No            string
+	IssuedAt      string
+	OrderId       string
+	Customer      string
+	Address       string
+	Contact       string
+	Lines         []*Line
+	ShippingTitle string
+	ShippingFee   float64
+	VatRate       float64 // percent, prices include vat
+	Store         *Store
+}
+
+const (
+	fontFamily = "invoice"
+	lineHeight = 6.0
+)
+
+// Render draws an A4 tax invoice, fontFile is a utf-8 ttf font for non latin text
+// and falls back to helvetica when empty
+func Render(inv *Invoice, fontFile string) ([]byte, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle(fmt.Sprintf("Tax invoice %s", inv.No), true)
+
+	family := "Helvetica"
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	if fontFile != "" {
+		pdf.AddUTF8Font(fontFamily, "", fontFile)
+		pdf.AddUTF8Font(fontFamily, "B", fontFile)
+		family = fontFamily
+		tr = func(s string) string { return s }
+	}
+
+	pdf.AddPage()
+
+	// Store
+	pdf.SetFont(family, "B", 16)
+	pdf.CellFormat(0, 8, tr(inv.Store.Name), "", 1, "L", false, 0, "")
+	pdf.SetFont(family, "", 10)
+	for _, v := range []string{
+		inv.Store.Address,
+		labeled("Tax ID", inv.Store.TaxId),
+		labeled("Tel", inv.Store.Phone),
+	} {
+		if v != "" {
+			pdf.MultiCell(0, 5, tr(v), "", "L", false)
+		}
+	}
+
+	// Title
+	pdf.Ln(4)
+	pdf.SetFont(family, "B", 14)
+	pdf.CellFormat(0, 8, "TAX INVOICE / RECEIPT", "", 1, "C", false, 0, "")
+	pdf.Ln(2)
+
+	// Invoice & customer
+	pdf.SetFont(family, "", 10)
+	y := pdf.GetY()
+	pdf.MultiCell(110, 5, tr(fmt.Sprintf("Bill to: %s\n%s\n%s", inv.Customer, inv.Address, inv.Contact)), "", "L", false)
+	bottom := pdf.GetY()
+	pdf.SetXY(130, y)
+	pdf.MultiCell(0, 5, tr(fmt.Sprintf("No: %s\nDate: %s\nOrder: %s", inv.No, inv.IssuedAt, inv.OrderId)), "", "L", false)
+	pdf.SetY(math.Max(bottom, pdf.GetY()) + 4)
+
+	// Items
+	widths := []float64{10, 100, 20, 30, 30}
+	pdf.SetFont(family, "B", 10)
+	pdf.SetFillColor(235, 235, 235)
+	for i, v := range []string{"#", "Description", "Qty", "Unit price", "Amount"} {
+		align := "R"
+		if i == 1 {
+			align = "L"
+		}
+		pdf.CellFormat(widths[i], 7, v, "1", 0, align, true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont(family, "", 10)
+	var total float64
+	row := func(no, title, qty string, price, amount float64) {
+		pdf.CellFormat(widths[0], lineHeight, no, "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[1], lineHeight, tr(title), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[2], lineHeight, qty, "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[3], lineHeight, money(price), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[4], lineHeight, money(amount), "1", 1, "R", false, 0, "")
+		total += amount
+	}
+	for i, v := range inv.Lines {
+		row(fmt.Sprint(i+1), v.Title, fmt.Sprint(v.Qty), v.Price, v.Price*float64(v.Qty))
+	}
+	if inv.ShippingFee > 0 {
+		title := "Shipping"
+		if inv.ShippingTitle != "" {
+			title += " - " + inv.ShippingTitle
+		}
+		row(fmt.Sprint(len(inv.Lines)+1), title, "1", inv.ShippingFee, inv.ShippingFee)
+	}
+
+	// Totals, vat is already included in the prices
+	vat := round(total * inv.VatRate / (100 + inv.VatRate))
+	pdf.Ln(2)
+	for _, v := range []struct {
+		label  string
+		amount float64
+		bold   bool
+	}{
+		{"Value before VAT", total - vat, false},
+		{fmt.Sprintf("VAT %g%%", inv.VatRate), vat, false},
+		{"Total", total, true},
+	} {
+		style := ""
+		if v.bold {
+			style = "B"
+		}
+		pdf.SetFont(family, style, 10)
+		pdf.CellFormat(widths[0]+widths[1]+widths[2]+widths[3], lineHeight, v.label, "", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[4], lineHeight, money(v.amount), "", 1, "R", false, 0, "")
+	}
+
+	buf := new(bytes.Buffer)
+	if err := pdf.Output(buf); err != nil {
+		return nil, fmt.Errorf("render invoice failed: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func labeled(label, value string) string {
+	if value == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s: %s", label, value)
+}
+
+func money(v float64) string {
+	return fmt.Sprintf("%.2f", v)
+}
+
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
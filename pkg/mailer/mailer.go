@@ -0,0 +1,110 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/LGROW101/lgrow-shop/config"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string // plain text
+}
+
+type IMailer interface {
+	Send(msg *Message) error
+}
+
+// NewMailer picks the mailer from MAIL_DRIVER, file and log are meant for development
+func NewMailer(cfg config.IMailConfig) IMailer {
+	switch cfg.Driver() {
+	case "smtp":
+		return SmtpMailer(cfg)
+	case "file":
+		return FileMailer(cfg.Dir(), cfg.From())
+	default:
+		return LogMailer(cfg.From())
+	}
+}
+
+func build(from string, msg *Message) []byte {
+	header := []string{
+		fmt.Sprintf("From: %s", from),
+		fmt.Sprintf("To: %s", msg.To),
+		fmt.Sprintf("Subject: %s", msg.Subject),
+		fmt.Sprintf("Date: %s", time.Now().Format(time.RFC1123Z)),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=\"utf-8\"",
+	}
+	return []byte(strings.Join(header, "\r\n") + "\r\n\r\n" + strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+}
+
+type smtpMailer struct {
+	cfg config.IMailConfig
+}
+
+func SmtpMailer(cfg config.IMailConfig) IMailer {
+	return &smtpMailer{cfg: cfg}
+}
+
+func (m *smtpMailer) Send(msg *Message) error {
+	var auth smtp.Auth
+	if m.cfg.Username() != "" {
+		auth = smtp.PlainAuth("", m.cfg.Username(), m.cfg.Password(), m.cfg.Host())
+	}
+
+	addr := fmt.Sprintf("%s:%d", m.cfg.Host(), m.cfg.Port())
+	if err := smtp.SendMail(addr, auth, m.cfg.From(), []string{msg.To}, build(m.cfg.From(), msg)); err != nil {
+		return fmt.Errorf("send mail failed: %v", err)
+	}
+	return nil
+}
+
+type fileMailer struct {
+	dir  string
+	from string
+}
+
+// FileMailer writes every message to dir as an .eml file
+func FileMailer(dir, from string) IMailer {
+	if dir == "" {
+		dir = "./mails"
+	}
+	return &fileMailer{
+		dir:  dir,
+		from: from,
+	}
+}
+
+func (m *fileMailer) Send(msg *Message) error {
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return fmt.Errorf("create mail dir failed: %v", err)
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102150405.000000"), regexp.MustCompile(`[^\w.@-]`).ReplaceAllString(msg.To, "_"))
+	if err := os.WriteFile(filepath.Join(m.dir, name), build(m.from, msg), 0644); err != nil {
+		return fmt.Errorf("write mail failed: %v", err)
+	}
+	return nil
+}
+
+type logMailer struct {
+	from string
+}
+
+func LogMailer(from string) IMailer {
+	return &logMailer{from: from}
+}
+
+func (m *logMailer) Send(msg *Message) error {
+	log.Printf("mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
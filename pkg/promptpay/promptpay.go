@@ -0,0 +1,108 @@
+package promptpay
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// EMVCo merchant presented QR for PromptPay
+// https://www.emvco.com/emv-technologies/qrcodes/
+const (
+	idPayloadFormat     = "00"
+	idPointOfInitMethod = "01"
+	idMerchantAccount   = "29"
+	idCountryCode       = "58"
+	idCurrency          = "53"
+	idAmount            = "54"
+	idAdditionalData    = "62"
+	idCRC               = "63"
+
+	merchantAID    = "A000000677010111"
+	subIdAID       = "00"
+	subIdPhone     = "01"
+	subIdTaxId     = "02"
+	subIdEWallet   = "03"
+	subIdReference = "05"
+	payloadFormat  = "01"
+	staticInit     = "11"
+	dynamicInit    = "12"
+	countryTH      = "TH"
+	currencyTHB    = "764"
+)
+
+var nonDigit = regexp.MustCompile(`[^0-9]`)
+
+func field(id, value string) string {
+	return fmt.Sprintf("%s%02d%s", id, len(value), value)
+}
+
+// Payload builds the PromptPay payload of a phone number, national/tax id or e-wallet id.
+// amount <= 0 gives a static QR, ref is put into the reference label when not empty
+func Payload(promptPayId string, amount float64, ref string) (string, error) {
+	id := nonDigit.ReplaceAllString(promptPayId, "")
+
+	var account string
+	switch {
+	case len(id) >= 15:
+		account = field(subIdEWallet, id)
+	case len(id) >= 13:
+		account = field(subIdTaxId, id)
+	case len(id) == 10 && strings.HasPrefix(id, "0"):
+		// 0812345678 -> 0066812345678
+		account = field(subIdPhone, "0066"+id[1:])
+	default:
+		return "", fmt.Errorf("promptpay id is invalid")
+	}
+
+	if len(ref) > 25 {
+		return "", fmt.Errorf("reference must not exceed 25 characters")
+	}
+
+	initMethod := staticInit
+	if amount > 0 {
+		initMethod = dynamicInit
+	}
+
+	payload := field(idPayloadFormat, payloadFormat) +
+		field(idPointOfInitMethod, initMethod) +
+		field(idMerchantAccount, field(subIdAID, merchantAID)+account) +
+		field(idCountryCode, countryTH) +
+		field(idCurrency, currencyTHB)
+	if amount > 0 {
+		payload += field(idAmount, fmt.Sprintf("%.2f", amount))
+	}
+	if ref != "" {
+		payload += field(idAdditionalData, field(subIdReference, ref))
+	}
+
+	payload += idCRC + "04"
+	return payload + fmt.Sprintf("%04X", crc16(payload)), nil
+}
+
+// PNG encodes the payload into a QR image
+func PNG(payload string, size int) ([]byte, error) {
+	png, err := qrcode.Encode(payload, qrcode.Medium, size)
+	if err != nil {
+		return nil, fmt.Errorf("encode qr failed: %v", err)
+	}
+	return png, nil
+}
+
+// CRC-16/CCITT-FALSE
+func crc16(data string) uint16 {
+	crc := uint16(0xFFFF)
+	for i := 0; i < len(data); i++ {
+		crc ^= uint16(data[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,155 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+type IScheduler interface {
+	Add(name string, interval time.Duration, run JobFunc)
+	Start()
+	Stop()
+	Status() []*JobStatus
+}
+
+// JobFunc returns a short summary of what the run did
+type JobFunc func(ctx context.Context) (string, error)
+
+type JobStatus struct {
+	Name        string  `json:"name"`
+	Interval    string  `json:"interval"`
+	LastRunAt   *string `json:"last_run_at"`
+	LastRunTook string  `json:"last_run_took"`
+	LastResult  string  `json:"last_result"`
+	LastError   string  `json:"last_error"`
+	NextRunAt   *string `json:"next_run_at"`
+}
+
+type job struct {
+	name     string
+	interval time.Duration
+	run      JobFunc
+	status   *JobStatus
+}
+
+type scheduler struct {
+	mu     sync.RWMutex
+	jobs   []*job
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewScheduler() IScheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &scheduler{
+		jobs:   make([]*job, 0),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+func (s *scheduler) Add(name string, interval time.Duration, run JobFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs = append(s.jobs, &job{
+		name:     name,
+		interval: interval,
+		run:      run,
+		status: &JobStatus{
+			Name:     name,
+			Interval: interval.String(),
+		},
+	})
+}
+
+func (s *scheduler) Start() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(j)
+	}
+}
+
+func (s *scheduler) Stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *scheduler) Status() []*JobStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]*JobStatus, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		status := *j.status
+		res = append(res, &status)
+	}
+	return res
+}
+
+func (s *scheduler) loop(j *job) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	s.setNextRun(j, time.Now())
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.runJob(j)
+		}
+	}
+}
+
+func (s *scheduler) runJob(j *job) {
+	ctx, cancel := context.WithTimeout(s.ctx, j.interval)
+	defer cancel()
+
+	start := time.Now()
+	result, err := s.call(ctx, j)
+	took := time.Since(start)
+
+	s.mu.Lock()
+	startAt := start.Format("2006-01-02 15:04:05")
+	j.status.LastRunAt = &startAt
+	j.status.LastRunTook = took.String()
+	j.status.LastResult = result
+	j.status.LastError = ""
+	if err != nil {
+		j.status.LastError = err.Error()
+		log.Printf("job %s failed: %v", j.name, err)
+	}
+	s.mu.Unlock()
+
+	s.setNextRun(j, start)
+}
+
+// call runs the job, a panic is returned as the error so it cannot take the server down
+func (s *scheduler) call(ctx context.Context, j *job) (result string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("job %s panicked: %v\n%s", j.name, r, debug.Stack())
+			result, err = "", fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return j.run(ctx)
+}
+
+func (s *scheduler) setNextRun(j *job, from time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := from.Add(j.interval).Format("2006-01-02 15:04:05")
+	j.status.NextRunAt = &next
+}
@@ -0,0 +1,117 @@
+package slipqr
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"strconv"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+// Thai bank slip mini-QR (slip verification payload)
+//
+// 00 -> 00 api id, 01 sending bank code, 02 transaction reference
+// 51 -> country code
+// 91 -> crc
+const (
+	idSlip        = "00"
+	idCountry     = "51"
+	subIdApiId    = "00"
+	subIdBank     = "01"
+	subIdTransRef = "02"
+)
+
+var bankMap = map[string]string{
+	"002": "BBL",
+	"004": "KBANK",
+	"006": "KTB",
+	"011": "TTB",
+	"014": "SCB",
+	"022": "CIMBT",
+	"024": "UOB",
+	"025": "BAY",
+	"030": "GSB",
+	"033": "GHB",
+	"034": "BAAC",
+	"067": "TISCO",
+	"069": "KKP",
+	"073": "LHB",
+}
+
+type Slip struct {
+	BankCode string `json:"bank_code"`
+	Bank     string `json:"bank"`
+	TransRef string `json:"trans_ref"`
+	Country  string `json:"country"`
+}
+
+// Decode reads the QR payload from a PNG or JPG image
+func Decode(r io.Reader) (string, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return "", fmt.Errorf("decode image failed: %v", err)
+	}
+
+	bmp, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return "", fmt.Errorf("read image failed: %v", err)
+	}
+
+	result, err := qrcode.NewQRCodeReader().Decode(bmp, map[gozxing.DecodeHintType]interface{}{
+		gozxing.DecodeHintType_TRY_HARDER: true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("qr code not found")
+	}
+	return result.GetText(), nil
+}
+
+// tlv splits an EMV payload into id -> value
+func tlv(payload string) (map[string]string, error) {
+	fields := make(map[string]string)
+	for i := 0; i < len(payload); {
+		if i+4 > len(payload) {
+			return nil, fmt.Errorf("slip qr is invalid")
+		}
+		id := payload[i : i+2]
+		// Length is always two digits, Atoi alone lets "-1" or "+1" through
+		length := payload[i+2 : i+4]
+		if length[0] < '0' || length[0] > '9' || length[1] < '0' || length[1] > '9' {
+			return nil, fmt.Errorf("slip qr is invalid")
+		}
+		size, err := strconv.Atoi(length)
+		if err != nil || size < 0 || i+4+size > len(payload) {
+			return nil, fmt.Errorf("slip qr is invalid")
+		}
+		fields[id] = payload[i+4 : i+4+size]
+		i += 4 + size
+	}
+	return fields, nil
+}
+
+// Parse extracts the sending bank and transaction reference from a slip payload
+func Parse(payload string) (*Slip, error) {
+	fields, err := tlv(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	sub, err := tlv(fields[idSlip])
+	if err != nil {
+		return nil, err
+	}
+	if sub[subIdBank] == "" || sub[subIdTransRef] == "" {
+		return nil, fmt.Errorf("slip qr is invalid")
+	}
+
+	return &Slip{
+		BankCode: sub[subIdBank],
+		Bank:     bankMap[sub[subIdBank]],
+		TransRef: sub[subIdTransRef],
+		Country:  fields[idCountry],
+	}, nil
+}